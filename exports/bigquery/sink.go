@@ -0,0 +1,283 @@
+// Package bigquery lands exported Mixpanel events directly in a BigQuery
+// table using streaming inserts.
+//
+// It lives in its own package so that the (large) BigQuery client library is
+// only pulled in by programs that actually use it.
+package bigquery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	bq "cloud.google.com/go/bigquery"
+	"github.com/erik/mixport/mixpanel"
+)
+
+// Default values of the `Options` fields.
+const (
+	DefaultBatchSize   = 500
+	DefaultExtraColumn = "extra"
+)
+
+// Inserter is the part of `*bigquery.Inserter` the sink uses.
+type Inserter interface {
+	Put(ctx context.Context, src interface{}) error
+}
+
+// Options configures a `Sink`.
+//
+// - `Columns` are the names of the target table's columns. Properties are
+//   matched to columns by `ColumnName`, and any without a column of their own
+//   are collected into a JSON object in `ExtraColumn` instead, so that new
+//   properties don't make inserts fail. See `ColumnsFromSchema`.
+// - `ExtraColumn` is the (string typed) column unknown properties go in.
+//   Defaults to `DefaultExtraColumn`.
+// - `BatchSize` is the number of rows sent per streaming insert. Defaults to
+//   `DefaultBatchSize`.
+type Options struct {
+	Columns     []string
+	ExtraColumn string
+	BatchSize   int
+}
+
+// Sink writes events into a BigQuery table.
+//
+// When several properties of an event map to the same column (see
+// `ColumnName`), the one named exactly like the column gets it, or failing
+// that the first in sorted order. The rest are kept in the extra column.
+type Sink struct {
+	inserter Inserter
+	opts     Options
+	columns  map[string]bool
+}
+
+// NewSink creates a sink inserting into `dataset.table` with `client`.
+func NewSink(client *bq.Client, dataset, table string, opts Options) *Sink {
+	return NewSinkWithInserter(client.Dataset(dataset).Table(table).Inserter(), opts)
+}
+
+// NewSinkWithInserter creates a sink inserting rows with `inserter`.
+func NewSinkWithInserter(inserter Inserter, opts Options) *Sink {
+	if opts.ExtraColumn == "" {
+		opts.ExtraColumn = DefaultExtraColumn
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	columns := make(map[string]bool, len(opts.Columns))
+	for _, column := range opts.Columns {
+		columns[column] = true
+	}
+
+	return &Sink{inserter: inserter, opts: opts, columns: columns}
+}
+
+// ColumnsFromSchema returns the names of the top level columns of `schema`,
+// for use as `Options.Columns`.
+func ColumnsFromSchema(schema bq.Schema) []string {
+	var columns []string
+	for _, field := range schema {
+		columns = append(columns, field.Name)
+	}
+	return columns
+}
+
+// ColumnName maps a property name to a valid BigQuery column name, replacing
+// anything other than letters, digits and underscores with an underscore. For
+// example, `$city` becomes `_city`. Distinct properties can end up with the
+// same name; see `Sink` for how they're told apart.
+func ColumnName(property string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, property)
+
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+
+	return name
+}
+
+// Run inserts every event received on `in` until it is closed, in batches of
+// `BatchSize`. Returns the first insert error, after which the rest of `in`
+// is drained and discarded.
+func (s *Sink) Run(ctx context.Context, in <-chan mixpanel.EventData) error {
+	b := s.newBatcher(ctx)
+
+	for event := range in {
+		if err := b.add(event); err != nil {
+			for range in {
+			}
+
+			return err
+		}
+	}
+
+	return b.flush()
+}
+
+// AsSink adapts the BigQuery sink into a `mixpanel.Sink`, for use with
+// `ExportDateToSink`. Inserts are made with `ctx` as batches fill up, and
+// `Flush` inserts whatever is left over.
+func (s *Sink) AsSink(ctx context.Context) mixpanel.Sink {
+	return &sinkAdapter{s.newBatcher(ctx)}
+}
+
+// batcher collects rows into batches of `BatchSize` for insertion.
+type batcher struct {
+	ctx   context.Context
+	s     *Sink
+	batch []*row
+}
+
+func (s *Sink) newBatcher(ctx context.Context) *batcher {
+	return &batcher{ctx: ctx, s: s, batch: make([]*row, 0, s.opts.BatchSize)}
+}
+
+// add queues `event`, inserting the batch once it's full.
+func (b *batcher) add(event mixpanel.EventData) error {
+	b.batch = append(b.batch, b.s.newRow(event))
+
+	if len(b.batch) < b.s.opts.BatchSize {
+		return nil
+	}
+
+	return b.flush()
+}
+
+// flush inserts the rows queued so far.
+func (b *batcher) flush() error {
+	if len(b.batch) == 0 {
+		return nil
+	}
+
+	err := b.s.inserter.Put(b.ctx, b.batch)
+	b.batch = make([]*row, 0, b.s.opts.BatchSize)
+
+	if err != nil {
+		return fmt.Errorf("bigquery insert failed: %w", err)
+	}
+
+	return nil
+}
+
+// sinkAdapter is the mixpanel.Sink returned by `Sink.AsSink`.
+type sinkAdapter struct {
+	b *batcher
+}
+
+func (a *sinkAdapter) Write(event []byte) error {
+	record, err := mixpanel.DecodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	return a.b.add(record)
+}
+
+func (a *sinkAdapter) Flush() error {
+	return a.b.flush()
+}
+
+func (a *sinkAdapter) Close() error {
+	return nil
+}
+
+// row is a single event, ready to be inserted. It implements
+// `bigquery.ValueSaver`.
+type row struct {
+	values   map[string]bq.Value
+	insertID string
+}
+
+func (r *row) Save() (map[string]bq.Value, string, error) {
+	return r.values, r.insertID, nil
+}
+
+// newRow converts an event to a row, using its `$insert_id` (or failing that,
+// the ID mixport gave it) as the insert ID so that BigQuery can deduplicate
+// retried inserts.
+func (s *Sink) newRow(event mixpanel.EventData) *row {
+	r := &row{values: make(map[string]bq.Value, len(event))}
+
+	if id, ok := event[mixpanel.PropInsertID].(string); ok && id != "" {
+		r.insertID = id
+	} else if id, ok := event[mixpanel.EventIDKey].(string); ok {
+		r.insertID = id
+	}
+
+	claims := s.claimColumns(event)
+	extra := make(map[string]interface{})
+
+	for key, value := range event {
+		if key == mixpanel.EventIDKey {
+			continue
+		}
+
+		if column := ColumnName(key); claims[column] == key {
+			r.values[column] = columnValue(value)
+		} else {
+			extra[key] = value
+		}
+	}
+
+	if len(extra) > 0 {
+		data, _ := json.Marshal(extra)
+		r.values[s.opts.ExtraColumn] = string(data)
+	}
+
+	return r
+}
+
+// claimColumns works out which property of `event` goes in each column.
+//
+// Several properties can map to the same column (`$city` and `_city` are both
+// `_city`). The one named exactly like the column wins, or else the first in
+// sorted order, so that the choice doesn't depend on map order. The others
+// go in the extra column under their own names.
+func (s *Sink) claimColumns(event mixpanel.EventData) map[string]string {
+	claims := make(map[string]string, len(s.columns))
+
+	for key := range event {
+		if key == mixpanel.EventIDKey {
+			continue
+		}
+
+		column := ColumnName(key)
+		if !s.columns[column] {
+			continue
+		}
+
+		if current, ok := claims[column]; !ok || key == column || (current != column && key < current) {
+			claims[column] = key
+		}
+	}
+
+	return claims
+}
+
+// columnValue converts a decoded JSON value to something BigQuery will
+// accept. Nested values are stored as JSON strings.
+func columnValue(value interface{}) bq.Value {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,171 @@
+package bigquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	bq "cloud.google.com/go/bigquery"
+	"github.com/erik/mixport/mixpanel"
+)
+
+// fakeInserter records every batch put into it.
+type fakeInserter struct {
+	batches [][]*row
+	err     error
+}
+
+func (f *fakeInserter) Put(ctx context.Context, src interface{}) error {
+	f.batches = append(f.batches, src.([]*row))
+	return f.err
+}
+
+func TestSink(t *testing.T) {
+	inserter := &fakeInserter{}
+	sink := NewSinkWithInserter(inserter, Options{
+		Columns:   []string{"_insert_id", "event", "distinct_id", "time", "_city", "extra"},
+		BatchSize: 2,
+	})
+
+	in := make(chan mixpanel.EventData, 3)
+	for i := 0; i < 3; i++ {
+		in <- mixpanel.EventData{
+			mixpanel.EventIDKey: fmt.Sprintf("uuid%d", i),
+			"$insert_id":        fmt.Sprintf("insert%d", i),
+			"event":             "Signed Up",
+			"distinct_id":       "user",
+			"time":              json.Number("1388534400"),
+			"$city":             "Boston",
+			"plan":              "pro",
+		}
+	}
+	close(in)
+
+	if err := sink.Run(context.Background(), in); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if len(inserter.batches) != 2 || len(inserter.batches[0]) != 2 || len(inserter.batches[1]) != 1 {
+		t.Fatalf("expected batches of 2 and 1, got %v", inserter.batches)
+	}
+
+	i := 0
+	for _, batch := range inserter.batches {
+		for _, r := range batch {
+			values, insertID, err := r.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected := fmt.Sprintf("insert%d", i); insertID != expected {
+				t.Errorf("expected insertId %s, got %s", expected, insertID)
+			}
+
+			expected := map[string]bq.Value{
+				"_insert_id":  insertID,
+				"event":       "Signed Up",
+				"distinct_id": "user",
+				"time":        int64(1388534400),
+				"_city":       "Boston",
+				"extra":       `{"plan":"pro"}`,
+			}
+
+			if !reflect.DeepEqual(values, expected) {
+				t.Errorf("expected row %v, got %v", expected, values)
+			}
+
+			i++
+		}
+	}
+}
+
+func TestSinkInsertIDFallback(t *testing.T) {
+	sink := NewSinkWithInserter(&fakeInserter{}, Options{})
+
+	if r := sink.newRow(mixpanel.EventData{mixpanel.EventIDKey: "uuid"}); r.insertID != "uuid" {
+		t.Errorf("expected event ID to be used, got %q", r.insertID)
+	}
+}
+
+func TestSinkError(t *testing.T) {
+	failure := errors.New("quota exceeded")
+	sink := NewSinkWithInserter(&fakeInserter{err: failure}, Options{BatchSize: 1})
+
+	in := make(chan mixpanel.EventData, 3)
+	for i := 0; i < 3; i++ {
+		in <- mixpanel.EventData{"event": "a"}
+	}
+	close(in)
+
+	if err := sink.Run(context.Background(), in); !errors.Is(err, failure) {
+		t.Errorf("expected insert error, got %v", err)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for property, expected := range map[string]string{
+		"event":    "event",
+		"$city":    "_city",
+		"utm-tag":  "utm_tag",
+		"1st_seen": "_1st_seen",
+	} {
+		if got := ColumnName(property); got != expected {
+			t.Errorf("%s: expected %s, got %s", property, expected, got)
+		}
+	}
+}
+
+func TestSinkAsSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"n": %d}}`+"\n", i)
+		}
+	}))
+	defer server.Close()
+
+	inserter := &fakeInserter{}
+	sink := NewSinkWithInserter(inserter, Options{Columns: []string{"n"}, BatchSize: 2})
+
+	mix := mixpanel.NewWithURL("product", "key", "secret", server.URL)
+	if _, err := mix.ExportDateToSink(context.Background(), time.Now(), sink.AsSink(context.Background()), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if len(inserter.batches) != 2 || len(inserter.batches[0]) != 2 || len(inserter.batches[1]) != 1 {
+		t.Fatalf("expected batches of 2 and 1, got %v", inserter.batches)
+	}
+
+	values, _, _ := inserter.batches[1][0].Save()
+	if values["n"] != int64(2) {
+		t.Errorf("expected numbers to survive the round trip, got %#v", values["n"])
+	}
+}
+
+func TestSinkColumnCollision(t *testing.T) {
+	sink := NewSinkWithInserter(&fakeInserter{}, Options{Columns: []string{"_city", "a_b", "extra"}})
+
+	for i := 0; i < 20; i++ {
+		values, _, _ := sink.newRow(mixpanel.EventData{
+			"$city": "Boston",
+			"_city": "Cambridge",
+			"a-b":   "dash",
+			"a.b":   "dot",
+		}).Save()
+
+		expected := map[string]bq.Value{
+			"_city": "Cambridge",
+			"a_b":   "dash",
+			"extra": `{"$city":"Boston","a.b":"dot"}`,
+		}
+
+		if !reflect.DeepEqual(values, expected) {
+			t.Fatalf("expected row %v, got %v", expected, values)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/erik/mixport/mixpanel"
 	"io"
+	"time"
 )
 
 // CSVStreamer writes the records passed on the given chan in a schema-less
@@ -22,12 +23,25 @@ import (
 // common set of columns is a nonstarter because of the time and memory
 // requirements this requires.
 func CSVStreamer(w io.Writer, records <-chan mixpanel.EventData) {
+	csvStream(w, records, 0)
+}
+
+// FlushingCSVStreamer returns a `CSVStreamer` which also flushes its output
+// (including `w`, if it buffers) at least every `interval`, so that a crash
+// loses at most that much output. A zero `interval` never flushes early.
+func FlushingCSVStreamer(interval time.Duration) func(io.Writer, <-chan mixpanel.EventData) {
+	return func(w io.Writer, records <-chan mixpanel.EventData) {
+		csvStream(w, records, interval)
+	}
+}
+
+func csvStream(w io.Writer, records <-chan mixpanel.EventData, interval time.Duration) {
 	writer := csv.NewWriter(w)
 
 	// Write the header
 	writer.Write([]string{"event_id", "key", "value"})
 
-	for record := range records {
+	consume(records, interval, func(record mixpanel.EventData) {
 		id := record[mixpanel.EventIDKey].(string)
 
 		// Divide the given map up into lines of `id,key,value`
@@ -46,7 +60,10 @@ func CSVStreamer(w io.Writer, records <-chan mixpanel.EventData) {
 
 			writer.Write([]string{id, key, repr})
 		}
-	}
+	}, func() {
+		writer.Flush()
+		flushWriter(w)
+	})
 
 	writer.Flush()
 }
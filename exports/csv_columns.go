@@ -5,16 +5,22 @@ import (
 	"fmt"
 	"github.com/erik/mixport/mixpanel"
 	"io"
+	"time"
 )
 
 // EventColumnDef represents the definition of an event's CSV columns to be
 // passed on to the `CSVColumnStreamer` function.
 //
+// - `w` is the underlying writer, kept so it can be flushed too.
+// - `recorder`, if not nil, counts every row written towards the file's
+//   manifest.
 // - `columns` contains the names of the columns.
 // - `values` represents a row, in the same order as specified by
 //   `columns`. This is to avoid creating excessive garbage by allocating and
 //   destroying the array on each iteration.
 type EventColumnDef struct {
+	w               io.Writer
+	recorder        *ManifestRecorder
 	writer          *csv.Writer
 	columns, values []string
 }
@@ -24,10 +30,18 @@ type EventColumnDef struct {
 //
 // Columns in the output will be in the same order as they passed in here.
 func NewEventColumnDef(w io.Writer, columns []string) EventColumnDef {
+	return NewRecordedEventColumnDef(w, columns, nil)
+}
+
+// NewRecordedEventColumnDef is `NewEventColumnDef`, but also counting each
+// row written in `recorder`, so that the file's manifest has its events.
+func NewRecordedEventColumnDef(w io.Writer, columns []string, recorder *ManifestRecorder) EventColumnDef {
 	return EventColumnDef{
-		writer:  csv.NewWriter(w),
-		columns: columns,
-		values:  make([]string, len(columns)),
+		w:        w,
+		recorder: recorder,
+		writer:   csv.NewWriter(w),
+		columns:  columns,
+		values:   make([]string, len(columns)),
 	}
 }
 
@@ -42,13 +56,25 @@ func NewEventColumnDef(w io.Writer, columns []string) EventColumnDef {
 // EventColumnDefs. Any event received that is not in this map will simply be
 // dropped.
 func CSVColumnStreamer(defs map[string]EventColumnDef, records <-chan mixpanel.EventData) {
+	csvColumnStream(defs, records, 0)
+}
+
+// FlushingCSVColumnStreamer returns a `CSVColumnStreamer` which also flushes
+// the output of every event at least every `interval`. A zero `interval` never
+// flushes early.
+func FlushingCSVColumnStreamer(interval time.Duration) func(map[string]EventColumnDef, <-chan mixpanel.EventData) {
+	return func(defs map[string]EventColumnDef, records <-chan mixpanel.EventData) {
+		csvColumnStream(defs, records, interval)
+	}
+}
 
+func csvColumnStream(defs map[string]EventColumnDef, records <-chan mixpanel.EventData, interval time.Duration) {
 	for _, def := range defs {
 		// Write the column names as CSV header
 		def.writer.Write(def.columns)
 	}
 
-	for record := range records {
+	consume(records, interval, func(record mixpanel.EventData) {
 		event := record["event"].(string)
 
 		// We simply ignore events we don't have column definitions
@@ -65,8 +91,14 @@ func CSVColumnStreamer(defs map[string]EventColumnDef, records <-chan mixpanel.E
 			}
 
 			def.writer.Write(def.values)
+			def.recorder.count(record)
 		}
-	}
+	}, func() {
+		for _, def := range defs {
+			def.writer.Flush()
+			flushWriter(def.w)
+		}
+	})
 
 	// Flush any remaining buffered data to the underlying io.Writer
 	for _, def := range defs {
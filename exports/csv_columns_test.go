@@ -70,6 +70,33 @@ func TestCSVColumnStreamer(t *testing.T) {
 	}
 }
 
+func TestCSVColumnStreamerManifest(t *testing.T) {
+	var kept, other bytes.Buffer
+
+	recorder := NewManifestRecorder(&kept, "product", "20140101")
+	defs := map[string]EventColumnDef{
+		"kept":  NewRecordedEventColumnDef(recorder, []string{"a"}, recorder),
+		"other": NewEventColumnDef(&other, []string{"a"}),
+	}
+
+	records := make(chan mixpanel.EventData, 4)
+	for _, event := range []string{"kept", "other", "kept", "dropped"} {
+		records <- mixpanel.EventData{"event": event, "a": "x"}
+	}
+	close(records)
+
+	CSVColumnStreamer(defs, records)
+
+	manifest := recorder.Manifest()
+	if manifest.Events != 2 || manifest.EventCounts["kept"] != 2 || len(manifest.EventCounts) != 1 {
+		t.Errorf("expected 2 kept events, got %+v", manifest)
+	}
+
+	if manifest.Bytes != int64(kept.Len()) {
+		t.Errorf("expected %d bytes, got %d", kept.Len(), manifest.Bytes)
+	}
+}
+
 func BenchmarkCSVColumnStreamer(b *testing.B) {
 	columns := [][]string{
 		[]string{"a0", "b0", "c0", "d0"},
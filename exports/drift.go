@@ -0,0 +1,145 @@
+package exports
+
+import "sort"
+
+// DriftDetector compares the `Schema` of two exports (say, yesterday's and
+// today's) to spot changes in the shape of the data, such as an SDK upgrade
+// renaming a property or sending a number as a string.
+//
+// - `IgnoreNull` leaves null out of the types compared, since a property
+//   which is only sometimes null tends to flip between days without anything
+//   having changed.
+type DriftDetector struct {
+	IgnoreNull bool
+}
+
+// DriftReport lists the differences found by a `DriftDetector`, with every
+// list sorted.
+//
+// - `AddedEvents` and `RemovedEvents` are event types only seen in one of the
+//   schemas. Their properties aren't compared.
+// - `Events` holds the drift of each event type seen in both which has any.
+type DriftReport struct {
+	AddedEvents   []string              `json:"added_events,omitempty"`
+	RemovedEvents []string              `json:"removed_events,omitempty"`
+	Events        map[string]EventDrift `json:"events,omitempty"`
+}
+
+// EventDrift is the drift of a single event type.
+type EventDrift struct {
+	Added       []string     `json:"added,omitempty"`
+	Removed     []string     `json:"removed,omitempty"`
+	TypeChanges []TypeChange `json:"type_changes,omitempty"`
+}
+
+// TypeChange records a property whose set of types changed.
+type TypeChange struct {
+	Property string   `json:"property"`
+	Before   []string `json:"before"`
+	After    []string `json:"after"`
+}
+
+// Empty reports whether no drift was found.
+func (r DriftReport) Empty() bool {
+	return len(r.AddedEvents) == 0 && len(r.RemovedEvents) == 0 && len(r.Events) == 0
+}
+
+// Compare reports how `after` differs from `before`.
+func (d DriftDetector) Compare(before, after Schema) DriftReport {
+	report := DriftReport{Events: make(map[string]EventDrift)}
+
+	for event, afterProps := range after {
+		beforeProps, ok := before[event]
+		if !ok {
+			report.AddedEvents = append(report.AddedEvents, event)
+			continue
+		}
+
+		if drift, changed := d.compareEvent(beforeProps, afterProps); changed {
+			report.Events[event] = drift
+		}
+	}
+
+	for event := range before {
+		if _, ok := after[event]; !ok {
+			report.RemovedEvents = append(report.RemovedEvents, event)
+		}
+	}
+
+	sort.Strings(report.AddedEvents)
+	sort.Strings(report.RemovedEvents)
+
+	if len(report.Events) == 0 {
+		report.Events = nil
+	}
+
+	return report
+}
+
+// compareEvent compares the properties of an event type seen in both schemas.
+func (d DriftDetector) compareEvent(before, after map[string][]string) (EventDrift, bool) {
+	var drift EventDrift
+
+	for key, afterTypes := range after {
+		beforeTypes, ok := before[key]
+		if !ok {
+			drift.Added = append(drift.Added, key)
+			continue
+		}
+
+		beforeTypes, afterTypes = d.types(beforeTypes), d.types(afterTypes)
+
+		// A property that was only ever null (and is ignored) has no types
+		// to have changed from.
+		if d.IgnoreNull && (len(beforeTypes) == 0 || len(afterTypes) == 0) {
+			continue
+		}
+
+		if !sameTypes(beforeTypes, afterTypes) {
+			drift.TypeChanges = append(drift.TypeChanges, TypeChange{Property: key, Before: beforeTypes, After: afterTypes})
+		}
+	}
+
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			drift.Removed = append(drift.Removed, key)
+		}
+	}
+
+	sort.Strings(drift.Added)
+	sort.Strings(drift.Removed)
+	sort.Slice(drift.TypeChanges, func(i, j int) bool { return drift.TypeChanges[i].Property < drift.TypeChanges[j].Property })
+
+	return drift, len(drift.Added) > 0 || len(drift.Removed) > 0 || len(drift.TypeChanges) > 0
+}
+
+// types returns the types to compare, without null if it's ignored.
+func (d DriftDetector) types(types []string) []string {
+	if !d.IgnoreNull {
+		return types
+	}
+
+	kept := make([]string, 0, len(types))
+	for _, t := range types {
+		if t != TypeNull {
+			kept = append(kept, t)
+		}
+	}
+
+	return kept
+}
+
+// sameTypes compares two sorted lists of types.
+func sameTypes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,61 @@
+package exports
+
+import (
+	"encoding/json"
+	"github.com/erik/mixport/mixpanel"
+	"reflect"
+	"testing"
+)
+
+// schemaOf collects the schema of `records`.
+func schemaOf(records ...mixpanel.EventData) Schema {
+	collector := NewSchemaCollector()
+	for _, record := range records {
+		collector.Add(record)
+	}
+	return collector.Schema()
+}
+
+func TestDriftDetector(t *testing.T) {
+	yesterday := schemaOf(
+		mixpanel.EventData{"event": "purchase", "amount": json.Number("10"), "coupon": "x", "note": nil},
+		mixpanel.EventData{"event": "legacy"},
+	)
+
+	today := schemaOf(
+		mixpanel.EventData{"event": "purchase", "amount": "10", "currency": "USD", "note": "gift"},
+		mixpanel.EventData{"event": "signup"},
+	)
+
+	report := DriftDetector{}.Compare(yesterday, today)
+
+	expected := DriftReport{
+		AddedEvents:   []string{"signup"},
+		RemovedEvents: []string{"legacy"},
+		Events: map[string]EventDrift{
+			"purchase": {
+				Added:   []string{"currency"},
+				Removed: []string{"coupon"},
+				TypeChanges: []TypeChange{
+					{Property: "amount", Before: []string{TypeInt}, After: []string{TypeString}},
+					{Property: "note", Before: []string{TypeNull}, After: []string{TypeString}},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("expected %+v, got %+v", expected, report)
+	}
+
+	// Null flipping to a value isn't drift when ignored.
+	report = DriftDetector{IgnoreNull: true}.Compare(yesterday, today)
+	expectedChanges := []TypeChange{{Property: "amount", Before: []string{TypeInt}, After: []string{TypeString}}}
+	if changes := report.Events["purchase"].TypeChanges; !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("expected %+v, got %+v", expectedChanges, changes)
+	}
+
+	if report := (DriftDetector{}).Compare(today, today); !report.Empty() {
+		t.Errorf("expected no drift against itself, got %+v", report)
+	}
+}
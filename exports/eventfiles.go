@@ -0,0 +1,279 @@
+package exports
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/erik/mixport/mixpanel"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// unsafeFileChars matches anything in an event name we wouldn't want in a
+// file name.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// UniformCompression returns a `CompressionFor` policy applying the same
+// gzip `level` to every event type.
+func UniformCompression(level int) func(string) (int, bool) {
+	return func(string) (int, bool) {
+		return level, true
+	}
+}
+
+// EventFileSink writes records as JSON lines (like `JSONStreamer`) into one
+// file per event type, `Prefix-EVENT.json[.gz]`, under `Directory`. Characters
+// of the event name which don't belong in a file name are replaced by `_`, so
+// if two event names end up with the same file name, `Run` fails with
+// `ErrPathConflict` rather than mixing them up.
+//
+// `CompressionFor` picks the gzip level for each event type, or disables
+// compression for it, so that large event types can be compressed harder and
+// tiny ones not at all. Defaults to `UniformCompression(gzip.DefaultCompression)`.
+//
+// `MaxOpenFiles`, if positive, caps the number of files open at once. When
+// it's reached, the least recently written file is closed, to be reopened for
+// appending if another record of its event type comes along (see
+// `openFiles` on how this works with gzip).
+type EventFileSink struct {
+	Directory      string
+	Prefix         string
+	CompressionFor func(eventName string) (level int, enabled bool)
+	MaxOpenFiles   int
+}
+
+// eventFile is the file of one event type, which is suspended while `fp` is
+// nil.
+type eventFile struct {
+	name     string
+	path     string
+	release  func()
+	compress bool
+	level    int
+	fp       *os.File
+	gzip     *gzip.Writer
+	encoder  *json.Encoder
+}
+
+// setWriter points the file's encoder at `fp`, through a new gzip writer if
+// it's compressed.
+func (f *eventFile) setWriter(fp *os.File) error {
+	w := io.Writer(fp)
+
+	if f.compress {
+		gz, err := gzip.NewWriterLevel(fp, f.level)
+		if err != nil {
+			return err
+		}
+
+		f.gzip, w = gz, gz
+	}
+
+	f.fp = fp
+	f.encoder = json.NewEncoder(w)
+
+	return nil
+}
+
+func (f *eventFile) suspend() error {
+	var err error
+
+	if f.gzip != nil {
+		err = f.gzip.Close()
+	}
+
+	if cerr := f.fp.Close(); err == nil {
+		err = cerr
+	}
+
+	f.fp, f.gzip, f.encoder = nil, nil, nil
+
+	return err
+}
+
+func (f *eventFile) resume() error {
+	fp, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := f.setWriter(fp); err != nil {
+		fp.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (f *eventFile) close() error {
+	defer f.release()
+
+	if f.fp == nil {
+		return nil
+	}
+
+	if f.gzip != nil {
+		if err := f.gzip.Close(); err != nil {
+			f.fp.Close()
+			return err
+		}
+	}
+
+	return f.fp.Close()
+}
+
+// Run writes every record from `records` and returns the names of the files
+// written, in sorted order.
+func (s *EventFileSink) Run(records <-chan mixpanel.EventData) ([]string, error) {
+	w := s.newWriter()
+
+	var err error
+
+	for record := range records {
+		if err = w.write(record); err != nil {
+			// Don't leave the sender blocked.
+			for range records {
+			}
+
+			break
+		}
+	}
+
+	names, cerr := w.close()
+	if err == nil {
+		err = cerr
+	}
+
+	return names, err
+}
+
+// AsSink adapts the event file sink into a `mixpanel.Sink`, for use with
+// `ExportDateToSink`. The files are closed by `Flush`, or by `Close` if the
+// export failed.
+func (s *EventFileSink) AsSink() mixpanel.Sink {
+	return &eventFileSinkAdapter{w: s.newWriter()}
+}
+
+// eventFileWriter holds the files of one run of an `EventFileSink`.
+type eventFileWriter struct {
+	s     *EventFileSink
+	files map[string]*eventFile
+	open  *openFiles
+}
+
+func (s *EventFileSink) newWriter() *eventFileWriter {
+	return &eventFileWriter{s: s, files: make(map[string]*eventFile), open: newOpenFiles(s.MaxOpenFiles)}
+}
+
+// write adds a record to the file of its event type, opening (or reopening)
+// it if needed.
+func (w *eventFileWriter) write(record mixpanel.EventData) error {
+	event := fmt.Sprintf("%v", record["event"])
+	name := w.s.Prefix + "-" + unsafeFileChars.ReplaceAllString(event, "_") + ".json"
+
+	file := w.files[event]
+	if file == nil {
+		if err := w.open.makeRoom(); err != nil {
+			return err
+		}
+
+		var err error
+		if file, err = w.s.open(event, name); err != nil {
+			return err
+		}
+
+		w.files[event] = file
+		w.open.add(file)
+	} else if err := w.open.use(file); err != nil {
+		return err
+	}
+
+	return file.encoder.Encode(record)
+}
+
+// close closes every file, returning their names in sorted order.
+func (w *eventFileWriter) close() ([]string, error) {
+	var err error
+
+	names := make([]string, 0, len(w.files))
+	for event, file := range w.files {
+		w.open.remove(file)
+
+		if cerr := file.close(); err == nil {
+			err = cerr
+		}
+
+		names = append(names, file.name)
+		delete(w.files, event)
+	}
+
+	sort.Strings(names)
+
+	return names, err
+}
+
+// eventFileSinkAdapter is the Sink returned by `EventFileSink.AsSink`.
+type eventFileSinkAdapter struct {
+	w *eventFileWriter
+}
+
+func (a *eventFileSinkAdapter) Write(event []byte) error {
+	record, err := mixpanel.DecodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	return a.w.write(record)
+}
+
+func (a *eventFileSinkAdapter) Flush() error {
+	_, err := a.w.close()
+	return err
+}
+
+func (a *eventFileSinkAdapter) Close() error {
+	// Anything left open after a failed export still gets closed, but its
+	// errors won't tell the caller anything new.
+	a.w.close()
+	return nil
+}
+
+// open creates the file for `event`, named `name` plus any compression
+// suffix.
+func (s *EventFileSink) open(event, name string) (*eventFile, error) {
+	policy := s.CompressionFor
+	if policy == nil {
+		policy = UniformCompression(gzip.DefaultCompression)
+	}
+
+	level, compress := policy(event)
+	if compress {
+		name += ".gz"
+	}
+
+	filePath := path.Join(s.Directory, name)
+
+	release, err := claimPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := os.Create(filePath)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	file := &eventFile{name: name, path: filePath, release: release, compress: compress, level: level}
+
+	if err := file.setWriter(fp); err != nil {
+		fp.Close()
+		release()
+		return nil, err
+	}
+
+	return file, nil
+}
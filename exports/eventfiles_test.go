@@ -0,0 +1,226 @@
+package exports
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"github.com/erik/mixport/mixpanel"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEventFileSinkCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := &EventFileSink{
+		Directory: dir,
+		Prefix:    "day",
+		CompressionFor: func(event string) (int, bool) {
+			switch event {
+			case "huge":
+				return gzip.BestCompression, true
+			case "fast":
+				return gzip.BestSpeed, true
+			}
+			return 0, false
+		},
+	}
+
+	records := make(chan mixpanel.EventData, 6)
+	for _, event := range []string{"huge", "tiny", "huge", "fast", "tiny/../x", "huge"} {
+		records <- mixpanel.EventData{"event": event}
+	}
+	close(records)
+
+	names, err := sink.Run(records)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := []string{"day-fast.json.gz", "day-huge.json.gz", "day-tiny.json", "day-tiny_.._x.json"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected files %q, got %q", expected, names)
+	}
+
+	// The gzip header records the level used: 2 for best compression, 4
+	// for best speed.
+	for name, xfl := range map[string]byte{"day-huge.json.gz": 2, "day-fast.json.gz": 4} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		} else if len(data) < 10 || data[0] != 0x1f || data[1] != 0x8b {
+			t.Fatalf("%s: not gzipped", name)
+		} else if data[8] != xfl {
+			t.Errorf("%s: expected XFL %d, got %d", name, xfl, data[8])
+		}
+	}
+
+	if lines := countLines(t, filepath.Join(dir, "day-huge.json.gz"), true); lines != 3 {
+		t.Errorf("expected 3 huge events, got %d", lines)
+	}
+
+	if lines := countLines(t, filepath.Join(dir, "day-tiny.json"), false); lines != 1 {
+		t.Errorf("expected 1 tiny event, got %d", lines)
+	}
+}
+
+func TestEventFileSinkDefault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	records := make(chan mixpanel.EventData, 2)
+	records <- mixpanel.EventData{"event": "a"}
+	records <- mixpanel.EventData{"event": "b"}
+	close(records)
+
+	names, err := (&EventFileSink{Directory: dir, Prefix: "day"}).Run(records)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if !reflect.DeepEqual(names, []string{"day-a.json.gz", "day-b.json.gz"}) {
+		t.Errorf("expected every event compressed, got %q", names)
+	}
+}
+
+func countLines(t *testing.T, name string, gzipped bool) int {
+	fp, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	r := io.Reader(fp)
+	if gzipped {
+		if r, err = gzip.NewReader(fp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := 0
+	for scanner := bufio.NewScanner(r); scanner.Scan(); {
+		lines++
+	}
+
+	return lines
+}
+
+func TestEventFileSinkAsSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := (&EventFileSink{Directory: dir, Prefix: "day", CompressionFor: func(string) (int, bool) { return 0, false }}).AsSink()
+	for _, event := range []string{`{"event": "a"}`, `{"event": "b"}`, `{"event": "a"}`} {
+		if err := sink.Write([]byte(event)); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+	}
+
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if err := sink.Close(); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	for name, lines := range map[string]int{"day-a.json": 2, "day-b.json": 1} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		} else if n := strings.Count(string(data), "\n"); n != lines {
+			t.Errorf("%s: expected %d lines, got %d", name, lines, n)
+		}
+	}
+}
+
+func TestEventFileSinkMaxOpenFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := &EventFileSink{
+		Directory:    dir,
+		Prefix:       "day",
+		MaxOpenFiles: 2,
+		CompressionFor: func(event string) (int, bool) {
+			return gzip.DefaultCompression, event != "b"
+		},
+	}
+
+	w := sink.newWriter()
+
+	// Round robin over three event types, so every write after the first
+	// two has to reopen a file.
+	for i := 0; i < 9; i++ {
+		event := []string{"a", "b", "c"}[i%3]
+
+		if err := w.write(mixpanel.EventData{"event": event, "n": i}); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		open := 0
+		for _, file := range w.files {
+			if file.fp != nil {
+				open++
+			}
+		}
+
+		if open > 2 || w.open.order.Len() != open {
+			t.Fatalf("after %d writes: %d files open, %d tracked", i+1, open, w.open.order.Len())
+		}
+	}
+
+	names, err := w.close()
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := []string{"day-a.json.gz", "day-b.json", "day-c.json.gz"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected files %q, got %q", expected, names)
+	}
+
+	for i, name := range names {
+		fp, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fp.Close()
+
+		r := io.Reader(fp)
+		if strings.HasSuffix(name, ".gz") {
+			if r, err = gzip.NewReader(fp); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		event := []string{"a", "b", "c"}[i]
+		want := ""
+		for n := i; n < 9; n += 3 {
+			want += fmt.Sprintf(`{"event":"%s","n":%d}`+"\n", event, n)
+		}
+
+		if string(data) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, data)
+		}
+	}
+}
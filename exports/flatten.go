@@ -0,0 +1,95 @@
+package exports
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/erik/mixport/mixpanel"
+)
+
+// How `Flatten` handles array valued properties.
+const (
+	// ArraysAsJSON keeps each array as a single JSON encoded string.
+	ArraysAsJSON = iota
+
+	// ArraysIndexed spreads arrays over index suffixed keys, so `tags`
+	// becomes `tags_0`, `tags_1`, ...
+	ArraysIndexed
+)
+
+// Default limit on how deeply `Flatten` descends into nested properties.
+const DefaultFlattenDepth = 8
+
+// FlattenOptions controls `Flatten`.
+//
+// - `Separator` joins the keys of nested properties. Defaults to "_".
+// - `Arrays` is either `ArraysAsJSON` (the default) or `ArraysIndexed`.
+// - `MaxDepth` is the deepest level of nesting that is flattened, after which
+//   the rest of the value is kept as a JSON string. Defaults to
+//   `DefaultFlattenDepth`.
+type FlattenOptions struct {
+	Separator string
+	Arrays    int
+	MaxDepth  int
+}
+
+// Flatten returns a copy of `record` with nested objects (such as
+// `$properties`) and, depending on `opts`, arrays (such as `$elements`)
+// folded into top level keys, for exporters like CSV that only deal in
+// scalars.
+//
+// Input : `{"a": {"b": 1, "c": [1, 2]}}`
+// Output: `{"a_b": 1, "a_c": "[1,2]"}`, or with `ArraysIndexed`
+//         `{"a_b": 1, "a_c_0": 1, "a_c_1": 2}`
+//
+// Empty objects and arrays have nothing to fold in, so are kept under their
+// own key as `"{}"` and `"[]"`, so that the property doesn't vanish. A
+// flattened key which collides with an existing one is overwritten.
+func Flatten(record mixpanel.EventData, opts FlattenOptions) mixpanel.EventData {
+	if opts.Separator == "" {
+		opts.Separator = "_"
+	}
+
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultFlattenDepth
+	}
+
+	flat := make(mixpanel.EventData, len(record))
+
+	for key, value := range record {
+		flattenValue(flat, key, value, 1, opts)
+	}
+
+	return flat
+}
+
+func flattenValue(flat mixpanel.EventData, key string, value interface{}, depth int, opts FlattenOptions) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if depth > opts.MaxDepth || len(v) == 0 {
+			flat[key] = jsonString(v)
+			return
+		}
+
+		for k, nested := range v {
+			flattenValue(flat, key+opts.Separator+k, nested, depth+1, opts)
+		}
+	case []interface{}:
+		if opts.Arrays != ArraysIndexed || depth > opts.MaxDepth || len(v) == 0 {
+			flat[key] = jsonString(v)
+			return
+		}
+
+		for i, nested := range v {
+			flattenValue(flat, fmt.Sprintf("%s%s%d", key, opts.Separator, i), nested, depth+1, opts)
+		}
+	default:
+		flat[key] = value
+	}
+}
+
+// jsonString encodes `v` as a string of JSON, which can't fail for anything
+// that came out of the JSON decoder.
+func jsonString(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
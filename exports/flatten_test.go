@@ -0,0 +1,86 @@
+package exports
+
+import (
+	"encoding/json"
+	"github.com/erik/mixport/mixpanel"
+	"reflect"
+	"testing"
+)
+
+// decodeRecord parses a JSON object into EventData the same way the export
+// does.
+func decodeRecord(t *testing.T, s string) mixpanel.EventData {
+	var record mixpanel.EventData
+	if err := json.Unmarshal([]byte(s), &record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestFlattenArraysOfScalars(t *testing.T) {
+	record := decodeRecord(t, `{"event": "e", "tags": ["a", "b"]}`)
+
+	asJSON := Flatten(record, FlattenOptions{})
+	if expected := (mixpanel.EventData{"event": "e", "tags": `["a","b"]`}); !reflect.DeepEqual(asJSON, expected) {
+		t.Errorf("expected %v, got %v", expected, asJSON)
+	}
+
+	indexed := Flatten(record, FlattenOptions{Arrays: ArraysIndexed})
+	if expected := (mixpanel.EventData{"event": "e", "tags_0": "a", "tags_1": "b"}); !reflect.DeepEqual(indexed, expected) {
+		t.Errorf("expected %v, got %v", expected, indexed)
+	}
+}
+
+func TestFlattenArraysOfObjects(t *testing.T) {
+	record := decodeRecord(t, `{"$properties": {"plan": "pro"}, "$elements": [{"tag": "a"}, {"tag": "b", "ids": [1]}]}`)
+
+	indexed := Flatten(record, FlattenOptions{Arrays: ArraysIndexed, Separator: "."})
+	expected := mixpanel.EventData{
+		"$properties.plan":  "pro",
+		"$elements.0.tag":   "a",
+		"$elements.1.tag":   "b",
+		"$elements.1.ids.0": float64(1),
+	}
+
+	if !reflect.DeepEqual(indexed, expected) {
+		t.Errorf("expected %v, got %v", expected, indexed)
+	}
+
+	asJSON := Flatten(record, FlattenOptions{})
+	if got := asJSON["$elements"]; got != `[{"tag":"a"},{"ids":[1],"tag":"b"}]` {
+		t.Errorf("unexpected $elements: %v", got)
+	}
+}
+
+func TestFlattenMaxDepth(t *testing.T) {
+	record := decodeRecord(t, `{"a": {"b": {"c": {"d": 1}}, "l": [[1, 2]]}}`)
+
+	flat := Flatten(record, FlattenOptions{Arrays: ArraysIndexed, MaxDepth: 2})
+	expected := mixpanel.EventData{
+		"a_b_c": `{"d":1}`,
+		"a_l_0": `[1,2]`,
+	}
+
+	if !reflect.DeepEqual(flat, expected) {
+		t.Errorf("expected %v, got %v", expected, flat)
+	}
+}
+
+func TestFlattenEmpty(t *testing.T) {
+	record := decodeRecord(t, `{"event": "e", "props": {}, "tags": [], "nested": {"obj": {}, "list": []}}`)
+
+	for _, arrays := range []int{ArraysAsJSON, ArraysIndexed} {
+		flat := Flatten(record, FlattenOptions{Arrays: arrays})
+		expected := mixpanel.EventData{
+			"event":       "e",
+			"props":       "{}",
+			"tags":        "[]",
+			"nested_obj":  "{}",
+			"nested_list": "[]",
+		}
+
+		if !reflect.DeepEqual(flat, expected) {
+			t.Errorf("arrays=%d: expected %v, got %v", arrays, expected, flat)
+		}
+	}
+}
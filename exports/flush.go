@@ -0,0 +1,49 @@
+package exports
+
+import (
+	"github.com/erik/mixport/mixpanel"
+	"io"
+	"time"
+)
+
+// flusher is implemented by writers which buffer their output, such as
+// `*gzip.Writer` and `*bufio.Writer`.
+type flusher interface {
+	Flush() error
+}
+
+// flushWriter pushes out anything buffered by `w`, if it buffers at all.
+func flushWriter(w io.Writer) {
+	if f, ok := w.(flusher); ok {
+		f.Flush()
+	}
+}
+
+// consume calls `each` with every record until `records` is closed. If
+// `interval` is positive, `flush` is also called whenever that long passes,
+// from the same goroutine, so it needs no extra synchronization.
+func consume(records <-chan mixpanel.EventData, interval time.Duration, each func(mixpanel.EventData), flush func()) {
+	if interval <= 0 {
+		for record := range records {
+			each(record)
+		}
+
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				return
+			}
+
+			each(record)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package exports
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/erik/mixport/mixpanel"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer which can be read while it's being written.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// waitFor polls until `buf` contains `want`, or gives up after a second.
+func waitFor(buf *syncBuffer, want string) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if strings.Contains(buf.String(), want) {
+			return true
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return false
+}
+
+func TestFlushingStreamers(t *testing.T) {
+	streamers := map[string]func(io.Writer, <-chan mixpanel.EventData){
+		"csv":  FlushingCSVStreamer(10 * time.Millisecond),
+		"json": FlushingJSONStreamer(10 * time.Millisecond),
+	}
+
+	for name, streamer := range streamers {
+		var out syncBuffer
+
+		// Buffered well beyond the size of the output, so nothing
+		// reaches `out` unless it's flushed.
+		w := bufio.NewWriterSize(&out, 1<<16)
+
+		records := make(chan mixpanel.EventData)
+		done := make(chan struct{})
+
+		go func() {
+			streamer(w, records)
+			close(done)
+		}()
+
+		records <- mixpanel.EventData{mixpanel.EventIDKey: "id", "foo": "persisted"}
+
+		// The stream is still open, so only a periodic flush can have
+		// written this out.
+		if !waitFor(&out, "persisted") {
+			t.Errorf("%s: expected output to be flushed before the stream completed", name)
+		}
+
+		close(records)
+		<-done
+	}
+}
+
+func TestFlushingCSVColumnStreamer(t *testing.T) {
+	var out syncBuffer
+
+	defs := map[string]EventColumnDef{
+		"a": NewEventColumnDef(&out, []string{"foo"}),
+	}
+
+	records := make(chan mixpanel.EventData)
+	done := make(chan struct{})
+
+	go func() {
+		FlushingCSVColumnStreamer(10*time.Millisecond)(defs, records)
+		close(done)
+	}()
+
+	records <- mixpanel.EventData{"event": "a", "foo": "persisted"}
+
+	if !waitFor(&out, "persisted") {
+		t.Error("expected output to be flushed before the stream completed")
+	}
+
+	close(records)
+	<-done
+}
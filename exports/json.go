@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/erik/mixport/mixpanel"
 	"io"
+	"time"
 )
 
 // JSONStreamer writes records to an io.Writer in JSON format line by line,
@@ -12,9 +13,24 @@ import (
 // Format is simply: `{"key": "value", ...}`. `value` is usually scalar, but
 // can be any valid JSON type.
 func JSONStreamer(w io.Writer, records <-chan mixpanel.EventData) {
+	jsonStream(w, records, 0)
+}
+
+// FlushingJSONStreamer returns a `JSONStreamer` which also flushes `w` (if it
+// buffers, like a `*gzip.Writer`) at least every `interval`, so that a crash
+// loses at most that much output. A zero `interval` never flushes early.
+func FlushingJSONStreamer(interval time.Duration) func(io.Writer, <-chan mixpanel.EventData) {
+	return func(w io.Writer, records <-chan mixpanel.EventData) {
+		jsonStream(w, records, interval)
+	}
+}
+
+func jsonStream(w io.Writer, records <-chan mixpanel.EventData, interval time.Duration) {
 	encoder := json.NewEncoder(w)
 
-	for record := range records {
+	consume(records, interval, func(record mixpanel.EventData) {
 		encoder.Encode(record)
-	}
+	}, func() {
+		flushWriter(w)
+	})
 }
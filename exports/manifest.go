@@ -0,0 +1,131 @@
+package exports
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/erik/mixport/mixpanel"
+	"hash"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Manifest describes a completed export file, and is written alongside it
+// for auditing.
+//
+// - `Events` and `EventCounts` are the total number of records written and
+//   the number of each event type.
+// - `Bytes` and `SHA256` describe the file exactly as written to disk (so
+//   after compression, if any).
+type Manifest struct {
+	Product         string         `json:"product"`
+	Date            string         `json:"date"`
+	Events          int            `json:"events"`
+	EventCounts     map[string]int `json:"event_counts"`
+	Bytes           int64          `json:"bytes"`
+	SHA256          string         `json:"sha256"`
+	DurationSeconds float64        `json:"duration_seconds"`
+}
+
+// ManifestRecorder builds the `Manifest` of an export as it is written. It
+// is an io.Writer which hashes and counts everything written through it to
+// the underlying writer, and `Count` tallies up the records.
+//
+// A nil ManifestRecorder records nothing.
+type ManifestRecorder struct {
+	w     io.Writer
+	hash  hash.Hash
+	start time.Time
+
+	mu       sync.Mutex
+	manifest Manifest
+}
+
+// NewManifestRecorder creates a recorder writing through to `w`. `date` is
+// however the export's date (or range) is labeled.
+func NewManifestRecorder(w io.Writer, product, date string) *ManifestRecorder {
+	return &ManifestRecorder{
+		w:     w,
+		hash:  sha256.New(),
+		start: time.Now(),
+		manifest: Manifest{
+			Product:     product,
+			Date:        date,
+			EventCounts: make(map[string]int),
+		},
+	}
+}
+
+func (r *ManifestRecorder) Write(p []byte) (int, error) {
+	n, err := r.w.Write(p)
+
+	r.mu.Lock()
+	r.hash.Write(p[:n])
+	r.manifest.Bytes += int64(n)
+	r.mu.Unlock()
+
+	return n, err
+}
+
+// Count passes every record from `records` through to the returned channel,
+// counting them on the way.
+func (r *ManifestRecorder) Count(records <-chan mixpanel.EventData) <-chan mixpanel.EventData {
+	if r == nil {
+		return records
+	}
+
+	counted := make(chan mixpanel.EventData, 100)
+
+	go func() {
+		defer close(counted)
+
+		for record := range records {
+			r.count(record)
+			counted <- record
+		}
+	}()
+
+	return counted
+}
+
+// count adds a single record to the manifest.
+func (r *ManifestRecorder) count(record mixpanel.EventData) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.manifest.Events++
+	r.manifest.EventCounts[fmt.Sprintf("%v", record["event"])]++
+}
+
+// Manifest returns the manifest of everything recorded so far.
+func (r *ManifestRecorder) Manifest() Manifest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	manifest := r.manifest
+	manifest.SHA256 = fmt.Sprintf("%x", r.hash.Sum(nil))
+	manifest.DurationSeconds = time.Since(r.start).Seconds()
+
+	manifest.EventCounts = make(map[string]int, len(r.manifest.EventCounts))
+	for event, count := range r.manifest.EventCounts {
+		manifest.EventCounts[event] = count
+	}
+
+	return manifest
+}
+
+// WriteFile writes the manifest out as JSON to `path`.
+func (r *ManifestRecorder) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r.Manifest(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
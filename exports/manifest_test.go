@@ -0,0 +1,63 @@
+package exports
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/erik/mixport/mixpanel"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestRecorder(t *testing.T) {
+	var output bytes.Buffer
+	recorder := NewManifestRecorder(&output, "product", "20140101")
+
+	records := make(chan mixpanel.EventData, 3)
+	for i, event := range []string{"a", "b", "a"} {
+		records <- mixpanel.EventData{mixpanel.EventIDKey: fmt.Sprintf("%d", i), "event": event}
+	}
+	close(records)
+
+	JSONStreamer(recorder, recorder.Count(records))
+
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "manifest.json")
+	if err := recorder.WriteFile(path); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+
+	if manifest.Product != "product" || manifest.Date != "20140101" {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+
+	if manifest.Events != 3 || manifest.EventCounts["a"] != 2 || manifest.EventCounts["b"] != 1 {
+		t.Errorf("expected counts to match events, got %+v", manifest)
+	}
+
+	if manifest.Bytes != int64(output.Len()) {
+		t.Errorf("expected %d bytes, got %d", output.Len(), manifest.Bytes)
+	}
+
+	if sum := fmt.Sprintf("%x", sha256.Sum256(output.Bytes())); manifest.SHA256 != sum {
+		t.Errorf("expected checksum %s, got %s", sum, manifest.SHA256)
+	}
+}
@@ -0,0 +1,167 @@
+// Package msgpack transcodes exported Mixpanel events to length prefixed
+// MessagePack records, which are more compact than JSON for passing between
+// services.
+//
+// It lives in its own package so that the MessagePack library is only pulled
+// in by programs that actually use it.
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	mp "github.com/vmihailenco/msgpack/v5"
+)
+
+// MaxRecordSize is the largest record a `Reader` will accept, to guard
+// against reading garbage as a huge length prefix.
+const MaxRecordSize = 64 * 1024 * 1024
+
+// ErrRecordTooLarge is returned by `Reader.Next` for a record longer than
+// `MaxRecordSize`.
+var ErrRecordTooLarge = errors.New("msgpack record exceeds the maximum size")
+
+// Sink is a `mixpanel.Sink` which re-encodes each JSON event as a MessagePack
+// map, writing it to an io.Writer framed by its length as a 4 byte, big
+// endian prefix. Use a `Reader` to read the records back.
+//
+// JSON numbers become integers where they are whole numbers that fit in an
+// int64, and floats otherwise.
+type Sink struct {
+	w   io.Writer
+	buf *bufio.Writer
+	enc *mp.Encoder
+	rec bytes.Buffer
+}
+
+// NewSink creates a Sink writing to `w`. Output is buffered until `Flush`,
+// and `Close` closes `w` if it is an io.Closer.
+func NewSink(w io.Writer) *Sink {
+	s := &Sink{w: w, buf: bufio.NewWriter(w)}
+	s.enc = mp.NewEncoder(&s.rec)
+
+	// Sorted keys, so identical events produce identical records.
+	s.enc.SetSortMapKeys(true)
+
+	return s
+}
+
+// Write transcodes a single JSON encoded event.
+func (s *Sink) Write(event []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+
+	var data map[string]interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return fmt.Errorf("msgpack: decoding event failed: %w", err)
+	}
+
+	s.rec.Reset()
+	if err := s.enc.Encode(numbers(data)); err != nil {
+		return fmt.Errorf("msgpack: encoding event failed: %w", err)
+	}
+
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(s.rec.Len()))
+
+	if _, err := s.buf.Write(prefix[:]); err != nil {
+		return err
+	}
+
+	_, err := s.buf.Write(s.rec.Bytes())
+	return err
+}
+
+// Flush writes out any buffered records.
+func (s *Sink) Flush() error {
+	return s.buf.Flush()
+}
+
+// Close closes the underlying writer, if it can be. It doesn't flush.
+func (s *Sink) Close() error {
+	if closer, ok := s.w.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// numbers replaces every json.Number in `v` with an int64 or float64, so
+// they are encoded as MessagePack numbers rather than strings.
+func numbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+
+		return t.String()
+	case map[string]interface{}:
+		for k, elem := range t {
+			t[k] = numbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range t {
+			t[i] = numbers(elem)
+		}
+	}
+
+	return v
+}
+
+// Reader reads back the records written by a `Sink`.
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewReader creates a Reader reading records from `r`.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next reads the next record. Integers are decoded as int64 (or uint64),
+// and floats as float64. Returns io.EOF once there are no more records, and
+// io.ErrUnexpectedEOF if the last one is truncated.
+func (r *Reader) Next() (map[string]interface{}, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r.r, prefix[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(prefix[:])
+	if size > MaxRecordSize {
+		return nil, ErrRecordTooLarge
+	}
+
+	if cap(r.buf) < int(size) {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	decoder := mp.NewDecoder(bytes.NewReader(r.buf))
+	decoder.UseLooseInterfaceDecoding(true)
+
+	var record map[string]interface{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, fmt.Errorf("msgpack: decoding record failed: %w", err)
+	}
+
+	return record, nil
+}
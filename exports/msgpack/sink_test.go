@@ -0,0 +1,107 @@
+package msgpack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erik/mixport/mixpanel"
+)
+
+func TestRoundTrip(t *testing.T) {
+	events := []string{
+		`{"event": "a", "n": 1, "f": 1.5, "big": -9000000000, "s": "x", "ok": true, "nil": null}`,
+		`{"event": "b", "list": [1, "two", 3.25], "nested": {"k": {"v": 2}}}`,
+		`{}`,
+	}
+
+	expected := []map[string]interface{}{
+		{"event": "a", "n": int64(1), "f": 1.5, "big": int64(-9000000000), "s": "x", "ok": true, "nil": nil},
+		{"event": "b", "list": []interface{}{int64(1), "two", 3.25}, "nested": map[string]interface{}{"k": map[string]interface{}{"v": int64(2)}}},
+		{},
+	}
+
+	var buf bytes.Buffer
+	sink := NewSink(&buf)
+
+	for _, event := range events {
+		if err := sink.Write([]byte(event)); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+	}
+
+	if buf.Len() != 0 {
+		t.Error("expected output to be buffered until flushed")
+	}
+
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	} else if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewReader(&buf)
+
+	for i, want := range expected {
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatalf("record %d: raised error: %v", i, err)
+		}
+
+		if !reflect.DeepEqual(record, want) {
+			t.Errorf("record %d: expected %#v, got %#v", i, want, record)
+		}
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSink(&buf)
+	sink.Write([]byte(`{"event": "a"}`))
+	sink.Flush()
+
+	data := buf.Bytes()
+	if _, err := NewReader(bytes.NewReader(data[:len(data)-1])).Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected ErrUnexpectedEOF, got %v", err)
+	}
+
+	if _, err := NewReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff})).Next(); err != ErrRecordTooLarge {
+		t.Errorf("expected ErrRecordTooLarge, got %v", err)
+	}
+}
+
+func TestExportToSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"i": %d}}`+"\n", i)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	mix := mixpanel.NewWithURL("product", "key", "secret", server.URL)
+
+	if _, err := mix.ExportDateToSink(context.Background(), time.Now(), NewSink(&buf), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	reader := NewReader(&buf)
+	for i := int64(0); i < 3; i++ {
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		} else if record["i"] != i || record["product"] != "product" {
+			t.Errorf("unexpected record %v", record)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package exports
+
+import "container/list"
+
+// suspendable is a file which can be closed while it's not being written and
+// reopened, in append mode, when it is again.
+type suspendable interface {
+	suspend() error
+	resume() error
+}
+
+// openFiles limits how many of a sink's files are open at once, for
+// `MaxOpenFiles`, by suspending the least recently written file when another
+// one needs to be opened. With a non-positive `max` nothing is ever
+// suspended.
+//
+// Gzipped files are suspended by finishing the current gzip member, and a new
+// member is started on resuming. A file of concatenated members is still a
+// valid gzip file, which `gzip -d` and Go's `gzip.Reader` read as one.
+type openFiles struct {
+	max   int
+	order *list.List
+	elems map[suspendable]*list.Element
+}
+
+func newOpenFiles(max int) *openFiles {
+	return &openFiles{max: max, order: list.New(), elems: make(map[suspendable]*list.Element)}
+}
+
+// makeRoom suspends files until another can be opened without going over
+// the limit.
+func (o *openFiles) makeRoom() error {
+	if o.max <= 0 {
+		return nil
+	}
+
+	for o.order.Len() >= o.max {
+		oldest := o.order.Back()
+		f := o.order.Remove(oldest).(suspendable)
+		delete(o.elems, f)
+
+		if err := f.suspend(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// add registers a newly opened file, which `makeRoom` must have made room
+// for.
+func (o *openFiles) add(f suspendable) {
+	if o.max > 0 {
+		o.elems[f] = o.order.PushFront(f)
+	}
+}
+
+// use marks `f` as about to be written, resuming it first if it was
+// suspended.
+func (o *openFiles) use(f suspendable) error {
+	if o.max <= 0 {
+		return nil
+	}
+
+	if e, ok := o.elems[f]; ok {
+		o.order.MoveToFront(e)
+		return nil
+	}
+
+	if err := o.makeRoom(); err != nil {
+		return err
+	}
+
+	if err := f.resume(); err != nil {
+		return err
+	}
+
+	o.add(f)
+	return nil
+}
+
+// remove forgets about a file that has been closed for good.
+func (o *openFiles) remove(f suspendable) {
+	if e, ok := o.elems[f]; ok {
+		o.order.Remove(e)
+		delete(o.elems, f)
+	}
+}
@@ -0,0 +1,388 @@
+package exports
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/erik/mixport/mixpanel"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// HourOutOfRange is the bucket of an hourly partitioned `PartitionedSink`
+// receiving events without a usable `time`, or from outside of `Date`.
+const HourOutOfRange = "out_of_range"
+
+// hourLayout names the bucket of each hour of an hourly partitioned
+// `PartitionedSink`.
+const hourLayout = "2006-01-02-15"
+
+// PartManifest is the manifest of one part file written by a
+// `PartitionedSink`.
+type PartManifest struct {
+	File string `json:"file"`
+	Manifest
+}
+
+// PartitionedSink writes records as JSON lines (like `JSONStreamer`) into a
+// series of size bounded part files, `Prefix-00001.json[.gz]`,
+// `Prefix-00002.json[.gz]`, ... under `Directory`.
+//
+// A part is closed and synced to disk as soon as its uncompressed size
+// reaches `MaxPartBytes`, so every part other than the last one being
+// written is complete and loadable on its own, even if the run dies part way
+// through. Zero `MaxPartBytes` writes a single part.
+//
+// Once `records` is drained, a `Prefix.manifest.json` listing the manifest of
+// each part is written alongside them. `Product` and `Date` label the parts'
+// manifests. If another sink in this process is already writing one of the
+// part files, `Run` fails with `ErrPathConflict`.
+//
+// With `HourlyPartition`, each record is instead routed by its (UTC) `time`
+// property to its own series of parts per hour, `Prefix-2006-01-02-15-00001...`.
+// If `Date` parses as `2006-01-02` or `20060102`, records from outside that
+// day go to the `Prefix-out_of_range-...` parts, along with any records
+// without a usable `time`.
+//
+// `MaxOpenFiles`, if positive, caps the number of parts open at once, as with
+// `EventFileSink`. Only hourly partitioning has more than one part open.
+type PartitionedSink struct {
+	Directory       string
+	Prefix          string
+	Product         string
+	Date            string
+	Gzip            bool
+	MaxPartBytes    int64
+	HourlyPartition bool
+	MaxOpenFiles    int
+}
+
+// part is the part file currently being written, which is suspended while
+// `fp` is nil.
+type part struct {
+	name     string
+	path     string
+	release  func()
+	compress bool
+	fp       *os.File
+	gzip     *gzip.Writer
+	recorder *ManifestRecorder
+	encoder  *json.Encoder
+	written  int64
+}
+
+func (p *part) suspend() error {
+	var err error
+
+	if p.gzip != nil {
+		err = p.gzip.Close()
+	}
+
+	if cerr := p.fp.Close(); err == nil {
+		err = cerr
+	}
+
+	p.fp, p.gzip = nil, nil
+
+	return err
+}
+
+func (p *part) resume() error {
+	fp, err := os.OpenFile(p.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	p.fp = fp
+	p.recorder.w = fp
+
+	if p.compress {
+		p.gzip = gzip.NewWriter(p.recorder)
+	}
+
+	return nil
+}
+
+func (p *part) Write(data []byte) (int, error) {
+	var (
+		n   int
+		err error
+	)
+
+	if p.gzip != nil {
+		n, err = p.gzip.Write(data)
+	} else {
+		n, err = p.recorder.Write(data)
+	}
+
+	p.written += int64(n)
+	return n, err
+}
+
+// bucket is the series of parts records are routed to, one for the whole
+// sink or one per hour.
+type bucket struct {
+	prefix  string
+	num     int
+	current *part
+}
+
+// Run writes every record from `records` and returns the manifests of the
+// parts written, ordered by file name.
+func (s *PartitionedSink) Run(records <-chan mixpanel.EventData) ([]PartManifest, error) {
+	w := s.newWriter()
+
+	for record := range records {
+		if err := w.write(record); err != nil {
+			w.abort()
+
+			// Don't leave the sender blocked.
+			for range records {
+			}
+
+			return w.parts, err
+		}
+	}
+
+	return w.finish()
+}
+
+// AsSink adapts the partitioned sink into a `mixpanel.Sink`, for use with
+// `ExportDateToSink`. `Flush` closes the last parts and writes the manifest,
+// while a `Close` without a `Flush` first (a failed export) just closes the
+// open parts, leaving no manifest behind.
+func (s *PartitionedSink) AsSink() mixpanel.Sink {
+	return &partitionedSinkAdapter{w: s.newWriter()}
+}
+
+// partitionWriter holds the state of one run of a `PartitionedSink`.
+type partitionWriter struct {
+	s       *PartitionedSink
+	parts   []PartManifest
+	buckets map[string]*bucket
+	open    *openFiles
+	day     time.Time
+	hasDay  bool
+}
+
+func (s *PartitionedSink) newWriter() *partitionWriter {
+	day, hasDay := s.day()
+
+	return &partitionWriter{
+		s:       s,
+		buckets: make(map[string]*bucket),
+		open:    newOpenFiles(s.MaxOpenFiles),
+		day:     day,
+		hasDay:  hasDay,
+	}
+}
+
+// write adds a record to its bucket's current part, opening a new part if
+// needed and closing it once it's full.
+func (w *partitionWriter) write(record mixpanel.EventData) error {
+	key := w.s.Prefix
+	if w.s.HourlyPartition {
+		key = w.s.Prefix + "-" + hourBucket(record, w.day, w.hasDay)
+	}
+
+	b := w.buckets[key]
+	if b == nil {
+		b = &bucket{prefix: key}
+		w.buckets[key] = b
+	}
+
+	if b.current == nil {
+		if err := w.open.makeRoom(); err != nil {
+			return err
+		}
+
+		var err error
+
+		b.num++
+		if b.current, err = w.s.openPart(b.prefix, b.num); err != nil {
+			return err
+		}
+
+		w.open.add(b.current)
+	} else if err := w.open.use(b.current); err != nil {
+		return err
+	}
+
+	if err := b.current.encoder.Encode(record); err != nil {
+		return err
+	}
+
+	b.current.recorder.count(record)
+
+	if w.s.MaxPartBytes > 0 && b.current.written >= w.s.MaxPartBytes {
+		w.open.remove(b.current)
+
+		err := w.s.closePart(b.current, &w.parts)
+		b.current = nil
+
+		return err
+	}
+
+	return nil
+}
+
+// abort closes the parts still open, without finishing them.
+func (w *partitionWriter) abort() {
+	for _, b := range w.buckets {
+		if b.current != nil {
+			w.open.remove(b.current)
+
+			if b.current.fp != nil {
+				b.current.fp.Close()
+			}
+
+			b.current.release()
+			b.current = nil
+		}
+	}
+}
+
+// finish closes the parts still open and writes the manifest.
+func (w *partitionWriter) finish() ([]PartManifest, error) {
+	for _, b := range w.buckets {
+		if b.current != nil {
+			// A suspended part has to be reopened to be synced.
+			err := w.open.use(b.current)
+			if err == nil {
+				w.open.remove(b.current)
+				err = w.s.closePart(b.current, &w.parts)
+				b.current = nil
+			}
+
+			if err != nil {
+				w.abort()
+				return w.parts, err
+			}
+		}
+	}
+
+	sort.Slice(w.parts, func(i, j int) bool { return w.parts[i].File < w.parts[j].File })
+
+	return w.parts, w.s.writeManifest(w.parts)
+}
+
+// partitionedSinkAdapter is the Sink returned by `PartitionedSink.AsSink`.
+type partitionedSinkAdapter struct {
+	w        *partitionWriter
+	finished bool
+}
+
+func (a *partitionedSinkAdapter) Write(event []byte) error {
+	record, err := mixpanel.DecodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	return a.w.write(record)
+}
+
+func (a *partitionedSinkAdapter) Flush() error {
+	a.finished = true
+
+	_, err := a.w.finish()
+	return err
+}
+
+func (a *partitionedSinkAdapter) Close() error {
+	if !a.finished {
+		a.w.abort()
+	}
+
+	return nil
+}
+
+// day parses `Date`, if it is a day that hourly partitions should fall in.
+func (s *PartitionedSink) day() (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if day, err := time.Parse(layout, s.Date); err == nil {
+			return day, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// hourBucket names the hour `record` happened in, or `HourOutOfRange`.
+func hourBucket(record mixpanel.EventData, day time.Time, hasDay bool) string {
+	t, ok := mixpanel.EventTime(record)
+	if !ok || (hasDay && (t.Before(day) || !t.Before(day.AddDate(0, 0, 1)))) {
+		return HourOutOfRange
+	}
+
+	return t.Format(hourLayout)
+}
+
+func (s *PartitionedSink) openPart(prefix string, n int) (*part, error) {
+	name := fmt.Sprintf("%s-%05d.json", prefix, n)
+	if s.Gzip {
+		name += ".gz"
+	}
+
+	partPath := path.Join(s.Directory, name)
+
+	release, err := claimPath(partPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := os.Create(partPath)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	p := &part{name: name, path: partPath, release: release, compress: s.Gzip, fp: fp, recorder: NewManifestRecorder(fp, s.Product, s.Date)}
+
+	if s.Gzip {
+		p.gzip = gzip.NewWriter(p.recorder)
+	}
+
+	p.encoder = json.NewEncoder(p)
+
+	return p, nil
+}
+
+// closePart finishes off a part, making sure it's on disk before it's
+// counted as complete.
+func (s *PartitionedSink) closePart(p *part, parts *[]PartManifest) error {
+	defer p.release()
+
+	if p.gzip != nil {
+		if err := p.gzip.Close(); err != nil {
+			p.fp.Close()
+			return err
+		}
+	}
+
+	if err := p.fp.Sync(); err != nil {
+		p.fp.Close()
+		return err
+	}
+
+	if err := p.fp.Close(); err != nil {
+		return err
+	}
+
+	*parts = append(*parts, PartManifest{File: p.name, Manifest: p.recorder.Manifest()})
+	return nil
+}
+
+func (s *PartitionedSink) writeManifest(parts []PartManifest) error {
+	data, err := json.MarshalIndent(struct {
+		Parts []PartManifest `json:"parts"`
+	}{parts}, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path.Join(s.Directory, s.Prefix+".manifest.json"), append(data, '\n'), 0644)
+}
@@ -0,0 +1,307 @@
+package exports
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/erik/mixport/mixpanel"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPartitionedSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := &PartitionedSink{
+		Directory:    dir,
+		Prefix:       "day",
+		Product:      "product",
+		Date:         "20140101",
+		Gzip:         true,
+		MaxPartBytes: 100,
+	}
+
+	records := make(chan mixpanel.EventData, 10)
+	for i := 0; i < 10; i++ {
+		records <- mixpanel.EventData{mixpanel.EventIDKey: fmt.Sprintf("%d", i), "event": "a", "padding": "xxxxxxxxxxxxxxxx"}
+	}
+	close(records)
+
+	parts, err := sink.Run(records)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+
+	total := 0
+
+	for i, part := range parts {
+		if expected := fmt.Sprintf("day-%05d.json.gz", i+1); part.File != expected {
+			t.Errorf("expected part %s, got %s", expected, part.File)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, part.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sum := fmt.Sprintf("%x", sha256.Sum256(data)); sum != part.SHA256 {
+			t.Errorf("%s: checksum mismatch", part.File)
+		}
+
+		// Each part must be a complete gzip stream on its own.
+		fp, _ := os.Open(filepath.Join(dir, part.File))
+		gz, err := gzip.NewReader(fp)
+		if err != nil {
+			t.Fatalf("%s: %v", part.File, err)
+		}
+
+		lines := 0
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			var record map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Errorf("%s: invalid line: %v", part.File, err)
+			}
+			lines++
+		}
+
+		if err := scanner.Err(); err != nil {
+			t.Errorf("%s: %v", part.File, err)
+		}
+		fp.Close()
+
+		if lines != part.Events {
+			t.Errorf("%s: manifest says %d events, found %d", part.File, part.Events, lines)
+		}
+
+		total += lines
+	}
+
+	if total != 10 {
+		t.Errorf("expected 10 records across all parts, got %d", total)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "day.manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest struct{ Parts []PartManifest }
+	if err := json.Unmarshal(data, &manifest); err != nil || len(manifest.Parts) != len(parts) {
+		t.Errorf("expected manifest of %d parts, got %s (%v)", len(parts), data, err)
+	}
+}
+
+func TestPartitionedSinkHourly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := &PartitionedSink{
+		Directory:       dir,
+		Prefix:          "day",
+		Product:         "product",
+		Date:            "2014-01-01",
+		HourlyPartition: true,
+	}
+
+	// 2014-01-01T00:00:00Z
+	const midnight = 1388534400
+
+	times := []interface{}{
+		int64(midnight),
+		int64(midnight + 59*60),
+		int64(midnight + 3600),
+		json.Number(fmt.Sprintf("%d", midnight+5*3600+1)),
+		float64(midnight + 5*3600 + 2),
+		int64(midnight + 24*3600), // late: the next day
+		int64(midnight - 1),       // early: the previous day
+		nil,                       // no time at all
+	}
+
+	records := make(chan mixpanel.EventData, len(times))
+	for _, ts := range times {
+		record := mixpanel.EventData{"event": "a"}
+		if ts != nil {
+			record["time"] = ts
+		}
+		records <- record
+	}
+	close(records)
+
+	parts, err := sink.Run(records)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := map[string]int{
+		"day-2014-01-01-00-00001.json": 2,
+		"day-2014-01-01-01-00001.json": 1,
+		"day-2014-01-01-05-00001.json": 2,
+		"day-out_of_range-00001.json":  3,
+	}
+
+	if len(parts) != len(expected) {
+		t.Errorf("expected %d parts, got %+v", len(expected), parts)
+	}
+
+	for i, part := range parts {
+		if i > 0 && parts[i-1].File >= part.File {
+			t.Errorf("parts out of order: %s before %s", parts[i-1].File, part.File)
+		}
+
+		if count, ok := expected[part.File]; !ok {
+			t.Errorf("unexpected part %s", part.File)
+		} else if part.Events != count {
+			t.Errorf("%s: expected %d events, got %d", part.File, count, part.Events)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, part.File))
+		if err != nil {
+			t.Fatal(err)
+		} else if lines := bytes.Count(data, []byte("\n")); lines != part.Events {
+			t.Errorf("%s: manifest says %d events, found %d", part.File, part.Events, lines)
+		}
+	}
+}
+
+func TestPartitionedSinkAsSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	partitioned := &PartitionedSink{Directory: dir, Prefix: "day"}
+
+	var sink mixpanel.Sink = partitioned.AsSink()
+	for i := 0; i < 3; i++ {
+		if err := sink.Write([]byte(fmt.Sprintf(`{"event": "a", "n": %d}`, i))); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+	}
+
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if err := sink.Close(); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "day-00001.json"))
+	if err != nil {
+		t.Fatal(err)
+	} else if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Errorf("expected 3 lines, got %d", lines)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "day.manifest.json")); err != nil {
+		t.Errorf("expected a manifest: %v", err)
+	}
+
+	// A failed export closes its parts without a manifest, and releases
+	// their paths for the next attempt.
+	os.Remove(filepath.Join(dir, "day.manifest.json"))
+
+	sink = partitioned.AsSink()
+	if err := sink.Write([]byte(`{"event": "a"}`)); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if err := sink.Close(); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "day.manifest.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no manifest after a failed export, got %v", err)
+	}
+
+	sink = partitioned.AsSink()
+	if err := sink.Write([]byte(`{"event": "a"}`)); err != nil {
+		t.Errorf("expected the part to be released, got %v", err)
+	}
+	sink.Close()
+}
+
+func TestPartitionedSinkMaxOpenFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := &PartitionedSink{
+		Directory:       dir,
+		Prefix:          "day",
+		Product:         "product",
+		Date:            "2014-01-01",
+		Gzip:            true,
+		HourlyPartition: true,
+		MaxOpenFiles:    2,
+	}
+
+	// 2014-01-01T00:00:00Z
+	const midnight = 1388534400
+
+	// Alternate between three hours, so parts keep being suspended.
+	records := make(chan mixpanel.EventData, 30)
+	for i := 0; i < 30; i++ {
+		records <- mixpanel.EventData{"event": "a", "time": int64(midnight + (i%3)*3600), "n": i}
+	}
+	close(records)
+
+	parts, err := sink.Run(records)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %+v", parts)
+	}
+
+	for hour, part := range parts {
+		data, err := ioutil.ReadFile(filepath.Join(dir, part.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The manifest covers every gzip member written.
+		if sum := fmt.Sprintf("%x", sha256.Sum256(data)); sum != part.SHA256 || int64(len(data)) != part.Bytes {
+			t.Errorf("%s: manifest doesn't match the file", part.File)
+		}
+
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ns []int
+		for decoder := json.NewDecoder(gz); decoder.More(); {
+			var record struct{ N int }
+			if err := decoder.Decode(&record); err != nil {
+				t.Fatalf("%s: %v", part.File, err)
+			}
+			ns = append(ns, record.N)
+		}
+
+		if len(ns) != 10 || part.Events != 10 {
+			t.Errorf("%s: expected 10 events, got %d (manifest %d)", part.File, len(ns), part.Events)
+		}
+
+		for i, n := range ns {
+			if n != hour+3*i {
+				t.Errorf("%s: expected event %d, got %d", part.File, hour+3*i, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package exports
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// ErrPathConflict is returned by the file based sinks when a file they would
+// write is already being written by another sink in this process (for
+// example, two event names which map to the same file name). Writing it
+// anyway would interleave and corrupt both outputs.
+var ErrPathConflict = errors.New("file is already open by another writer")
+
+// openPaths tracks every file currently being written by a sink.
+var openPaths = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+// claimPath registers `name` as being written, failing with
+// `ErrPathConflict` if it already is. The returned function releases it
+// again.
+func claimPath(name string) (func(), error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+
+	openPaths.Lock()
+	defer openPaths.Unlock()
+
+	if openPaths.paths[abs] {
+		return nil, fmt.Errorf("%s: %w", name, ErrPathConflict)
+	}
+
+	openPaths.paths[abs] = true
+
+	return func() {
+		openPaths.Lock()
+		defer openPaths.Unlock()
+
+		delete(openPaths.paths, abs)
+	}, nil
+}
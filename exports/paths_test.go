@@ -0,0 +1,72 @@
+package exports
+
+import (
+	"errors"
+	"github.com/erik/mixport/mixpanel"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestEventFileSinkPathConflict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Both of these end up as "day-sign_up.json".
+	records := make(chan mixpanel.EventData, 3)
+	records <- mixpanel.EventData{"event": "sign up"}
+	records <- mixpanel.EventData{"event": "sign/up"}
+	records <- mixpanel.EventData{"event": "sign up"}
+	close(records)
+
+	sink := &EventFileSink{Directory: dir, Prefix: "day", CompressionFor: func(string) (int, bool) { return 0, false }}
+	if _, err := sink.Run(records); !errors.Is(err, ErrPathConflict) {
+		t.Errorf("expected ErrPathConflict, got %v", err)
+	}
+
+	// Everything was released again afterwards.
+	records = make(chan mixpanel.EventData, 1)
+	records <- mixpanel.EventData{"event": "sign up"}
+	close(records)
+
+	if _, err := sink.Run(records); err != nil {
+		t.Errorf("raised error: %v", err)
+	}
+}
+
+func TestPartitionedSinkPathConflict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := make(chan mixpanel.EventData)
+	done := make(chan error)
+
+	go func() {
+		_, err := (&PartitionedSink{Directory: dir, Prefix: "day"}).Run(first)
+		done <- err
+	}()
+
+	// Once the first sink has its part open, a second sink with the same
+	// prefix must not write to it too.
+	first <- mixpanel.EventData{"event": "a"}
+	first <- mixpanel.EventData{"event": "a"}
+
+	second := make(chan mixpanel.EventData, 1)
+	second <- mixpanel.EventData{"event": "b"}
+	close(second)
+
+	if _, err := (&PartitionedSink{Directory: dir, Prefix: "day"}).Run(second); !errors.Is(err, ErrPathConflict) {
+		t.Errorf("expected ErrPathConflict, got %v", err)
+	}
+
+	close(first)
+	if err := <-done; err != nil {
+		t.Errorf("first sink raised error: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Package protobuf writes exported Mixpanel events as length delimited
+// protocol buffer messages, for consumers (gRPC services, say) which would
+// rather not parse JSON.
+//
+// Events have no schema of their own, so the caller maps each one to a
+// message of their choosing.
+//
+// It lives in its own package so that the protobuf library is only pulled in
+// by programs that actually use it. Only its `proto` package is used, which
+// has been around since the first releases of the v2 API, so older releases
+// of it (for older versions of Go) work too.
+package protobuf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/erik/mixport/mixpanel"
+	"google.golang.org/protobuf/proto"
+)
+
+// MaxRecordSize is the largest message a `Reader` will accept, to guard
+// against reading garbage as a huge length prefix.
+const MaxRecordSize = 64 * 1024 * 1024
+
+// MarshalFunc maps an event to the message it's written as. Returning an
+// error skips the event.
+type MarshalFunc func(event mixpanel.EventData) (proto.Message, error)
+
+// Sink is a `mixpanel.Sink` which maps each JSON event to a message with a
+// `MarshalFunc`, writing it to an io.Writer prefixed by its length as a
+// varint, the framing of Java's `writeDelimitedTo` and Go's `protodelim`. Use
+// a `Reader` to read the messages back.
+//
+// Events are decoded as they are exported, with numbers as `json.Number`.
+type Sink struct {
+	w       io.Writer
+	buf     *bufio.Writer
+	marshal MarshalFunc
+	skipped int
+}
+
+// NewSink creates a Sink writing the messages `marshal` maps events to to
+// `w`. Output is buffered until `Flush`, and `Close` closes `w` if it is an
+// io.Closer.
+func NewSink(w io.Writer, marshal MarshalFunc) *Sink {
+	return &Sink{w: w, buf: bufio.NewWriter(w), marshal: marshal}
+}
+
+// Write maps a single JSON encoded event to its message and writes it, or
+// skips it if the `MarshalFunc` rejects it.
+func (s *Sink) Write(event []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+
+	var data mixpanel.EventData
+	if err := decoder.Decode(&data); err != nil {
+		return fmt.Errorf("protobuf: decoding event failed: %w", err)
+	}
+
+	msg, err := s.marshal(data)
+	if err != nil {
+		s.skipped++
+		return nil
+	}
+
+	encoded, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("protobuf: encoding event failed: %w", err)
+	}
+
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(len(encoded)))
+
+	if _, err := s.buf.Write(prefix[:n]); err != nil {
+		return err
+	}
+
+	_, err = s.buf.Write(encoded)
+	return err
+}
+
+// Skipped returns the number of events the `MarshalFunc` rejected.
+func (s *Sink) Skipped() int {
+	return s.skipped
+}
+
+// Flush writes out any buffered messages.
+func (s *Sink) Flush() error {
+	return s.buf.Flush()
+}
+
+// Close closes the underlying writer, if it can be. It doesn't flush.
+func (s *Sink) Close() error {
+	if closer, ok := s.w.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// Reader reads back the messages written by a `Sink`.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader creates a Reader reading messages from `r`.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next reads the next message into `msg`, which must be of the type it was
+// written as. Returns io.EOF once there are no more messages, and
+// io.ErrUnexpectedEOF if the last one is truncated.
+func (r *Reader) Next(msg proto.Message) error {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	} else if size > MaxRecordSize {
+		return fmt.Errorf("protobuf: message of %d bytes is over the limit of %d", size, MaxRecordSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err == io.EOF {
+		return io.ErrUnexpectedEOF
+	} else if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(data, msg)
+}
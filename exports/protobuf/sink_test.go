@@ -0,0 +1,146 @@
+package protobuf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/erik/mixport/mixpanel"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// eventMessage maps an event to a Struct of its name and count, rejecting
+// events without a count.
+func eventMessage(event mixpanel.EventData) (proto.Message, error) {
+	count, ok := event["count"].(json.Number)
+	if !ok {
+		return nil, errors.New("no count")
+	}
+
+	n, err := count.Float64()
+	if err != nil {
+		return nil, err
+	}
+
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"event": structpb.NewStringValue(fmt.Sprint(event["event"])),
+		"count": structpb.NewNumberValue(n),
+	}}, nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	events := []string{
+		`{"event": "a", "count": 1, "other": "dropped"}`,
+		`{"event": "b"}`,
+		`{"event": "c", "count": 2.5}`,
+	}
+
+	var buf bytes.Buffer
+	sink := NewSink(&buf, eventMessage)
+
+	for _, event := range events {
+		if err := sink.Write([]byte(event)); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+	}
+
+	if buf.Len() != 0 {
+		t.Error("expected output to be buffered until flushed")
+	}
+
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	} else if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sink.Skipped() != 1 {
+		t.Errorf("expected 1 skipped event, got %d", sink.Skipped())
+	}
+
+	reader := NewReader(&buf)
+
+	for _, want := range []struct {
+		event string
+		count float64
+	}{{"a", 1}, {"c", 2.5}} {
+		var msg structpb.Struct
+		if err := reader.Next(&msg); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		if msg.Fields["event"].GetStringValue() != want.event || msg.Fields["count"].GetNumberValue() != want.count || len(msg.Fields) != 2 {
+			t.Errorf("expected %v, got %v", want, &msg)
+		}
+	}
+
+	if err := reader.Next(&structpb.Struct{}); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestFraming(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSink(&buf, eventMessage)
+	sink.Write([]byte(`{"event": "a", "count": 1}`))
+	sink.Flush()
+
+	msg, _ := eventMessage(mixpanel.EventData{"event": "a", "count": json.Number("1")})
+
+	// A varint length, then exactly that many bytes of message.
+	size, n := binary.Uvarint(buf.Bytes())
+	if n <= 0 || int(size) != proto.Size(msg) || buf.Len() != n+int(size) {
+		t.Errorf("expected a %d byte message with its length, got %x", proto.Size(msg), buf.Bytes())
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSink(&buf, eventMessage)
+	sink.Write([]byte(`{"event": "a", "count": 1}`))
+	sink.Flush()
+
+	data := buf.Bytes()
+	if err := NewReader(bytes.NewReader(data[:len(data)-1])).Next(&structpb.Struct{}); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected ErrUnexpectedEOF, got %v", err)
+	}
+
+	if err := NewReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x0f})).Next(&structpb.Struct{}); err == nil {
+		t.Error("expected an error for an oversized message")
+	}
+}
+
+func TestExportToSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"count": %d}}`+"\n", i)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	mix := mixpanel.NewWithURL("product", "key", "secret", server.URL)
+
+	if _, err := mix.ExportDateToSink(context.Background(), time.Now(), NewSink(&buf, eventMessage), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	reader := NewReader(&buf)
+	for i := 0; i < 3; i++ {
+		var msg structpb.Struct
+		if err := reader.Next(&msg); err != nil {
+			t.Fatal(err)
+		} else if msg.Fields["count"].GetNumberValue() != float64(i) {
+			t.Errorf("unexpected message %v", &msg)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package exports
+
+import (
+	"encoding/json"
+	"github.com/erik/mixport/mixpanel"
+	"sort"
+	"sync"
+)
+
+// The JSON types a property's values can be recorded as by a
+// `SchemaCollector`. Numbers are split into integers and floats, since a
+// property changing from one to the other is the sort of thing worth knowing
+// about.
+const (
+	TypeNull   = "null"
+	TypeBool   = "bool"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeString = "string"
+	TypeArray  = "array"
+	TypeObject = "object"
+)
+
+// Schema is a snapshot of the shape of an export: for each event type, each
+// property seen on it and the (sorted) types of the values it had. It
+// marshals to JSON as is, so that it can be stored and compared against a
+// later export (see `DriftDetector`).
+type Schema map[string]map[string][]string
+
+// SchemaCollector builds up the `Schema` of the records it's shown. It is
+// safe for concurrent use.
+type SchemaCollector struct {
+	mu     sync.Mutex
+	events map[string]map[string]map[string]bool
+}
+
+// NewSchemaCollector creates an empty collector.
+func NewSchemaCollector() *SchemaCollector {
+	return &SchemaCollector{events: make(map[string]map[string]map[string]bool)}
+}
+
+// Add records the properties of a single record.
+func (c *SchemaCollector) Add(record mixpanel.EventData) {
+	event, _ := record["event"].(string)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	props := c.events[event]
+	if props == nil {
+		props = make(map[string]map[string]bool)
+		c.events[event] = props
+	}
+
+	for key, value := range record {
+		types := props[key]
+		if types == nil {
+			types = make(map[string]bool)
+			props[key] = types
+		}
+
+		types[valueType(value)] = true
+	}
+}
+
+// Collect passes every record from `records` through to the returned
+// channel, adding each to the schema on the way.
+func (c *SchemaCollector) Collect(records <-chan mixpanel.EventData) <-chan mixpanel.EventData {
+	collected := make(chan mixpanel.EventData, 100)
+
+	go func() {
+		defer close(collected)
+
+		for record := range records {
+			c.Add(record)
+			collected <- record
+		}
+	}()
+
+	return collected
+}
+
+// Schema returns the schema of everything added so far.
+func (c *SchemaCollector) Schema() Schema {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	schema := make(Schema, len(c.events))
+
+	for event, props := range c.events {
+		schema[event] = make(map[string][]string, len(props))
+
+		for key, types := range props {
+			list := make([]string, 0, len(types))
+			for t := range types {
+				list = append(list, t)
+			}
+			sort.Strings(list)
+
+			schema[event][key] = list
+		}
+	}
+
+	return schema
+}
+
+// valueType returns the type of a decoded JSON value.
+func valueType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBool
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return TypeInt
+		}
+		return TypeFloat
+	case float64:
+		if v == float64(int64(v)) {
+			return TypeInt
+		}
+		return TypeFloat
+	case int, int64:
+		return TypeInt
+	case string:
+		return TypeString
+	case []interface{}:
+		return TypeArray
+	default:
+		return TypeObject
+	}
+}
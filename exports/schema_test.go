@@ -0,0 +1,45 @@
+package exports
+
+import (
+	"encoding/json"
+	"github.com/erik/mixport/mixpanel"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaCollector(t *testing.T) {
+	records := make(chan mixpanel.EventData, 3)
+	records <- mixpanel.EventData{"event": "a", "n": json.Number("1"), "s": "x", "o": map[string]interface{}{}}
+	records <- mixpanel.EventData{"event": "a", "n": json.Number("1.5"), "s": nil, "l": []interface{}{}}
+	records <- mixpanel.EventData{"event": "b", "ok": true}
+	close(records)
+
+	collector := NewSchemaCollector()
+
+	passed := 0
+	for range collector.Collect(records) {
+		passed++
+	}
+
+	if passed != 3 {
+		t.Errorf("expected 3 records passed through, got %d", passed)
+	}
+
+	expected := Schema{
+		"a": {
+			"event": {TypeString},
+			"n":     {TypeFloat, TypeInt},
+			"s":     {TypeNull, TypeString},
+			"o":     {TypeObject},
+			"l":     {TypeArray},
+		},
+		"b": {
+			"event": {TypeString},
+			"ok":    {TypeBool},
+		},
+	}
+
+	if schema := collector.Schema(); !reflect.DeepEqual(schema, expected) {
+		t.Errorf("expected %v, got %v", expected, schema)
+	}
+}
@@ -0,0 +1,61 @@
+package exports
+
+import (
+	"fmt"
+	"github.com/erik/mixport/mixpanel"
+	"hash/fnv"
+)
+
+// ShardByEvent is a shard key function which keeps all instances of an event
+// type on the same shard.
+func ShardByEvent(record mixpanel.EventData) string {
+	return fmt.Sprintf("%v", record["event"])
+}
+
+// ShardByDistinctID is a shard key function which keeps all of a single
+// user's events on the same shard.
+func ShardByDistinctID(record mixpanel.EventData) string {
+	return fmt.Sprintf("%v", record["distinct_id"])
+}
+
+// Shard splits the records coming over `records` across `n` channels, so that
+// they can be handled by `n` streaming exporters running in parallel. The
+// shard a record lands on is picked by hashing the value returned by `key`.
+//
+// Records with the same key always land on the same shard, and any two
+// records on the same shard arrive in the order they were received. There are
+// no ordering guarantees between shards.
+//
+// The returned channels are all closed once `records` is closed and drained.
+func Shard(records <-chan mixpanel.EventData, n int, key func(mixpanel.EventData) string) []<-chan mixpanel.EventData {
+	if n < 1 {
+		n = 1
+	}
+
+	shards := make([]chan mixpanel.EventData, n)
+	out := make([]<-chan mixpanel.EventData, n)
+
+	for i := range shards {
+		// Buffered so one slow shard doesn't immediately block the
+		// others.
+		shards[i] = make(chan mixpanel.EventData, 100)
+		out[i] = shards[i]
+	}
+
+	go func() {
+		hash := fnv.New32a()
+
+		for record := range records {
+			hash.Reset()
+			hash.Write([]byte(key(record)))
+
+			shards[hash.Sum32()%uint32(n)] <- record
+		}
+
+		for _, shard := range shards {
+			close(shard)
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,103 @@
+package exports
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/erik/mixport/mixpanel"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestShard(t *testing.T) {
+	records := make(chan mixpanel.EventData, 100)
+
+	for i := 0; i < 100; i++ {
+		event := make(mixpanel.EventData)
+		event[mixpanel.EventIDKey] = fmt.Sprintf("%d", i)
+		event["event"] = fmt.Sprintf("event%d", i%7)
+
+		records <- event
+	}
+	close(records)
+
+	shards := Shard(records, 4, ShardByEvent)
+	if len(shards) != 4 {
+		t.Fatalf("expected 4 shards, got %d", len(shards))
+	}
+
+	var wg sync.WaitGroup
+	output := make([]bytes.Buffer, 4)
+
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard <-chan mixpanel.EventData) {
+			defer wg.Done()
+			JSONStreamer(&output[i], shard)
+		}(i, shard)
+	}
+
+	wg.Wait()
+
+	total := 0
+	shardOf := make(map[string]int)
+
+	for i := range output {
+		for _, line := range strings.Split(strings.TrimSpace(output[i].String()), "\n") {
+			if line == "" {
+				continue
+			}
+
+			total++
+
+			// Each event name should only ever be seen on a single
+			// shard.
+			name := strings.SplitN(strings.SplitN(line, `"event":"`, 2)[1], `"`, 2)[0]
+			if prev, ok := shardOf[name]; ok && prev != i {
+				t.Errorf("%s seen on shards %d and %d", name, prev, i)
+			}
+
+			shardOf[name] = i
+		}
+	}
+
+	if total != 100 {
+		t.Errorf("expected 100 events across all shards, got %d", total)
+	}
+}
+
+// A noisy product with thousands of distinct event names must not spawn a
+// goroutine per name.
+func TestShardBoundedGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	records := make(chan mixpanel.EventData)
+
+	var wg sync.WaitGroup
+	for _, shard := range Shard(records, 8, ShardByEvent) {
+		wg.Add(1)
+		go func(shard <-chan mixpanel.EventData) {
+			defer wg.Done()
+			JSONStreamer(ioutil.Discard, shard)
+		}(shard)
+	}
+
+	peak := 0
+	for i := 0; i < 5000; i++ {
+		records <- mixpanel.EventData{mixpanel.EventIDKey: "id", "event": fmt.Sprintf("event%d", i)}
+
+		if n := runtime.NumGoroutine(); n > peak {
+			peak = n
+		}
+	}
+
+	close(records)
+	wg.Wait()
+
+	// The shard distributor plus one streamer per shard.
+	if extra := peak - before; extra > 9 {
+		t.Errorf("expected at most 9 extra goroutines, saw %d", extra)
+	}
+}
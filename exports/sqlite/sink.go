@@ -0,0 +1,250 @@
+// Package sqlite loads exported Mixpanel events into a SQLite database, for
+// poking at a day's events with SQL locally.
+//
+// It uses the pure Go `modernc.org/sqlite` driver, so it doesn't need cgo,
+// and importing the package registers the driver as "sqlite". It lives in
+// its own package so that the driver, which needs a much newer Go than the
+// rest of mixport, is only pulled in by programs that actually use it.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/erik/mixport/mixpanel"
+
+	// Registers the "sqlite" driver.
+	_ "modernc.org/sqlite"
+)
+
+// Default values of the `Options` fields.
+const (
+	DefaultTable     = "events"
+	DefaultBatchSize = 1000
+)
+
+// The properties which get columns of their own. Everything else goes in the
+// `properties` column.
+var coreColumns = []string{"event", "distinct_id", "time", "product"}
+
+// Options configures a `Sink`.
+//
+// - `Table` is the table events are inserted into, created (along with
+//   indexes on `event` and `time`) if it doesn't exist. Defaults to
+//   `DefaultTable`.
+// - `BatchSize` is the number of events inserted per transaction. Defaults
+//   to `DefaultBatchSize`.
+type Options struct {
+	Table     string
+	BatchSize int
+}
+
+// Sink inserts events into a SQLite table with the columns `event`,
+// `distinct_id`, `time` (in seconds since the epoch, if the event has a
+// usable one), `product` and `properties`, a JSON object of the rest of the
+// event's properties, which can be queried with SQLite's JSON functions
+// (`json_extract(properties, '$.plan')`, say).
+type Sink struct {
+	db   *sql.DB
+	opts Options
+}
+
+// NewSink creates a sink inserting into `db`, which should have been opened
+// with the "sqlite" driver, creating the table if need be.
+func NewSink(ctx context.Context, db *sql.DB, opts Options) (*Sink, error) {
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	s := &Sink{db: db, opts: opts}
+
+	table := s.table()
+	schema := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (event TEXT, distinct_id TEXT, time INTEGER, product TEXT, properties TEXT)`, table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (event)`, quote(opts.Table+"_event"), table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (time)`, quote(opts.Table+"_time"), table),
+	}
+
+	for _, stmt := range schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("sqlite: creating table failed: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// table returns the quoted name of the table.
+func (s *Sink) table() string {
+	return quote(s.opts.Table)
+}
+
+// quote quotes an identifier for use in SQL.
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// Run inserts every event received on `in` until it is closed, committing a
+// transaction every `BatchSize` events. Returns the first insert error,
+// after which the rest of `in` is drained and discarded.
+func (s *Sink) Run(ctx context.Context, in <-chan mixpanel.EventData) error {
+	b := &batcher{ctx: ctx, s: s}
+
+	for event := range in {
+		if err := b.add(event); err != nil {
+			for range in {
+			}
+
+			return err
+		}
+	}
+
+	return b.flush()
+}
+
+// AsSink adapts the SQLite sink into a `mixpanel.Sink`, for use with
+// `ExportDateToSink`. Inserts are made with `ctx`, and `Flush` commits
+// whatever is left over; closing it without flushing rolls that back. It
+// doesn't close the database.
+func (s *Sink) AsSink(ctx context.Context) mixpanel.Sink {
+	return &sinkAdapter{&batcher{ctx: ctx, s: s}}
+}
+
+// batcher inserts events in transactions of `BatchSize`.
+type batcher struct {
+	ctx  context.Context
+	s    *Sink
+	tx   *sql.Tx
+	stmt *sql.Stmt
+	n    int
+}
+
+// add inserts `event`, committing the transaction once it's full.
+func (b *batcher) add(event mixpanel.EventData) error {
+	if b.tx == nil {
+		tx, err := b.s.db.BeginTx(b.ctx, nil)
+		if err != nil {
+			return fmt.Errorf("sqlite: starting transaction failed: %w", err)
+		}
+
+		stmt, err := tx.PrepareContext(b.ctx, fmt.Sprintf(`INSERT INTO %s (event, distinct_id, time, product, properties) VALUES (?, ?, ?, ?, ?)`, b.s.table()))
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("sqlite: preparing insert failed: %w", err)
+		}
+
+		b.tx, b.stmt = tx, stmt
+	}
+
+	values, err := rowValues(event)
+	if err == nil {
+		_, err = b.stmt.ExecContext(b.ctx, values...)
+	}
+
+	if err != nil {
+		b.rollback()
+		return fmt.Errorf("sqlite: insert failed: %w", err)
+	}
+
+	if b.n++; b.n < b.s.opts.BatchSize {
+		return nil
+	}
+
+	return b.flush()
+}
+
+// flush commits the events inserted so far.
+func (b *batcher) flush() error {
+	if b.tx == nil {
+		return nil
+	}
+
+	b.stmt.Close()
+	err := b.tx.Commit()
+	b.tx, b.stmt, b.n = nil, nil, 0
+
+	if err != nil {
+		return fmt.Errorf("sqlite: commit failed: %w", err)
+	}
+
+	return nil
+}
+
+// rollback discards the events inserted since the last commit.
+func (b *batcher) rollback() {
+	if b.tx == nil {
+		return
+	}
+
+	b.stmt.Close()
+	b.tx.Rollback()
+	b.tx, b.stmt, b.n = nil, nil, 0
+}
+
+// rowValues returns the values of the columns of `event`.
+func rowValues(event mixpanel.EventData) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(coreColumns)+1)
+
+	for _, column := range coreColumns {
+		v, ok := event[column]
+
+		switch {
+		case !ok || v == nil:
+			values = append(values, nil)
+		case column == "time":
+			if t, ok := mixpanel.EventTime(event); ok {
+				values = append(values, t.Unix())
+			} else {
+				values = append(values, nil)
+			}
+		default:
+			values = append(values, fmt.Sprint(v))
+		}
+	}
+
+	rest := make(map[string]interface{}, len(event))
+	for k, v := range event {
+		rest[k] = v
+	}
+
+	for _, column := range coreColumns {
+		delete(rest, column)
+	}
+
+	properties, err := json.Marshal(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(values, string(properties)), nil
+}
+
+// sinkAdapter is the mixpanel.Sink returned by `Sink.AsSink`.
+type sinkAdapter struct {
+	b *batcher
+}
+
+func (a *sinkAdapter) Write(event []byte) error {
+	record, err := mixpanel.DecodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	return a.b.add(record)
+}
+
+func (a *sinkAdapter) Flush() error {
+	return a.b.flush()
+}
+
+func (a *sinkAdapter) Close() error {
+	a.b.rollback()
+	return nil
+}
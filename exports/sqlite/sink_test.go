@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erik/mixport/mixpanel"
+)
+
+// openDB opens a fresh database in a temporary directory, returning a function
+// which closes and removes it.
+func openDB(t *testing.T) (*sql.DB, func()) {
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "events.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSink(t *testing.T) {
+	db, cleanup := openDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	sink, err := NewSink(ctx, db, Options{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	in := make(chan mixpanel.EventData, 5)
+	for i := 0; i < 5; i++ {
+		event := "view"
+		if i%2 == 1 {
+			event = "purchase"
+		}
+
+		in <- mixpanel.EventData{
+			"event":       event,
+			"product":     "product",
+			"distinct_id": fmt.Sprintf("user%d", i),
+			"time":        json.Number(fmt.Sprint(1388534400 + i)),
+			"plan":        fmt.Sprintf("plan%d", i),
+			"$city":       "Paris",
+		}
+	}
+	close(in)
+
+	if err := sink.Run(ctx, in); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	var total, purchases int
+	if err := db.QueryRow(`SELECT COUNT(*), SUM(event = 'purchase') FROM events`).Scan(&total, &purchases); err != nil {
+		t.Fatal(err)
+	} else if total != 5 || purchases != 2 {
+		t.Errorf("expected 5 events and 2 purchases, got %d and %d", total, purchases)
+	}
+
+	var (
+		id, plan, city string
+		ts             int64
+	)
+
+	row := db.QueryRow(`SELECT distinct_id, time, json_extract(properties, '$.plan'), json_extract(properties, '$."$city"') FROM events WHERE time = ?`, 1388534403)
+	if err := row.Scan(&id, &ts, &plan, &city); err != nil {
+		t.Fatal(err)
+	} else if id != "user3" || plan != "plan3" || city != "Paris" {
+		t.Errorf("unexpected row %s %d %s %s", id, ts, plan, city)
+	}
+
+	// The core columns aren't repeated in the properties.
+	var event sql.NullString
+	if err := db.QueryRow(`SELECT json_extract(properties, '$.event') FROM events LIMIT 1`).Scan(&event); err != nil {
+		t.Fatal(err)
+	} else if event.Valid {
+		t.Errorf("expected no event in the properties, got %s", event.String)
+	}
+
+	var indexes int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'events'`).Scan(&indexes); err != nil {
+		t.Fatal(err)
+	} else if indexes != 2 {
+		t.Errorf("expected 2 indexes, got %d", indexes)
+	}
+
+	// Creating the sink again leaves the table as it is.
+	if _, err := NewSink(ctx, db, Options{}); err != nil {
+		t.Errorf("raised error: %v", err)
+	}
+}
+
+func TestExportToSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"time": 1388534400, "i": %d}}`+"\n", i)
+		}
+	}))
+	defer server.Close()
+
+	db, cleanup := openDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	sink, err := NewSink(ctx, db, Options{Table: "day"})
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	mix := mixpanel.NewWithURL("product", "key", "secret", server.URL)
+	if _, err := mix.ExportDateToSink(ctx, time.Now(), sink.AsSink(ctx), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	var n, sum int
+	if err := db.QueryRow(`SELECT COUNT(*), SUM(json_extract(properties, '$.i')) FROM day WHERE product = 'product'`).Scan(&n, &sum); err != nil {
+		t.Fatal(err)
+	} else if n != 3 || sum != 3 {
+		t.Errorf("expected 3 events adding up to 3, got %d and %d", n, sum)
+	}
+}
@@ -0,0 +1,96 @@
+package exports
+
+import (
+	"github.com/erik/mixport/mixpanel"
+	"sort"
+)
+
+// UnifiedSchema makes a stream of several event types rectangular, so that it
+// can be loaded into a single columnar table: every record comes out with the
+// same set of properties, those it didn't have set to nil.
+//
+// - `Events` are the event types to keep. Records of any other type are
+//   dropped. Empty keeps every type.
+// - `Columns` is the set of properties every record ends up with. Properties
+//   outside of it are dropped, so it should include `event` and anything else
+//   mixport adds which is wanted in the output. If empty, it's inferred as the
+//   union of every kept record's properties, which means holding the whole
+//   stream in memory before anything can be sent on.
+type UnifiedSchema struct {
+	Events  []string
+	Columns []string
+}
+
+// Apply passes every kept record from `records` through to the returned
+// channel, with exactly the schema's columns. The returned channel is closed
+// once `records` is.
+func (u UnifiedSchema) Apply(records <-chan mixpanel.EventData) <-chan mixpanel.EventData {
+	unified := make(chan mixpanel.EventData, 100)
+
+	var events map[string]bool
+	if len(u.Events) > 0 {
+		events = make(map[string]bool, len(u.Events))
+		for _, event := range u.Events {
+			events[event] = true
+		}
+	}
+
+	keep := func(record mixpanel.EventData) bool {
+		name, _ := record["event"].(string)
+		return events == nil || events[name]
+	}
+
+	go func() {
+		defer close(unified)
+
+		if len(u.Columns) > 0 {
+			for record := range records {
+				if keep(record) {
+					unified <- unifyRecord(record, u.Columns)
+				}
+			}
+
+			return
+		}
+
+		var (
+			buffered []mixpanel.EventData
+			union    = make(map[string]bool)
+		)
+
+		for record := range records {
+			if !keep(record) {
+				continue
+			}
+
+			for key := range record {
+				union[key] = true
+			}
+
+			buffered = append(buffered, record)
+		}
+
+		columns := make([]string, 0, len(union))
+		for key := range union {
+			columns = append(columns, key)
+		}
+		sort.Strings(columns)
+
+		for _, record := range buffered {
+			unified <- unifyRecord(record, columns)
+		}
+	}()
+
+	return unified
+}
+
+// unifyRecord returns `record` with exactly `columns` as its keys.
+func unifyRecord(record mixpanel.EventData, columns []string) mixpanel.EventData {
+	unified := make(mixpanel.EventData, len(columns))
+
+	for _, column := range columns {
+		unified[column] = record[column]
+	}
+
+	return unified
+}
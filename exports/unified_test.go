@@ -0,0 +1,52 @@
+package exports
+
+import (
+	"github.com/erik/mixport/mixpanel"
+	"reflect"
+	"testing"
+)
+
+func TestUnifiedSchemaInferred(t *testing.T) {
+	records := make(chan mixpanel.EventData, 4)
+	records <- mixpanel.EventData{"event": "signup", "plan": "pro", "referrer": "news"}
+	records <- mixpanel.EventData{"event": "purchase", "amount": 10, "currency": "USD"}
+	records <- mixpanel.EventData{"event": "ignored", "other": true}
+	records <- mixpanel.EventData{"event": "signup", "plan": "free"}
+	close(records)
+
+	var rows []mixpanel.EventData
+	for row := range (UnifiedSchema{Events: []string{"signup", "purchase"}}).Apply(records) {
+		rows = append(rows, row)
+	}
+
+	expected := []mixpanel.EventData{
+		{"event": "signup", "plan": "pro", "referrer": "news", "amount": nil, "currency": nil},
+		{"event": "purchase", "plan": nil, "referrer": nil, "amount": 10, "currency": "USD"},
+		{"event": "signup", "plan": "free", "referrer": nil, "amount": nil, "currency": nil},
+	}
+
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+}
+
+func TestUnifiedSchemaColumns(t *testing.T) {
+	records := make(chan mixpanel.EventData, 2)
+	records <- mixpanel.EventData{"event": "a", "x": 1, "dropped": true}
+	records <- mixpanel.EventData{"event": "b", "y": 2}
+	close(records)
+
+	var rows []mixpanel.EventData
+	for row := range (UnifiedSchema{Columns: []string{"event", "x", "y"}}).Apply(records) {
+		rows = append(rows, row)
+	}
+
+	expected := []mixpanel.EventData{
+		{"event": "a", "x": 1, "y": nil},
+		{"event": "b", "x": nil, "y": 2},
+	}
+
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+}
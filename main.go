@@ -2,6 +2,7 @@ package main
 
 import (
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -30,12 +31,32 @@ type mixpanelCredentials struct {
 
 // fileExportConfig contains configuration options common to the file export
 // streams (CSV and JSON).
+//
+// - `Workers` is the number of writer goroutines (and output files) events are
+//   sharded across. Zero or one means a single file.
+// - `ShardBy` selects what events are sharded on, either "event" (the
+//   default) or "distinct_id".
+// - `Manifest` writes a `.manifest.json` sidecar describing each export file
+//   once it has been written successfully.
+// - `FlushInterval` is how often buffered output is flushed to disk, as
+//   understood by `time.ParseDuration`. Empty means only at the end.
 type fileExportConfig struct {
-	State        bool
-	Gzip         bool
-	Fifo         bool
-	RemoveFailed bool
-	Directory    string
+	State         bool
+	Gzip          bool
+	Fifo          bool
+	RemoveFailed  bool
+	Directory     string
+	Workers       int
+	ShardBy       string
+	Manifest      bool
+	FlushInterval string
+}
+
+// flushInterval returns the parsed `FlushInterval`, which is validated when
+// the configuration is loaded.
+func (c fileExportConfig) flushInterval() time.Duration {
+	interval, _ := time.ParseDuration(c.FlushInterval)
+	return interval
 }
 
 // columnExportConfig contains configuration options for the CSV with columns
@@ -77,7 +98,28 @@ var cfg = configFormat{}
 
 // Map containing the names of the product exports that failed. For deletion
 // and error reporting purposes.
-var failedExports = make(map[string]bool)
+//
+// Products are exported concurrently, so access must hold `failedLock`.
+var (
+	failedExports = make(map[string]bool)
+	failedLock    sync.Mutex
+)
+
+// markFailed records that the export of the given product failed.
+func markFailed(product string) {
+	failedLock.Lock()
+	defer failedLock.Unlock()
+
+	failedExports[product] = true
+}
+
+// exportFailed reports whether the export of the given product failed.
+func exportFailed(product string) bool {
+	failedLock.Lock()
+	defer failedLock.Unlock()
+
+	return failedExports[product]
+}
 
 func main() {
 	flag.Usage = func() {
@@ -170,6 +212,16 @@ Options:
 		if config.State && config.Fifo && config.RemoveFailed {
 			log.Fatalf("Can't have both `fifo=true` and `removefailed=true`")
 		}
+
+		if config.ShardBy != "" && config.ShardBy != "event" && config.ShardBy != "distinct_id" {
+			log.Fatalf("Invalid `shardby=%s`, should be `event` or `distinct_id`", config.ShardBy)
+		}
+
+		if config.FlushInterval != "" {
+			if _, err := time.ParseDuration(config.FlushInterval); err != nil {
+				log.Fatalf("Invalid `flushinterval=%s`: %s", config.FlushInterval, err)
+			}
+		}
 	}
 
 	products := make(map[string]*mixpanelCredentials)
@@ -214,10 +266,12 @@ Options:
 //
 // The returned tuple contains:
 //   - A generic io.Writer which will be passed off to the export function.
+//   - The recorder building the file's manifest, or nil if `conf.Manifest`
+//     isn't set. Records should be passed through its `Count`.
 //   - A function taking no arguments which should be called after the export
 //     function finishes (using defer) to do any necessary cleanup, depending
 //     on the specified configuration options.
-func createExportFile(export exportConfig, conf fileExportConfig, event, ext string) (io.Writer, func()) {
+func createExportFile(export exportConfig, conf fileExportConfig, event, ext string) (io.Writer, *exports.ManifestRecorder, func()) {
 	if conf.Gzip {
 		ext += ".gz"
 	}
@@ -252,8 +306,17 @@ func createExportFile(export exportConfig, conf fileExportConfig, event, ext str
 	}
 
 	writer := (io.Writer)(fp)
+
+	// Sits below any compression, so the manifest describes the file
+	// exactly as it is on disk.
+	var recorder *exports.ManifestRecorder
+	if conf.Manifest {
+		recorder = exports.NewManifestRecorder(fp, export.Product, stamp)
+		writer = recorder
+	}
+
 	if conf.Gzip {
-		writer = gzip.NewWriter(fp)
+		writer = gzip.NewWriter(writer)
 	}
 
 	// Create a function used to clean up any on-disk resources created for
@@ -272,13 +335,55 @@ func createExportFile(export exportConfig, conf fileExportConfig, event, ext str
 		// Make sure bad files get deleted if the export for this
 		// product failed.
 		if conf.RemoveFailed {
-			if _, err := failedExports[export.Product]; err {
+			if exportFailed(export.Product) {
 				os.Remove(name)
 			}
 		}
+
+		if recorder != nil && !exportFailed(export.Product) {
+			if err := recorder.WriteFile(name + ".manifest.json"); err != nil {
+				log.Printf("%s: couldn't write manifest: %s", export.Product, err)
+			}
+		}
+	}
+
+	return writer, recorder, cleanup
+}
+
+// runFileExport runs `streamer` over `records`, writing to a single export
+// file, or if `conf.Workers` is greater than one, sharding the records across
+// that many streamers each writing to their own file.
+func runFileExport(export exportConfig, conf fileExportConfig, ext string,
+	records <-chan mixpanel.EventData, streamer func(io.Writer, <-chan mixpanel.EventData)) {
+
+	if conf.Workers <= 1 {
+		writer, recorder, cleanup := createExportFile(export, conf, "", ext)
+		defer cleanup()
+
+		streamer(writer, recorder.Count(records))
+		return
+	}
+
+	key := exports.ShardByEvent
+	if conf.ShardBy == "distinct_id" {
+		key = exports.ShardByDistinctID
 	}
 
-	return writer, cleanup
+	var wg sync.WaitGroup
+
+	for i, shard := range exports.Shard(records, conf.Workers, key) {
+		wg.Add(1)
+		go func(i int, shard <-chan mixpanel.EventData) {
+			defer wg.Done()
+
+			writer, recorder, cleanup := createExportFile(export, conf, fmt.Sprintf("shard%02d", i), ext)
+			defer cleanup()
+
+			streamer(writer, recorder.Count(shard))
+		}(i, shard)
+	}
+
+	wg.Wait()
 }
 
 // exportProduct is called once for each individual mixpanel product to be
@@ -310,11 +415,8 @@ func exportProduct(export exportConfig, wg *sync.WaitGroup) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-
-			writer, cleanup := createExportFile(export, cfg.JSON, "", "json")
-			defer cleanup()
-
-			exports.JSONStreamer(writer, c)
+			runFileExport(export, cfg.JSON, "json", c,
+				exports.FlushingJSONStreamer(cfg.JSON.flushInterval()))
 		}()
 	}
 
@@ -324,11 +426,8 @@ func exportProduct(export exportConfig, wg *sync.WaitGroup) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-
-			writer, cleanup := createExportFile(export, cfg.CSV, "", "csv")
-			defer cleanup()
-
-			exports.CSVStreamer(writer, c)
+			runFileExport(export, cfg.CSV, "csv", c,
+				exports.FlushingCSVStreamer(cfg.CSV.flushInterval()))
 		}()
 	}
 
@@ -361,15 +460,16 @@ func exportProduct(export exportConfig, wg *sync.WaitGroup) {
 				defs := make(map[string]exports.EventColumnDef)
 
 				for event, cols := range prodCols {
-					writer, cleanup := createExportFile(
+					writer, recorder, cleanup := createExportFile(
 						export, cfg.Columns.fileExportConfig, event, "csv")
 
-					defs[event] = exports.NewEventColumnDef(writer, cols)
+					defs[event] = exports.NewRecordedEventColumnDef(writer, cols, recorder)
 
 					defer cleanup()
 				}
 
-				exports.CSVColumnStreamer(defs, c)
+				stream := exports.FlushingCSVColumnStreamer(cfg.Columns.flushInterval())
+				stream(defs, c)
 			}()
 		}
 	}
@@ -386,7 +486,7 @@ func exportProduct(export exportConfig, wg *sync.WaitGroup) {
 		end := export.End.AddDate(0, 0, 1)
 
 		for date := export.Start; date.Before(end); date = date.AddDate(0, 0, 1) {
-			num, err := client.ExportDate(date, eventData, nil)
+			stats, err := client.ExportDate(context.Background(), date, eventData, nil)
 
 			dateStr := date.Format("2006-01-02")
 
@@ -397,13 +497,13 @@ func exportProduct(export exportConfig, wg *sync.WaitGroup) {
 			//       until the end? Maybe make that configurable.
 			if err != nil {
 				log.Printf("%s: %s: export failed: %v", dateStr, export.Product, err)
-				failedExports[export.Product] = true
+				markFailed(export.Product)
 				return
-			} else if num == 0 {
+			} else if stats.EventsExported == 0 {
 				log.Printf("%s: %s: no records.", dateStr, export.Product)
 			}
 
-			total += num
+			total += stats.EventsExported
 		}
 
 		log.Printf("%s: %d records.", export.Product, total)
@@ -0,0 +1,69 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// UserActivity is the activity feed of a single user: every event of theirs
+// in the requested range, oldest first, transformed in the same way as by
+// `TransformEventData`.
+type UserActivity struct {
+	DistinctID string
+	Events     []EventData
+}
+
+// ActivityStream fetches the activity feed of each of the given users from
+// `from` through `to` (inclusive), sending one `UserActivity` per user over
+// `output`, in the order the users were given. Users with no activity are
+// sent with no events.
+//
+// Returns the number of events fetched and possibly an error.
+func (m *Mixpanel) ActivityStream(ctx context.Context, distinctIDs []string, from, to time.Time, output chan<- UserActivity) (int, error) {
+	encoded, err := json.Marshal(distinctIDs)
+	if err != nil {
+		return 0, fmt.Errorf("%s: encoding distinct IDs failed: %w", m.Product, err)
+	}
+
+	args := m.baseArgs()
+	setDateRange(args, EndpointStream, from, to)
+	args.Set("distinct_ids", string(encoded))
+
+	// Response has the form:
+	//   {"status": "ok", "results": {"events": [{"event": "...", "properties": {...}}, ...]}}
+	var result struct {
+		Results struct {
+			Events []rawEvent
+		}
+	}
+
+	if err := m.query(ctx, EndpointStream, args, &result); err != nil {
+		return 0, err
+	}
+
+	byUser := make(map[string][]EventData, len(distinctIDs))
+
+	for i := range result.Results.Events {
+		ev := &result.Results.Events[i]
+
+		event, err := m.transformEvent(ev)
+		if err != nil {
+			return 0, err
+		}
+
+		id := fmt.Sprintf("%v", event["distinct_id"])
+		byUser[id] = append(byUser[id], event)
+	}
+
+	for _, id := range distinctIDs {
+		select {
+		case output <- UserActivity{DistinctID: id, Events: byUser[id]}:
+		case <-ctx.Done():
+			return len(result.Results.Events), ctx.Err()
+		}
+	}
+
+	return len(result.Results.Events), nil
+}
@@ -0,0 +1,77 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Trimmed down response from the activity stream endpoint.
+const activityPayload = `{
+  "status": "ok",
+  "results": {
+    "events": [
+      {"event": "Signed Up", "properties": {"distinct_id": "user1", "time": 1388534400, "$os": "Mac OS X"}},
+      {"event": "Viewed Page", "properties": {"distinct_id": "user2", "time": 1388534460, "page": "/home"}},
+      {"event": "Viewed Page", "properties": {"distinct_id": "user1", "time": 1388534500, "page": "/pricing"}}
+    ]
+  }
+}`
+
+func TestActivityStream(t *testing.T) {
+	var ids string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stream/query" || r.URL.Query().Get("sig") == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		ids = r.URL.Query().Get("distinct_ids")
+		fmt.Fprint(w, activityPayload)
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	output := make(chan UserActivity, 3)
+
+	num, err := mix.ActivityStream(context.Background(), []string{"user1", "user2", "user3"}, date, date, output)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+	close(output)
+
+	if num != 3 {
+		t.Errorf("expected 3 events, got %d", num)
+	}
+
+	if ids != `["user1","user2","user3"]` {
+		t.Errorf("unexpected distinct_ids: %s", ids)
+	}
+
+	expected := map[string][]string{
+		"user1": {"Signed Up", "Viewed Page"},
+		"user2": {"Viewed Page"},
+		"user3": nil,
+	}
+
+	for activity := range output {
+		names := expected[activity.DistinctID]
+		if len(activity.Events) != len(names) {
+			t.Errorf("%s: expected %d events, got %d", activity.DistinctID, len(names), len(activity.Events))
+			continue
+		}
+
+		for i, event := range activity.Events {
+			if event["event"] != names[i] || event["product"] != "product" || event[TimestampKey] == nil {
+				t.Errorf("%s: unexpected event %d: %v", activity.DistinctID, i, event)
+			}
+		}
+	}
+}
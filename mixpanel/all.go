@@ -0,0 +1,90 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Key into the EventData map identifying what kind of record it is in the
+// output of `ExportAll`, and its values.
+const (
+	RecordTypeKey     = "_record_type"
+	RecordTypeEvent   = "event"
+	RecordTypeProfile = "profile"
+)
+
+// ExportAllOptions configures `ExportAll`.
+//
+// - `MoreArgs` are passed on to the event export, as for `ExportDate`.
+// - `EventOptions` are the options of the event export, as for
+//   `ExportDateWithOptions`. Anything also in `MoreArgs` is overridden by it.
+// - `Where` filters the People profiles, as for `ExportPeople`.
+type ExportAllOptions struct {
+	MoreArgs     *url.Values
+	EventOptions ExportOptions
+	Where        string
+}
+
+// AllStats combines the statistics of the two halves of `ExportAll`.
+type AllStats struct {
+	Events   Stats
+	Profiles int
+}
+
+// ExportAll exports both the given day of events and every People profile
+// over the same channel, for one shot snapshots of a project. Each record is
+// tagged with `RecordTypeKey` set to `RecordTypeEvent` or `RecordTypeProfile`.
+//
+// All events are sent first, then all profiles. If the events fail, profiles
+// aren't exported at all.
+func (m *Mixpanel) ExportAll(ctx context.Context, date time.Time, output chan<- EventData, opts ExportAllOptions) (AllStats, error) {
+	var stats AllStats
+
+	args, err := opts.EventOptions.Args()
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", m.Product, err)
+	}
+
+	if opts.MoreArgs != nil {
+		for k, vs := range *opts.MoreArgs {
+			(*args)[k] = vs
+		}
+	}
+
+	tagRecords(ctx, output, RecordTypeEvent, func(tagged chan<- EventData) {
+		stats.Events, err = m.ExportDate(ctx, date, tagged, args)
+	})
+
+	if err != nil {
+		return stats, err
+	}
+
+	tagRecords(ctx, output, RecordTypeProfile, func(tagged chan<- EventData) {
+		stats.Profiles, err = m.ExportPeople(ctx, tagged, opts.Where)
+	})
+
+	return stats, err
+}
+
+// tagRecords runs `export`, tagging everything it sends with the given record
+// type before passing it on to `output`. Returns once every record has been
+// passed on, or `ctx` is done.
+func tagRecords(ctx context.Context, output chan<- EventData, recordType string, export func(chan<- EventData)) {
+	tagged := make(chan EventData, 100)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		relay(ctx, tagged, output, func(record EventData) bool {
+			stamp(record, RecordTypeKey, recordType)
+			return true
+		})
+	}()
+
+	export(tagged)
+	close(tagged)
+	<-done
+}
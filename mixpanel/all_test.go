@@ -0,0 +1,45 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportAll(t *testing.T) {
+	people := newPeopleServer(t, 3, 10)
+	defer people.Close()
+
+	events := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat(`{"event": "a", "properties": {"time": 1388534400}}`+"\n", 2))
+	}))
+	defer events.Close()
+
+	mix := NewWithURL("product", "key", "secret", events.URL)
+	mix.Endpoints.Query = people.URL
+
+	output := make(chan EventData, 10)
+
+	stats, err := mix.ExportAll(context.Background(), time.Now(), output, ExportAllOptions{})
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+	close(output)
+
+	if stats.Events.EventsExported != 2 || stats.Profiles != 3 {
+		t.Errorf("expected 2 events and 3 profiles, got %+v", stats)
+	}
+
+	var types []string
+	for record := range output {
+		types = append(types, record[RecordTypeKey].(string))
+	}
+
+	if got := strings.Join(types, ","); got != "event,event,profile,profile,profile" {
+		t.Errorf("expected events then profiles, got %s", got)
+	}
+}
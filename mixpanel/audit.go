@@ -0,0 +1,170 @@
+package mixpanel
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Redacted replaces credentials in an `AuditRecord`.
+const Redacted = "REDACTED"
+
+// Query (and form) arguments which hold credentials, and are never included
+// in an `AuditRecord` as is. The `X-Amz-` arguments sign the presigned URLs
+// export job results are downloaded from.
+var redactedArgs = []string{
+	"sig", "api_key", "secret",
+	"X-Amz-Signature", "X-Amz-Credential", "X-Amz-Security-Token",
+}
+
+// Headers with any of these in their (lower cased) name are redacted. Besides
+// `Authorization`, this catches the likes of API tokens passed in `Headers`.
+var redactedHeaderWords = []string{"auth", "cookie", "token", "secret", "key", "signature"}
+
+// AuditRecord describes a single request sent to Mixpanel, with its
+// credentials redacted, for `AuditLogger`.
+//
+// - `ID` is shared by the attempts (see `ThrottleRetries`) and redirects of
+//   one request.
+// - `Attempt` counts from 1, and is 0 for a redirect being followed.
+// - `Args` holds the arguments of POST requests, which are sent in the body
+//   rather than in `URL`.
+// - `FromDate` and `ToDate` are the date window asked for, if any.
+type AuditRecord struct {
+	ID       string      `json:"id"`
+	Time     time.Time   `json:"time"`
+	Product  string      `json:"product"`
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Args     url.Values  `json:"args,omitempty"`
+	Header   http.Header `json:"header,omitempty"`
+	FromDate string      `json:"from_date,omitempty"`
+	ToDate   string      `json:"to_date,omitempty"`
+	Attempt  int         `json:"attempt"`
+}
+
+// auditKey is the context key of the ID of the request being sent.
+type auditKey struct{}
+
+// withAuditID returns a copy of `ctx` carrying a new request ID, if requests
+// are being audited.
+func (m *Mixpanel) withAuditID(ctx context.Context) context.Context {
+	if m.AuditLogger == nil {
+		return ctx
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		// Only ever used to correlate records, so a poor ID beats none.
+		return context.WithValue(ctx, auditKey{}, fmt.Sprintf("%x", m.now().UnixNano()))
+	}
+
+	return context.WithValue(ctx, auditKey{}, fmt.Sprintf("%x", id))
+}
+
+// audit passes the redacted record of `req` to `AuditLogger`, if set.
+func (m *Mixpanel) audit(req *http.Request, attempt int) {
+	if m.AuditLogger == nil {
+		return
+	}
+
+	id, _ := req.Context().Value(auditKey{}).(string)
+
+	record := AuditRecord{
+		ID:      id,
+		Time:    m.now(),
+		Product: m.Product,
+		Method:  req.Method,
+		Header:  redactHeader(req.Header),
+		Attempt: attempt,
+	}
+
+	record.URL = redactURL(req.URL.String())
+
+	args := req.URL.Query()
+	if form := requestForm(req); form != nil {
+		args = form
+		record.Args = redactArgs(form)
+	}
+
+	record.FromDate, record.ToDate = args.Get("from_date"), args.Get("to_date")
+
+	m.AuditLogger(record)
+}
+
+// requestForm returns the form encoded arguments in the body of `req`, if
+// any, without consuming it.
+func requestForm(req *http.Request) url.Values {
+	if req.GetBody == nil || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+
+	var r io.Reader = body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		if r, err = gzip.NewReader(body); err != nil {
+			return nil
+		}
+	}
+
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil
+	}
+
+	form, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return nil
+	}
+
+	return form
+}
+
+// redactArgs returns a copy of `args` with credentials redacted.
+func redactArgs(args url.Values) url.Values {
+	redacted := make(url.Values, len(args))
+	for k, vs := range args {
+		redacted[k] = append([]string(nil), vs...)
+	}
+
+	for _, k := range redactedArgs {
+		if _, ok := redacted[k]; ok {
+			redacted.Set(k, Redacted)
+		}
+	}
+
+	return redacted
+}
+
+// redactHeader returns a copy of `header` with credentials redacted.
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	if redacted == nil {
+		return nil
+	}
+
+	for k := range redacted {
+		name := strings.ToLower(k)
+
+		for _, word := range redactedHeaderWords {
+			if strings.Contains(name, word) {
+				redacted[k] = []string{Redacted}
+				break
+			}
+		}
+	}
+
+	return redacted
+}
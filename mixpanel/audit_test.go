@@ -0,0 +1,77 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLogger(t *testing.T) {
+	var sigs []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sigs = append(sigs, r.Form.Get("sig"))
+	}))
+	defer server.Close()
+
+	var records []AuditRecord
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Headers = http.Header{"X-Api-Token": {"token"}, "X-Correlation-Id": {"run"}}
+	mix.AuditLogger = func(record AuditRecord) {
+		records = append(records, record)
+	}
+
+	date := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := mix.ExportDate(context.Background(), date, make(chan EventData, 1), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	// The same again, as a form encoded POST.
+	mix.MaxURLLength = 10
+	mix.LongURLPolicy = LongURLPost
+
+	if _, err := mix.ExportDate(context.Background(), date, make(chan EventData, 1), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 audit records, got %d", len(records))
+	}
+
+	for i, record := range records {
+		encoded, _ := json.Marshal(record)
+
+		for _, secret := range []string{sigs[i], `"key"`, "=key", "token"} {
+			if strings.Contains(string(encoded), secret) {
+				t.Errorf("record %d leaks %q: %s", i, secret, encoded)
+			}
+		}
+
+		if record.Product != "product" || record.FromDate != "2020-03-01" || record.ToDate != "2020-03-01" {
+			t.Errorf("record %d lost the product or dates: %s", i, encoded)
+		}
+
+		if record.ID == "" || record.Attempt != 1 || record.Header.Get("X-Correlation-Id") != "run" {
+			t.Errorf("record %d is incomplete: %s", i, encoded)
+		}
+	}
+
+	if records[0].Method != "GET" || !strings.Contains(records[0].URL, "sig="+Redacted) {
+		t.Errorf("expected a redacted GET, got %+v", records[0])
+	}
+
+	if records[1].Method != "POST" || records[1].Args.Get("sig") != Redacted || records[1].Args.Get("api_key") != Redacted {
+		t.Errorf("expected a redacted POST, got %+v", records[1])
+	}
+
+	if records[0].ID == records[1].ID {
+		t.Error("expected separate requests to have separate IDs")
+	}
+}
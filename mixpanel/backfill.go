@@ -0,0 +1,155 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Default delay between attempts at exporting a failed day.
+const DefaultBackfillRetryDelay = 30 * time.Second
+
+// DaySink opens the `Sink` for a single attempt at exporting `date`.
+//
+// A failed day is retried from scratch with a fresh sink, so if an attempt
+// fails part way through, the next attempt's sink will see that day's events
+// again. It is up to the sink to make that safe, for example by truncating a
+// per day output file whenever it's opened. Returning an error fails the
+// attempt.
+type DaySink func(date time.Time) (Sink, error)
+
+// BackfillOptions configures a `Backfill`.
+//
+// - `Concurrency` is the number of days exported at once. Defaults to 1.
+// - `Retries` is the number of times a failed day is retried.
+// - `RetryDelay` is how long to wait between attempts. Defaults to
+//   `DefaultBackfillRetryDelay`.
+// - `Checkpoint`, if set, is used to skip days which were already completed
+//   and to record days as they complete.
+type BackfillOptions struct {
+	Concurrency int
+	Retries     int
+	RetryDelay  time.Duration
+	Checkpoint  Checkpoint
+}
+
+// DayResult is the outcome of backfilling a single day.
+type DayResult struct {
+	Date     time.Time
+	Stats    Stats
+	Attempts int
+	Skipped  bool
+	Err      error
+}
+
+// Backfill exports every day from `from` through `to` (inclusive), writing
+// each day's events to a sink opened with `sink`.
+//
+// Days are exported with bounded concurrency, failed days are retried, and
+// completed days are recorded in (and skipped according to) the checkpoint.
+// Throttling is handled per request in the same way as for `ExportDate`.
+//
+// Returns a result for every day in the range, in date order, and an error if
+// any day ultimately failed.
+func (m *Mixpanel) Backfill(ctx context.Context, from, to time.Time, sink DaySink, opts BackfillOptions) ([]DayResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var results []DayResult
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		results = append(results, DayResult{Date: date})
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Each worker owns the results it's handed, so no
+			// locking needed.
+			for i := range indexes {
+				m.backfillDay(ctx, &results[i], sink, opts)
+			}
+		}()
+	}
+
+feed:
+	for i := range results {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	failed := 0
+	for i := range results {
+		if results[i].Err == nil && !results[i].Skipped && results[i].Attempts == 0 {
+			results[i].Err = ctx.Err()
+		}
+
+		if results[i].Err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%s: backfill failed for %d of %d days", m.Product, failed, len(results))
+	}
+
+	return results, nil
+}
+
+// backfillDay exports a single day of a backfill, retrying on failure.
+func (m *Mixpanel) backfillDay(ctx context.Context, result *DayResult, sink DaySink, opts BackfillOptions) {
+	if opts.Checkpoint != nil && opts.Checkpoint.Completed(result.Date) {
+		result.Skipped = true
+		return
+	}
+
+	delay := opts.RetryDelay
+	if delay <= 0 {
+		delay = DefaultBackfillRetryDelay
+	}
+
+	for result.Attempts <= opts.Retries {
+		if result.Attempts > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				result.Err = ctx.Err()
+				return
+			}
+		}
+
+		result.Attempts++
+
+		if result.Stats, result.Err = m.exportDay(ctx, result.Date, sink); result.Err == nil {
+			break
+		}
+	}
+
+	if result.Err == nil && opts.Checkpoint != nil {
+		result.Err = opts.Checkpoint.MarkCompleted(result.Date)
+	}
+}
+
+// exportDay runs a single attempt at exporting `date` into a sink opened
+// with `open`. The attempt is abandoned as soon as the sink fails.
+func (m *Mixpanel) exportDay(ctx context.Context, date time.Time, open DaySink) (Stats, error) {
+	sink, err := open(date)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return m.ExportDateToSink(ctx, date, sink, nil)
+}
@@ -0,0 +1,198 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackfill(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = make(map[string]int)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		day := r.URL.Query().Get("from_date")
+
+		mu.Lock()
+		requests[day]++
+		attempt := requests[day]
+		mu.Unlock()
+
+		// The 5th fails the first time around.
+		if day == "2014-01-05" && attempt == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error": "try again"}`)
+			return
+		}
+
+		fmt.Fprintf(w, `{"event": "a", "properties": {"day": "%s"}}`, day)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "mixport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	checkpoint, err := NewFileCheckpoint(filepath.Join(dir, "checkpoint"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend the first day was done by an earlier run.
+	from := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	checkpoint.MarkCompleted(from)
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	// The last sink opened for each day.
+	var sinks sync.Map
+	sink := func(date time.Time) (Sink, error) {
+		s := &memorySink{}
+		sinks.Store(date.Format("2006-01-02"), s)
+		return s, nil
+	}
+
+	results, err := mix.Backfill(context.Background(), from, from.AddDate(0, 0, 9), sink, BackfillOptions{
+		Concurrency: 3,
+		Retries:     2,
+		RetryDelay:  time.Millisecond,
+		Checkpoint:  checkpoint,
+	})
+
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if len(results) != 10 {
+		t.Fatalf("expected 10 results, got %d", len(results))
+	}
+
+	for i, result := range results {
+		day := from.AddDate(0, 0, i).Format("2006-01-02")
+
+		switch {
+		case i == 0:
+			if !result.Skipped {
+				t.Errorf("%s: expected to be skipped", day)
+			}
+		case day == "2014-01-05":
+			if result.Attempts != 2 || result.Err != nil {
+				t.Errorf("%s: expected success on 2nd attempt, got %+v", day, result)
+			}
+		default:
+			if result.Attempts != 1 || result.Err != nil || result.Stats.EventsExported != 1 {
+				t.Errorf("%s: expected success on 1st attempt, got %+v", day, result)
+			}
+		}
+
+		if s, ok := sinks.Load(day); i > 0 && (!ok || len(s.(*memorySink).events) != 1 || !strings.Contains(string(s.(*memorySink).events[0]), day)) {
+			t.Errorf("%s: events didn't reach the sink", day)
+		}
+
+		if !checkpoint.Completed(result.Date) {
+			t.Errorf("%s: expected checkpoint to be marked", day)
+		}
+	}
+
+	// A new run should pick the checkpoint up from disk and skip
+	// everything.
+	reloaded, _ := NewFileCheckpoint(filepath.Join(dir, "checkpoint"))
+	for i := 0; i < 10; i++ {
+		if !reloaded.Completed(from.AddDate(0, 0, i)) {
+			t.Errorf("expected day %d in reloaded checkpoint", i)
+		}
+	}
+}
+
+func TestBackfillGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	sink := func(date time.Time) (Sink, error) {
+		return &memorySink{}, nil
+	}
+
+	from := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	results, err := mix.Backfill(context.Background(), from, from, sink, BackfillOptions{
+		Retries:    1,
+		RetryDelay: time.Millisecond,
+	})
+
+	if err == nil {
+		t.Error("expected error")
+	} else if results[0].Attempts != 2 || results[0].Err == nil {
+		t.Errorf("expected 2 failed attempts, got %+v", results[0])
+	}
+}
+
+func TestBackfillSinkFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(w, `{"event": "e%d", "properties": {}}`+"\n", i)
+		}
+		w.(http.Flusher).Flush()
+
+		// Only a cancelled export gets past this.
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	failure := errors.New("disk full")
+	var opened []*memorySink
+
+	sink := func(date time.Time) (Sink, error) {
+		s := &memorySink{writeErr: failure}
+		opened = append(opened, s)
+		return s, nil
+	}
+
+	from := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	done := make(chan struct{})
+	var (
+		results []DayResult
+		err     error
+	)
+
+	go func() {
+		results, err = mix.Backfill(context.Background(), from, from, sink, BackfillOptions{
+			Retries:    1,
+			RetryDelay: time.Millisecond,
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("export kept running after the sink failed")
+	}
+
+	if err == nil {
+		t.Error("expected error")
+	} else if !errors.Is(results[0].Err, failure) || results[0].Attempts != 2 {
+		t.Errorf("expected 2 attempts failing with the sink's error, got %+v", results[0])
+	}
+
+	for i, s := range opened {
+		if len(s.events) != 1 || s.calls[len(s.calls)-1] != "close" {
+			t.Errorf("attempt %d: expected one write then close, got %v", i+1, s.calls)
+		}
+	}
+}
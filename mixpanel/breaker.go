@@ -0,0 +1,151 @@
+package mixpanel
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without making a request while a
+// `CircuitBreaker` is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Defaults used by a `CircuitBreaker` with zero valued settings.
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = time.Minute
+)
+
+// CircuitBreaker stops requests from being made at all once Mixpanel has
+// failed (network errors, 5xx responses, or exhausted throttling retries)
+// `Threshold` times in a row, so that a sustained outage fails fast instead
+// of being hammered with retries.
+//
+// Once `Cooldown` has passed, a single probe request is let through: if it
+// succeeds the breaker closes again, and if it fails the breaker stays open
+// for another cooldown.
+//
+// A breaker can be shared by several Mixpanel structs (say, every product on
+// the same account) and is safe for concurrent use.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	// Clock is used to time the cooldown. Defaults to the real wall clock.
+	Clock Clock
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	open     bool
+	probing  bool
+	probe    uint64
+}
+
+// NewCircuitBreaker creates a breaker which opens after `threshold`
+// consecutive failures, for `cooldown` at a time.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+func (b *CircuitBreaker) now() time.Time {
+	if b.Clock == nil {
+		return realClock{}.Now()
+	}
+
+	return b.Clock.Now()
+}
+
+// allow reports whether a request may be made right now, returning
+// `ErrCircuitOpen` if not. A nil breaker allows everything.
+//
+// If the request is the probe of an open breaker, a non-zero `probe` is
+// returned, which has to be handed back to `record` or `abandon`.
+func (b *CircuitBreaker) allow() (probe uint64, err error) {
+	if b == nil {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return 0, nil
+	}
+
+	cooldown := b.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+
+	if b.probing || b.now().Before(b.openedAt.Add(cooldown)) {
+		return 0, ErrCircuitOpen
+	}
+
+	b.probing = true
+	b.probe++
+
+	return b.probe, nil
+}
+
+// record notes the outcome of a request that `allow` let through.
+//
+// While the breaker is open, only the current probe counts. Requests which
+// were let through before it opened say nothing about whether Mixpanel has
+// recovered since, so their outcomes are ignored.
+func (b *CircuitBreaker) record(probe uint64, failed bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	threshold := b.Threshold
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+
+	if b.open {
+		if !b.probing || probe != b.probe {
+			return
+		}
+
+		b.probing = false
+	}
+
+	if !failed {
+		b.failures = 0
+		b.open = false
+		return
+	}
+
+	b.failures++
+
+	if b.open || b.failures >= threshold {
+		b.open = true
+		b.openedAt = b.now()
+	}
+}
+
+// abandon notes that a request `allow` let through ended without telling us
+// anything, so that another probe can be made if it was one.
+func (b *CircuitBreaker) abandon(probe uint64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	if b.probing && probe == b.probe {
+		b.probing = false
+	}
+	b.mu.Unlock()
+}
+
+// breakerFailure reports whether the outcome of a request counts as a
+// failure as far as the circuit breaker is concerned. Client errors are our
+// own fault, so only server errors and network failures count.
+func breakerFailure(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= 500
+}
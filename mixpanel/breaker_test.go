@@ -0,0 +1,165 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock is a Clock which only moves when told to.
+type manualClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.t
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.t = c.t.Add(d)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		healthy  bool
+		requests int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests++
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprint(w, `{"event": "a", "properties": {}}`)
+	}))
+	defer server.Close()
+
+	clock := &manualClock{t: time.Unix(1388534400, 0)}
+
+	breaker := NewCircuitBreaker(3, time.Minute)
+	breaker.Clock = clock
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Breaker = breaker
+
+	export := func() error {
+		_, err := mix.ExportDate(context.Background(), clock.Now(), make(chan EventData, 1), nil)
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := export(); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("attempt %d: expected a server error, got %v", i, err)
+		}
+	}
+
+	// Tripped: no more requests should reach the server.
+	for i := 0; i < 5; i++ {
+		if err := export(); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected ErrCircuitOpen, got %v", err)
+		}
+	}
+
+	if requests != 3 {
+		t.Errorf("expected 3 requests to reach the server, got %d", requests)
+	}
+
+	// A failed probe after the cooldown keeps it open.
+	clock.Advance(time.Minute)
+
+	if err := export(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected probe to fail with a server error, got %v", err)
+	} else if err := export(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen after failed probe, got %v", err)
+	}
+
+	// A successful probe closes it again.
+	clock.Advance(time.Minute)
+
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+
+	for i := 0; i < 3; i++ {
+		if err := export(); err != nil {
+			t.Fatalf("expected recovery, got %v", err)
+		}
+	}
+
+	if requests != 7 {
+		t.Errorf("expected 7 requests to reach the server, got %d", requests)
+	}
+}
+
+func TestCircuitBreakerStaleRequest(t *testing.T) {
+	clock := &manualClock{t: time.Unix(1388534400, 0)}
+
+	breaker := NewCircuitBreaker(1, time.Minute)
+	breaker.Clock = clock
+
+	// Let a request through while closed, which is slow to finish.
+	stale, err := breaker.allow()
+	if err != nil || stale != 0 {
+		t.Fatalf("expected a plain request while closed, got %d, %v", stale, err)
+	}
+
+	if _, err := breaker.allow(); err != nil {
+		t.Fatal(err)
+	}
+	breaker.record(0, true)
+
+	clock.Advance(2 * time.Minute)
+
+	probe, err := breaker.allow()
+	if err != nil || probe == 0 {
+		t.Fatalf("expected a probe, got %d, %v", probe, err)
+	}
+
+	// The old request finishing must neither end the probe nor close the
+	// breaker.
+	breaker.record(stale, false)
+	breaker.abandon(stale)
+
+	if _, err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected a second probe to be refused, got %v", err)
+	}
+
+	breaker.record(probe, false)
+
+	if next, err := breaker.allow(); err != nil || next != 0 {
+		t.Errorf("expected the probe to close the breaker, got %d, %v", next, err)
+	}
+
+	// A probe finishing late, after the breaker has reopened, doesn't
+	// count for the new probe either.
+	breaker.record(0, true)
+	clock.Advance(2 * time.Minute)
+
+	if _, err := breaker.allow(); err != nil {
+		t.Fatal(err)
+	}
+
+	breaker.record(probe, false)
+
+	if _, err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected an old probe not to close the breaker, got %v", err)
+	}
+}
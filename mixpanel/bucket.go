@@ -0,0 +1,34 @@
+package mixpanel
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrBucketUnsupported is returned for requests made with a `Bucket` to an
+// endpoint which doesn't take one. Sending them without it would silently
+// query the default bucket instead.
+var ErrBucketUnsupported = errors.New("endpoint doesn't support data buckets")
+
+// bucketEndpoint reports whether `endpoint` reads raw data, and so takes a
+// `bucket`: the raw export and export jobs (including their status).
+func bucketEndpoint(endpoint string) bool {
+	return endpoint == EndpointExport || endpoint == EndpointExportJobs || strings.HasPrefix(endpoint, EndpointExportJobs+"/")
+}
+
+// setBucket adds `Bucket` to the arguments of a request to `endpoint`, if set,
+// failing if the endpoint doesn't support it.
+func (m *Mixpanel) setBucket(endpoint string, args url.Values) error {
+	if m.Bucket == "" {
+		return nil
+	}
+
+	if !bucketEndpoint(endpoint) {
+		return fmt.Errorf("%s: %s: %w", m.Product, endpoint, ErrBucketUnsupported)
+	}
+
+	args.Set("bucket", m.Bucket)
+	return nil
+}
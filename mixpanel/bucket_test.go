@@ -0,0 +1,57 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	var query url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Endpoints.Query = server.URL
+	mix.Bucket = "emea"
+
+	if _, err := mix.ExportDate(context.Background(), time.Now(), make(chan EventData, 1), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if query.Get("bucket") != "emea" {
+		t.Fatalf("expected the bucket to be sent, got %v", query)
+	}
+
+	// Re-signing what was sent must give the same signature, which it
+	// only does if the bucket was signed too.
+	sig := query.Get("sig")
+	mix.addSignature(&query, mix.Secret)
+
+	if query.Get("sig") != sig {
+		t.Error("bucket wasn't included in the signature")
+	}
+
+	query.Del("bucket")
+	mix.addSignature(&query, mix.Secret)
+
+	if query.Get("sig") == sig {
+		t.Error("signature doesn't depend on the bucket")
+	}
+
+	// Queries can't be limited to a bucket.
+	query = nil
+
+	if _, _, err := mix.VerifyDate(context.Background(), time.Now()); !errors.Is(err, ErrBucketUnsupported) {
+		t.Errorf("expected ErrBucketUnsupported, got %v", err)
+	} else if query != nil {
+		t.Error("expected no request to be sent")
+	}
+}
@@ -0,0 +1,93 @@
+package mixpanel
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Checkpoint keeps track of which days have been fully exported, so that an
+// interrupted backfill can pick up where it left off.
+type Checkpoint interface {
+	// Completed reports whether `date` has already been exported.
+	Completed(date time.Time) bool
+
+	// MarkCompleted records that `date` has been exported.
+	MarkCompleted(date time.Time) error
+}
+
+// FileCheckpoint is a Checkpoint persisted to a plain text file, with one
+// completed `YYYY-MM-DD` date per line. It is safe for concurrent use.
+type FileCheckpoint struct {
+	path string
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+// NewFileCheckpoint loads the checkpoint stored in `path`, which doesn't need
+// to exist yet.
+func NewFileCheckpoint(path string) (*FileCheckpoint, error) {
+	c := &FileCheckpoint{path: path, done: make(map[string]bool)}
+
+	fp, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	defer fp.Close()
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			c.done[line] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	return c, nil
+}
+
+// Completed reports whether `date` has already been exported.
+func (c *FileCheckpoint) Completed(date time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.done[date.Format("2006-01-02")]
+}
+
+// MarkCompleted records that `date` has been exported, appending it to the
+// checkpoint file.
+func (c *FileCheckpoint) MarkCompleted(date time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	day := date.Format("2006-01-02")
+	if c.done[day] {
+		return nil
+	}
+
+	fp, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+
+	if _, err := fmt.Fprintln(fp, day); err != nil {
+		fp.Close()
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+
+	if err := fp.Close(); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+
+	c.done[day] = true
+	return nil
+}
@@ -0,0 +1,81 @@
+package mixpanel
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+)
+
+// checksummer computes the SHA-256 of the events written by `StreamDate` and
+// `ExportDateToSink`, for `Checksum` and `ChecksumByEvent`.
+//
+// A nil checksummer computes nothing.
+type checksummer struct {
+	eventKey string
+	all      hash.Hash
+	byEvent  map[string]hash.Hash
+}
+
+// newChecksummer returns a checksummer if one has been asked for.
+func (m *Mixpanel) newChecksummer() *checksummer {
+	if !m.Checksum && !m.ChecksumByEvent {
+		return nil
+	}
+
+	c := &checksummer{eventKey: m.eventKey()}
+
+	if m.ImportShape {
+		c.eventKey = "event"
+	}
+
+	if m.Checksum {
+		c.all = sha256.New()
+	}
+
+	if m.ChecksumByEvent {
+		c.byEvent = make(map[string]hash.Hash)
+	}
+
+	return c
+}
+
+// add hashes `line`, the bytes written for `event`.
+func (c *checksummer) add(event EventData, line []byte) {
+	if c == nil {
+		return
+	}
+
+	if c.all != nil {
+		c.all.Write(line)
+	}
+
+	if c.byEvent != nil {
+		name := fmt.Sprintf("%v", event[c.eventKey])
+
+		h := c.byEvent[name]
+		if h == nil {
+			h = sha256.New()
+			c.byEvent[name] = h
+		}
+
+		h.Write(line)
+	}
+}
+
+// fill sets the checksums of `stats`.
+func (c *checksummer) fill(stats *Stats) {
+	if c == nil {
+		return
+	}
+
+	if c.all != nil {
+		stats.Checksum = fmt.Sprintf("%x", c.all.Sum(nil))
+	}
+
+	if c.byEvent != nil {
+		stats.EventChecksums = make(map[string]string, len(c.byEvent))
+		for name, h := range c.byEvent {
+			stats.EventChecksums[name] = fmt.Sprintf("%x", h.Sum(nil))
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// checksumOf returns the hex encoded SHA-256 of `data`.
+func checksumOf(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// eventChecksums independently computes the per event type checksums of a
+// stream of JSON lines.
+func eventChecksums(t *testing.T, stream []byte) map[string]string {
+	byEvent := make(map[string][]byte)
+
+	for _, line := range bytes.SplitAfter(stream, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		var event struct{ Event string }
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatal(err)
+		}
+
+		byEvent[event.Event] = append(byEvent[event.Event], line...)
+	}
+
+	sums := make(map[string]string)
+	for name, data := range byEvent {
+		sums[name] = checksumOf(data)
+	}
+
+	return sums
+}
+
+func TestStreamDateChecksum(t *testing.T) {
+	server := newSinkServer(25)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Checksum = true
+	mix.ChecksumByEvent = true
+
+	var out bytes.Buffer
+
+	stats, err := mix.StreamDate(context.Background(), time.Now(), &out, nil)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if stats.Checksum != checksumOf(out.Bytes()) {
+		t.Errorf("checksum %s doesn't match the %d bytes written", stats.Checksum, out.Len())
+	}
+
+	expected := eventChecksums(t, out.Bytes())
+	if len(stats.EventChecksums) != 25 {
+		t.Errorf("expected 25 event checksums, got %d", len(stats.EventChecksums))
+	}
+
+	for name, sum := range expected {
+		if stats.EventChecksums[name] != sum {
+			t.Errorf("%s: expected checksum %s, got %s", name, sum, stats.EventChecksums[name])
+		}
+	}
+}
+
+func TestExportDateToSinkChecksum(t *testing.T) {
+	server := newSinkServer(10)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Checksum = true
+	sink := &memorySink{}
+
+	stats, err := mix.ExportDateToSink(context.Background(), time.Now(), sink, nil)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	var lines []byte
+	for _, event := range sink.events {
+		lines = append(append(lines, event...), '\n')
+	}
+
+	if stats.Checksum != checksumOf(lines) {
+		t.Errorf("checksum %s doesn't match the events written", stats.Checksum)
+	} else if stats.EventChecksums != nil {
+		t.Errorf("expected no per event checksums, got %v", stats.EventChecksums)
+	}
+
+	// Nothing is computed unless asked for.
+	mix.Checksum = false
+
+	if stats, err := mix.ExportDateToSink(context.Background(), time.Now(), &memorySink{}, nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.Checksum != "" {
+		t.Errorf("expected no checksum, got %s", stats.Checksum)
+	}
+}
@@ -0,0 +1,26 @@
+package mixpanel
+
+import "time"
+
+// Clock is the source of the current time for everything time dependent in
+// this package (request expiry, for example). Tests can swap in a fixed clock
+// to get deterministic results.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the default Clock, simply deferring to the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns the current time according to the configured Clock.
+func (m *Mixpanel) now() time.Time {
+	if m.Clock == nil {
+		return realClock{}.Now()
+	}
+
+	return m.Clock.Now()
+}
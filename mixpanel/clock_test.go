@@ -0,0 +1,25 @@
+package mixpanel
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock that is always stuck at the same instant.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestFixedClockExpire(t *testing.T) {
+	mix := New("product", "key", "secret")
+	mix.Clock = fixedClock(time.Unix(1000000000, 0))
+
+	date, _ := time.Parse("2006-01-02", "1999-12-31")
+	args := mix.makeArgs(date)
+
+	if expire := args.Get("expire"); expire != "1000010000" {
+		t.Errorf("Expected expire=1000010000, got %s", expire)
+	}
+}
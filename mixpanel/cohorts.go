@@ -0,0 +1,39 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Cohort is a saved cohort of People profiles, as listed by `ListCohorts`.
+// `Count` is the number of profiles in it when Mixpanel last computed it.
+type Cohort struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ListCohorts lists the cohorts saved in the project.
+func (m *Mixpanel) ListCohorts(ctx context.Context) ([]Cohort, error) {
+	var cohorts []Cohort
+
+	// Mixpanel only documents POST for this one.
+	if err := m.request(ctx, "POST", EndpointCohorts, m.baseArgs(), &cohorts); err != nil {
+		return nil, err
+	}
+
+	return cohorts, nil
+}
+
+// ExportCohort downloads the People profiles belonging to the cohort
+// `cohortID` (see `ListCohorts`), streaming each over the `output` channel
+// just like `ExportPeople`.
+//
+// Returns the number of profiles that have been processed and possibly an
+// error.
+func (m *Mixpanel) ExportCohort(ctx context.Context, cohortID int, output chan<- EventData) (int, error) {
+	filter := &url.Values{"filter_by_cohort": {fmt.Sprintf(`{"id": %d}`, cohortID)}}
+
+	return m.exportPeoplePages(ctx, output, filter, "", 0)
+}
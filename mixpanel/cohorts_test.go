@@ -0,0 +1,77 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestListCohorts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cohorts/list" || r.Method != "POST" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		fmt.Fprint(w, `[
+			{"count": 150, "description": "", "created": "2019-03-19 23:49:51", "id": 1000, "is_visible": 1, "name": "Cohort One", "project_id": 1},
+			{"count": 25, "description": "Big spenders", "created": "2019-04-02 23:22:01", "id": 2000, "is_visible": 0, "name": "Cohort Two", "project_id": 1}
+		]`)
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	cohorts, err := mix.ListCohorts(context.Background())
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := []Cohort{{ID: 1000, Name: "Cohort One", Count: 150}, {ID: 2000, Name: "Cohort Two", Count: 25}}
+	if !reflect.DeepEqual(cohorts, expected) {
+		t.Errorf("expected %v, got %v", expected, cohorts)
+	}
+}
+
+func TestExportCohort(t *testing.T) {
+	people := newPeopleServer(t, 25, 10)
+	defer people.Close()
+
+	var filters []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters = append(filters, r.URL.Query().Get("filter_by_cohort"))
+		people.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	output := make(chan EventData, 100)
+	done := make(chan map[string]int)
+	go collectPeople(output, done)
+
+	total, err := mix.ExportCohort(context.Background(), 1000, output)
+	close(output)
+	seen := <-done
+
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if total != 25 || len(seen) != 25 {
+		t.Errorf("expected 25 profiles, got %d (%d distinct)", total, len(seen))
+	}
+
+	if len(filters) != 3 {
+		t.Fatalf("expected 3 page requests, got %d", len(filters))
+	}
+
+	for i, filter := range filters {
+		if filter != `{"id": 1000}` {
+			t.Errorf("page %d: expected the cohort filter, got %q", i, filter)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BucketDiff compares the total count of one bucket (or segment) of a
+// segmentation over two windows.
+//
+// - `A` and `B` are the totals over each window.
+// - `Delta` is `B - A`.
+// - `PercentChange` is `Delta` as a percentage of `A`. It's left zero when
+//   `A` is zero, where there is no percentage to speak of.
+type BucketDiff struct {
+	Label         string
+	A             float64
+	B             float64
+	Delta         float64
+	PercentChange float64
+}
+
+// WindowDiff is the result of `CompareWindows`: the event and windows
+// compared, and a diff per bucket seen in either window, ordered as in
+// `NumericSegmentation`.
+type WindowDiff struct {
+	Event   string
+	WindowA [2]time.Time
+	WindowB [2]time.Time
+	Buckets []BucketDiff
+}
+
+// CompareWindows counts the occurrences of `eventName` over two windows,
+// each the days from its first through its second date (inclusive), and
+// compares them, for before and after or A/B analysis. Without a property to
+// segment by (see `CompareWindowsOn`), there is a single bucket, labelled
+// with the event name.
+func (m *Mixpanel) CompareWindows(ctx context.Context, eventName string, windowA, windowB [2]time.Time) (*WindowDiff, error) {
+	return m.CompareWindowsOn(ctx, eventName, "", windowA, windowB)
+}
+
+// CompareWindowsOn is `CompareWindows`, with the counts segmented by the
+// expression `on` (such as `properties["plan"]`) and compared per segment.
+func (m *Mixpanel) CompareWindowsOn(ctx context.Context, eventName, on string, windowA, windowB [2]time.Time) (*WindowDiff, error) {
+	diff := &WindowDiff{Event: eventName, WindowA: windowA, WindowB: windowB}
+
+	var totals [2]map[string]float64
+
+	for i, window := range [][2]time.Time{windowA, windowB} {
+		if window[1].Before(window[0]) {
+			return nil, fmt.Errorf("%s: window %s to %s ends before it starts", m.Product, window[0].Format("2006-01-02"), window[1].Format("2006-01-02"))
+		}
+
+		result, err := m.segmentationSeries(ctx, EndpointSegmentation, m.segmentationArgs(EndpointSegmentation, eventName, on, window[0], window[1]))
+		if err != nil {
+			return nil, err
+		}
+
+		totals[i] = make(map[string]float64, len(result.Buckets))
+		for _, bucket := range result.Buckets {
+			for _, point := range bucket.Points {
+				totals[i][bucket.Label] += point.Value
+			}
+		}
+	}
+
+	labels := make(map[string]bool)
+	for _, byLabel := range totals {
+		for label := range byLabel {
+			labels[label] = true
+		}
+	}
+
+	for label := range labels {
+		bucket := BucketDiff{Label: label, A: totals[0][label], B: totals[1][label]}
+		bucket.Delta = bucket.B - bucket.A
+
+		if bucket.A != 0 {
+			bucket.PercentChange = bucket.Delta / bucket.A * 100
+		}
+
+		diff.Buckets = append(diff.Buckets, bucket)
+	}
+
+	sort.Slice(diff.Buckets, func(i, j int) bool {
+		return bucketLess(diff.Buckets[i].Label, diff.Buckets[j].Label)
+	})
+
+	return diff, nil
+}
@@ -0,0 +1,83 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompareWindows(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		if r.URL.Path != "/segmentation" {
+			http.NotFound(w, r)
+			return
+		} else if query.Get("event") != "signup" || query.Get("on") != `properties["plan"]` {
+			t.Errorf("unexpected arguments %v", query)
+		}
+
+		switch query.Get("from_date") + " " + query.Get("to_date") {
+		case "2014-01-01 2014-01-02":
+			fmt.Fprint(w, `{"data": {
+				"series": ["2014-01-01", "2014-01-02"],
+				"values": {
+					"free": {"2014-01-01": 10, "2014-01-02": 30},
+					"paid": {"2014-01-01": 5},
+					"trial": {"2014-01-02": 4}
+				}
+			}}`)
+		case "2014-02-01 2014-02-02":
+			fmt.Fprint(w, `{"data": {
+				"series": ["2014-02-01", "2014-02-02"],
+				"values": {
+					"free": {"2014-02-01": 20, "2014-02-02": 30},
+					"paid": {"2014-02-01": 2, "2014-02-02": 1},
+					"team": {"2014-02-02": 3}
+				}
+			}}`)
+		default:
+			t.Errorf("unexpected range %v", query)
+		}
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	windowA := [2]time.Time{day("2014-01-01"), day("2014-01-02")}
+	windowB := [2]time.Time{day("2014-02-01"), day("2014-02-02")}
+
+	diff, err := mix.CompareWindowsOn(context.Background(), "signup", `properties["plan"]`, windowA, windowB)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := []BucketDiff{
+		{Label: "free", A: 40, B: 50, Delta: 10, PercentChange: 25},
+		{Label: "paid", A: 5, B: 3, Delta: -2, PercentChange: -40},
+		{Label: "team", A: 0, B: 3, Delta: 3},
+		{Label: "trial", A: 4, B: 0, Delta: -4, PercentChange: -100},
+	}
+
+	if !reflect.DeepEqual(diff.Buckets, expected) {
+		t.Errorf("expected %+v, got %+v", expected, diff.Buckets)
+	}
+
+	if diff.Event != "signup" || diff.WindowA != windowA || diff.WindowB != windowB {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+
+	if _, err := mix.CompareWindows(context.Background(), "signup", [2]time.Time{windowA[1], windowA[0]}, windowB); err == nil {
+		t.Error("expected an error for a backwards window")
+	}
+}
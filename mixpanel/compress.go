@@ -0,0 +1,52 @@
+package mixpanel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+)
+
+// DefaultCompressMinBytes is the smallest request body gzipped for the
+// `CompressEndpoints`, unless `CompressMinBytes` says otherwise. Below about
+// this size compressing saves less than it costs.
+const DefaultCompressMinBytes = 1024
+
+// compressMinBytes returns the smallest request body worth compressing.
+func (m *Mixpanel) compressMinBytes() int {
+	if m.CompressMinBytes <= 0 {
+		return DefaultCompressMinBytes
+	}
+
+	return m.CompressMinBytes
+}
+
+// newFormPost builds a POST of the form `encoded` to `url`, for `endpoint`.
+// The body is gzipped (with `Content-Encoding: gzip`) if the endpoint is one
+// of the `CompressEndpoints` and it's at least `CompressMinBytes` long.
+func (m *Mixpanel) newFormPost(url, endpoint, encoded string) (*http.Request, error) {
+	body := []byte(encoded)
+
+	compress := m.CompressEndpoints[endpoint] && len(body) >= m.compressMinBytes()
+	if compress {
+		var buf bytes.Buffer
+
+		// Writing to a bytes.Buffer can't fail.
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, redactError(err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	return req, nil
+}
@@ -0,0 +1,120 @@
+package mixpanel
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// newGunzipServer decompresses gzipped form bodies and records the decoded
+// `data` of each, along with the `Content-Encoding` it was sent with.
+func newGunzipServer(t *testing.T, encodings *[]string, received *[]interface{}) *httptest.Server {
+	var mu sync.Mutex
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body := r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("bad gzip body: %v", err)
+				return
+			}
+
+			body = zr
+		}
+
+		raw, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Errorf("reading body failed: %v", err)
+			return
+		}
+
+		form, err := url.ParseQuery(string(raw))
+		if err != nil {
+			t.Errorf("bad form: %v", err)
+			return
+		}
+
+		var data interface{}
+		decoded, _ := base64.StdEncoding.DecodeString(form.Get("data"))
+		json.Unmarshal(decoded, &data)
+
+		*encodings = append(*encodings, r.Header.Get("Content-Encoding"))
+		*received = append(*received, data)
+
+		fmt.Fprint(w, `{"status": 1, "error": null}`)
+	}))
+}
+
+func TestCompressRequests(t *testing.T) {
+	var (
+		encodings []string
+		received  []interface{}
+	)
+
+	server := newGunzipServer(t, &encodings, &received)
+	defer server.Close()
+
+	var audited []AuditRecord
+
+	mix := New("product", "", "")
+	mix.Endpoints.Ingestion = server.URL
+	mix.Token = "token"
+	mix.CompressEndpoints = map[string]bool{EndpointImport: true}
+	mix.AuditLogger = func(record AuditRecord) {
+		audited = append(audited, record)
+	}
+
+	events := make([]ImportEvent, 100)
+	expected := make([]interface{}, 100)
+
+	for i := range events {
+		events[i] = ImportEvent{Event: "signup", Properties: map[string]interface{}{"distinct_id": fmt.Sprint(i)}}
+		expected[i] = map[string]interface{}{
+			"event":      "signup",
+			"properties": map[string]interface{}{"distinct_id": fmt.Sprint(i), "token": "token"},
+		}
+	}
+
+	// Large enough to be compressed, then too small to be.
+	if err := mix.Import(context.Background(), events); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if err := mix.Import(context.Background(), events[:1]); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	// Not one of the endpoints to compress.
+	if err := mix.PeopleSet(context.Background(), "a", map[string]interface{}{"padding": string(make([]byte, 2048))}); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if expected := []string{"gzip", "", ""}; !reflect.DeepEqual(encodings, expected) {
+		t.Errorf("expected encodings %q, got %q", expected, encodings)
+	}
+
+	if !reflect.DeepEqual(received[0], expected) {
+		t.Errorf("compressed payload didn't round trip: got %v", received[0])
+	}
+
+	if !reflect.DeepEqual(received[1], expected[:1]) {
+		t.Errorf("uncompressed payload didn't round trip: got %v", received[1])
+	}
+
+	if len(audited) != 3 || audited[0].Args.Get("verbose") != "1" {
+		t.Errorf("expected the compressed form to be audited, got %+v", audited)
+	}
+}
@@ -0,0 +1,56 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Meant to be run with `go test -race`.
+func TestConcurrentExportDate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat(`{"event": "a", "properties": {"b": "c", "time": 1388534400}}`+"\n", 50))
+	}))
+	defer server.Close()
+
+	for _, dedup := range []bool{false, true} {
+		mix := NewWithURL("product", "key", "secret", server.URL)
+		mix.DedupRequests = dedup
+		mix.Keep = []string{"b"}
+		mix.HashProperties = map[string]string{"b": "salt"}
+		mix.OnThrottle = func(time.Duration, int) {}
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				date := time.Date(2014, 1, 1+i%3, 0, 0, 0, 0, time.UTC)
+				output := make(chan EventData, 50)
+
+				stats, err := mix.ExportDate(context.Background(), date, output, nil)
+				if err != nil {
+					t.Errorf("dedup=%v: raised error: %v", dedup, err)
+				} else if stats.EventsExported != 50 {
+					t.Errorf("dedup=%v: expected 50 records, got %d", dedup, stats.EventsExported)
+				}
+
+				close(output)
+				for event := range output {
+					// Modifying what we receive must not race
+					// with anyone else.
+					event["seen"] = true
+				}
+			}(i)
+		}
+
+		wg.Wait()
+	}
+}
@@ -0,0 +1,102 @@
+package mixpanel
+
+import (
+	"net/url"
+	"time"
+)
+
+// Endpoint names, used to look up endpoint specific behavior.
+const (
+	EndpointExport       = "export"
+	EndpointExportJobs   = "export/jobs"
+	EndpointEvents       = "events"
+	EndpointSegmentation = "segmentation"
+	EndpointFunnels      = "funnels"
+	EndpointStream       = "stream/query"
+	EndpointEngage       = "engage"
+	EndpointImport       = "import"
+	EndpointCohorts      = "cohorts/list"
+	EndpointJQL          = "jql"
+
+	EndpointSegmentationNumeric = "segmentation/numeric"
+	EndpointSegmentationSum     = "segmentation/sum"
+	EndpointSegmentationAverage = "segmentation/average"
+)
+
+// dateParams are the names of the parameters an endpoint expects the start
+// and end (inclusive) of its date range in.
+type dateParams struct {
+	from, to string
+}
+
+// Every endpoint we currently talk to, including the raw export, documents
+// `from_date` and `to_date`. Keeping the names in one place means callers
+// never need to know them, and an endpoint that differs only needs an entry
+// here.
+var endpointDateParams = map[string]dateParams{
+	EndpointExport:       {"from_date", "to_date"},
+	EndpointExportJobs:   {"from_date", "to_date"},
+	EndpointEvents:       {"from_date", "to_date"},
+	EndpointSegmentation: {"from_date", "to_date"},
+	EndpointFunnels:      {"from_date", "to_date"},
+	EndpointStream:       {"from_date", "to_date"},
+
+	EndpointSegmentationNumeric: {"from_date", "to_date"},
+	EndpointSegmentationSum:     {"from_date", "to_date"},
+	EndpointSegmentationAverage: {"from_date", "to_date"},
+}
+
+// Date parameter names callers commonly pass through `moreArgs`, mapped to
+// whether they refer to the start of the range.
+var dateAliases = map[string]bool{
+	"from_date": true,
+	"start":     true,
+	"to_date":   false,
+	"end":       false,
+}
+
+// paramsFor returns the date parameter names used by `endpoint`.
+func paramsFor(endpoint string) dateParams {
+	if params, ok := endpointDateParams[endpoint]; ok {
+		return params
+	}
+
+	return dateParams{"from_date", "to_date"}
+}
+
+// setDateRange sets the date range of a request for `endpoint`, using
+// whatever parameter names that endpoint expects.
+func setDateRange(args url.Values, endpoint string, from, to time.Time) {
+	params := paramsFor(endpoint)
+
+	args.Set(params.from, from.Format("2006-01-02"))
+	args.Set(params.to, to.Format("2006-01-02"))
+}
+
+// mergeArgs adds the user supplied `moreArgs` to `args`. Any date parameter
+// given under one of the names in `dateAliases` is renamed to what
+// `endpoint` actually expects and replaces the existing value, rather than
+// being passed along under a name the endpoint would silently ignore.
+func mergeArgs(args url.Values, moreArgs *url.Values, endpoint string) {
+	if moreArgs == nil {
+		return
+	}
+
+	params := paramsFor(endpoint)
+
+	for k, vs := range *moreArgs {
+		if isFrom, ok := dateAliases[k]; ok && len(vs) > 0 {
+			if isFrom {
+				args.Set(params.from, vs[0])
+			} else {
+				args.Set(params.to, vs[0])
+			}
+
+			continue
+		}
+
+		for _, v := range vs {
+			args.Add(k, v)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// recordQuery starts a server which records the query of each request made
+// to it, responding with `body`.
+func recordQuery(body string, queries *[]url.Values) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		*queries = append(*queries, r.Form)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestDateParams(t *testing.T) {
+	var queries []url.Values
+
+	server := recordQuery(`{"job_id": "1"}`, &queries)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Endpoints.Query = server.URL
+
+	from := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2014, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	mix.ExportDate(ctx, from, make(chan EventData, 1), nil)
+	mix.StartExportJob(ctx, from, to, nil)
+
+	expected := []struct {
+		Endpoint string
+		From, To string
+	}{
+		{EndpointExport, "2014-01-01", "2014-01-01"},
+		{EndpointExportJobs, "2014-01-01", "2014-01-31"},
+	}
+
+	if len(queries) != len(expected) {
+		t.Fatalf("expected %d requests, got %d", len(expected), len(queries))
+	}
+
+	for i, e := range expected {
+		params := paramsFor(e.Endpoint)
+		q := queries[i]
+
+		if q.Get(params.from) != e.From || q.Get(params.to) != e.To {
+			t.Errorf("%s: expected %s-%s, got %v", e.Endpoint, e.From, e.To, q)
+		}
+
+		if q.Get("sig") == "" {
+			t.Errorf("%s: request wasn't signed: %v", e.Endpoint, q)
+		}
+	}
+}
+
+func TestDateAliases(t *testing.T) {
+	var queries []url.Values
+
+	server := recordQuery("", &queries)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	moreArgs := url.Values{"start": {"2013-12-30"}, "end": {"2013-12-31"}, "event": {`["a"]`}}
+
+	mix.ExportDate(context.Background(), date, make(chan EventData), &moreArgs)
+
+	q := queries[0]
+	if q.Get("from_date") != "2013-12-30" || q.Get("to_date") != "2013-12-31" {
+		t.Errorf("expected aliases to be translated, got %v", q)
+	}
+
+	if _, ok := q["start"]; ok {
+		t.Errorf("expected alias to be removed, got %v", q)
+	}
+
+	if q.Get("event") != `["a"]` {
+		t.Errorf("expected other arguments to pass through, got %v", q)
+	}
+}
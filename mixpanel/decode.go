@@ -0,0 +1,276 @@
+package mixpanel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Number of lines handed to a decode worker at a time. Batching lines up
+// keeps the channel overhead small relative to the decoding work.
+const decodeChunkLines = 256
+
+// decodeChunk is a batch of raw lines, numbered in the order they were read.
+type decodeChunk struct {
+	seq   int
+	lines int
+	data  []byte
+}
+
+// decodeResult is a decoded and transformed chunk. If decoding any line
+// failed, `events` holds everything before that line and `err` is set.
+type decodeResult struct {
+	seq         int
+	events      []EventData
+	raws        [][]byte
+	rejected    []EventData
+	oversized   int
+	missingTime int
+	err         error
+}
+
+// transformConcurrent is the `DecodeWorkers` flavor of `TransformEventData`.
+// It splits the input into chunks of lines and hands them to a pool of
+// workers to decode and transform, sending the resulting events to `output`
+// either as soon as they're ready or, with `PreserveOrder`, in their original
+// order.
+func (m *Mixpanel) transformConcurrent(ctx context.Context, input *bufio.Reader, output chan<- EventData, filter *eventFilter, tp *tap) (Stats, error) {
+	var stats Stats
+
+	chunks := make(chan decodeChunk, m.DecodeWorkers)
+	results := make(chan decodeResult, m.DecodeWorkers)
+
+	// Closed to tell the reader to stop early if something fails.
+	stop := make(chan struct{})
+	readErr := make(chan error, 1)
+
+	go func() {
+		defer close(chunks)
+		readErr <- readChunks(input, chunks, stop)
+	}()
+
+	keep := m.keepSet()
+
+	var wg sync.WaitGroup
+	for i := 0; i < m.DecodeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for chunk := range chunks {
+				results <- m.decodeLines(chunk, keep, filter)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var (
+		err     error
+		next    int
+		pending = make(map[int]decodeResult)
+		prog    = progressFrom(ctx)
+	)
+
+	// send hands off the events of one result, failing if `ctx` is done
+	// before they've all been sent to `output`.
+	send := func(result decodeResult) error {
+		for i, event := range result.events {
+			sending := time.Now()
+
+			if m.RawChan != nil {
+				if err := sendRaw(ctx, m.RawChan, result.raws[i]); err != nil {
+					return err
+				}
+			}
+
+			if err := sendEvent(ctx, output, event); err != nil {
+				return err
+			}
+
+			stats.outputWait += time.Since(sending)
+
+			if result.raws != nil {
+				tp.send(result.raws[i])
+			}
+
+			stats.EventsExported++
+			prog.sent()
+		}
+
+		for _, event := range result.rejected {
+			if err := m.reject(ctx, event); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	emit := func(result decodeResult) {
+		stats.OversizedEvents += result.oversized
+		stats.MissingTime += result.missingTime
+		prog.oversize(result.oversized)
+		prog.missTime(result.missingTime)
+
+		if serr := send(result); serr != nil {
+			err = serr
+			close(stop)
+		} else if result.err != nil {
+			err = result.err
+			close(stop)
+		}
+	}
+
+	for result := range results {
+		// Once something has failed, just drain what's left.
+		if err != nil {
+			continue
+		}
+
+		if !m.PreserveOrder {
+			emit(result)
+			continue
+		}
+
+		pending[result.seq] = result
+
+		for r, ok := pending[next]; ok && err == nil; r, ok = pending[next] {
+			delete(pending, next)
+			emit(r)
+			next++
+		}
+	}
+
+	if err != nil {
+		return stats, err
+	}
+
+	if err := <-readErr; isAbort(err) {
+		return stats, err
+	} else if err != nil {
+		return stats, fmt.Errorf("%s: Failed to read response: %w", m.Product, err)
+	}
+
+	return stats, nil
+}
+
+// readChunks splits `input` into newline delimited chunks of lines and sends
+// them over `chunks` until the input runs out or `stop` is closed.
+func readChunks(input *bufio.Reader, chunks chan<- decodeChunk, stop <-chan struct{}) error {
+	chunk := decodeChunk{}
+
+	send := func() bool {
+		select {
+		case chunks <- chunk:
+			chunk = decodeChunk{seq: chunk.seq + 1}
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	for {
+		line, err := input.ReadSlice('\n')
+
+		// Lines longer than the reader's buffer come back in pieces.
+		chunk.data = append(chunk.data, line...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		} else if len(line) > 0 {
+			chunk.lines++
+		}
+
+		if err == io.EOF {
+			if chunk.lines > 0 {
+				send()
+			}
+
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if chunk.lines == decodeChunkLines && !send() {
+			return nil
+		}
+	}
+}
+
+// decodeLines decodes and transforms each line of the chunk.
+func (m *Mixpanel) decodeLines(chunk decodeChunk, keep map[string]bool, filter *eventFilter) decodeResult {
+	result := decodeResult{seq: chunk.seq, events: make([]EventData, 0, chunk.lines)}
+
+	decoder := json.NewDecoder(bytes.NewReader(chunk.data))
+	decoder.UseNumber()
+
+	for {
+		var ev rawEvent
+
+		raw, err := m.decodeNext(decoder, keep, &ev)
+		if err == io.EOF {
+			return result
+		} else if err != nil {
+			result.err = fmt.Errorf("%s: Failed to parse JSON: %w", m.Product, err)
+			return result
+		}
+
+		if filter.drops(&ev) {
+			continue
+		}
+
+		missingTime := m.fillMissingTime(&ev, filter)
+		if missingTime {
+			result.missingTime++
+
+			if m.MissingTimePolicy == MissingTimeDrop {
+				continue
+			}
+		}
+
+		for _, ev := range m.explode(ev) {
+			oversized := m.limitProperties(&ev)
+
+			event, err := m.transformEvent(&ev)
+			if err != nil {
+				result.err = err
+				return result
+			}
+
+			if reason := m.duplicateReason(raw); reason != "" {
+				event[RejectReasonKey] = reason
+				result.rejected = append(result.rejected, event)
+				continue
+			}
+
+			if missingTime && m.MissingTimePolicy == MissingTimeReject {
+				result.rejected = append(result.rejected, event)
+				continue
+			}
+
+			if oversized {
+				result.oversized++
+
+				if m.OversizedPolicy == OversizedDrop {
+					result.rejected = append(result.rejected, event)
+					continue
+				}
+			}
+
+			result.events = append(result.events, event)
+
+			if raw != nil {
+				result.raws = append(result.raws, raw)
+			}
+		}
+	}
+}
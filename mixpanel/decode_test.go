@@ -0,0 +1,102 @@
+package mixpanel
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedEvents builds `n` newline delimited events with sequential "n"
+// properties.
+func numberedEvents(n int) string {
+	var lines []string
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf(`{"event": "e%d", "properties": {"n": %d}}`, i%10, i))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func TestDecodeWorkers(t *testing.T) {
+	const total = 5000
+
+	for _, ordered := range []bool{false, true} {
+		mix := New("product", "", "")
+		mix.DecodeWorkers = 4
+		mix.PreserveOrder = ordered
+
+		output := make(chan EventData, total)
+
+		stats, err := mix.TransformEventData(strings.NewReader(numberedEvents(total)), output)
+		if err != nil {
+			t.Fatalf("ordered=%v: raised error: %v", ordered, err)
+		} else if stats.EventsExported != total {
+			t.Fatalf("ordered=%v: expected %d records, got %d", ordered, total, stats.EventsExported)
+		}
+
+		close(output)
+
+		seen := make(map[string]bool)
+		i := 0
+		for event := range output {
+			n := fmt.Sprint(event["n"])
+
+			if seen[n] {
+				t.Errorf("ordered=%v: duplicated event %s", ordered, n)
+			}
+			seen[n] = true
+
+			if ordered && n != fmt.Sprint(i) {
+				t.Fatalf("ordered=%v: expected event %d, got %s", ordered, i, n)
+			}
+			i++
+		}
+
+		if len(seen) != total {
+			t.Errorf("ordered=%v: expected %d unique events, got %d", ordered, total, len(seen))
+		}
+	}
+}
+
+func TestDecodeWorkersBadJSON(t *testing.T) {
+	mix := New("product", "", "")
+	mix.DecodeWorkers = 4
+	mix.PreserveOrder = true
+
+	input := strings.NewReader(numberedEvents(1000) + "\n{\"event\": \"bad\"\n" + numberedEvents(1000))
+	output := make(chan EventData, 2000)
+
+	if stats, err := mix.TransformEventData(input, output); err == nil {
+		t.Error("expected error on bad json")
+	} else if stats.EventsExported != 1000 {
+		t.Errorf("expected 1000 records before the bad line, got %d", stats.EventsExported)
+	}
+}
+
+func benchmarkDecodeWorkers(b *testing.B, workers int) {
+	input := numberedEvents(20000)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		mix := New("product", "", "")
+		mix.DecodeWorkers = workers
+
+		output := make(chan EventData, 1000)
+		go func() {
+			for range output {
+			}
+		}()
+
+		mix.TransformEventData(strings.NewReader(input), output)
+		close(output)
+	}
+}
+
+func BenchmarkDecodeWorkers1(b *testing.B) {
+	benchmarkDecodeWorkers(b, 1)
+}
+
+func BenchmarkDecodeWorkers4(b *testing.B) {
+	benchmarkDecodeWorkers(b, 4)
+}
@@ -0,0 +1,106 @@
+package mixpanel
+
+import (
+	"context"
+	"golang.org/x/sync/singleflight"
+	"net/url"
+	"time"
+)
+
+// dedupWaiting, if set, is called once a caller is registered with the
+// download it will share. Only used by tests.
+var dedupWaiting func()
+
+// exportShared is the `DedupRequests` flavor of `ExportDate`. Calls for the
+// same product, day, and arguments that overlap in time share one download,
+// and every caller receives every event on its own channel.
+//
+// This means the full day's events have to be buffered in memory until the
+// download finishes before any of them can be fanned out, so memory use is
+// proportional to the size of the day rather than constant. Each caller gets
+// its own deep copy of each event, so callers are free to modify what they
+// receive, nested properties included.
+//
+// The download runs under the context of whichever caller started it, so
+// cancelling that caller will fail the export for everyone sharing it.
+func (m *Mixpanel) exportShared(ctx context.Context, date time.Time, output chan<- EventData, moreArgs *url.Values) (Stats, error) {
+	// The location matters when `ProjectTimezone` is set.
+	key := m.Product + "|" + date.Format("2006-01-02") + "|" + date.Location().String()
+	if moreArgs != nil {
+		key += "|" + moreArgs.Encode()
+	}
+
+	type result struct {
+		stats  Stats
+		events []EventData
+	}
+
+	ch := m.inflight.DoChan(key, func() (interface{}, error) {
+		buffer := make(chan EventData, 100)
+		done := make(chan []EventData)
+
+		go func() {
+			var events []EventData
+			for event := range buffer {
+				events = append(events, event)
+			}
+			done <- events
+		}()
+
+		stats, err := m.exportDate(ctx, date, buffer, moreArgs)
+		close(buffer)
+
+		return result{stats, <-done}, err
+	})
+
+	if dedupWaiting != nil {
+		dedupWaiting()
+	}
+
+	var shared singleflight.Result
+	select {
+	case shared = <-ch:
+	case <-ctx.Done():
+		return Stats{}, ctx.Err()
+	}
+
+	downloaded := shared.Val.(result)
+	stats := downloaded.stats
+
+	for i, event := range downloaded.events {
+		select {
+		case output <- copyValue(event).(EventData):
+		case <-ctx.Done():
+			stats.EventsExported = i
+			return stats, ctx.Err()
+		}
+	}
+
+	return stats, shared.Err
+}
+
+// copyValue deep copies the maps and slices of a decoded JSON value.
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case EventData:
+		copied := make(EventData, len(t))
+		for k, elem := range t {
+			copied[k] = copyValue(elem)
+		}
+		return copied
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(t))
+		for k, elem := range t {
+			copied[k] = copyValue(elem)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(t))
+		for i, elem := range t {
+			copied[i] = copyValue(elem)
+		}
+		return copied
+	}
+
+	return v
+}
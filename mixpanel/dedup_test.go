@@ -0,0 +1,144 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDedupRequests(t *testing.T) {
+	var requests int32
+
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(arrived)
+		}
+
+		<-release
+		fmt.Fprint(w, `{"event": "a", "properties": {}}
+{"event": "b", "properties": {}}`)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.DedupRequests = true
+
+	// Closed once both callers are registered with the shared download.
+	joined := make(chan struct{})
+	var waiting int32
+	dedupWaiting = func() {
+		if atomic.AddInt32(&waiting, 1) == 2 {
+			close(joined)
+		}
+	}
+	defer func() { dedupWaiting = nil }()
+
+	date, _ := time.Parse("2006-01-02", "2014-01-01")
+
+	var wg sync.WaitGroup
+	counts := make([]int, 2)
+
+	export := func(i int) {
+		defer wg.Done()
+
+		output := make(chan EventData, 2)
+		stats, err := mix.ExportDate(context.Background(), date, output, nil)
+		if err != nil {
+			t.Errorf("raised error: %v", err)
+		}
+
+		close(output)
+		for range output {
+			counts[i]++
+		}
+
+		if stats.EventsExported != counts[i] {
+			t.Errorf("reported %d records but sent %d", stats.EventsExported, counts[i])
+		}
+	}
+
+	wg.Add(2)
+	go export(0)
+
+	// Make sure the first request is in flight before starting another.
+	<-arrived
+	go export(1)
+
+	// Only let the download finish once the second caller is sharing it.
+	<-joined
+	close(release)
+	wg.Wait()
+
+	if requests != 1 {
+		t.Errorf("expected 1 HTTP request, got %d", requests)
+	}
+
+	if counts[0] != 2 || counts[1] != 2 {
+		t.Errorf("expected both callers to see 2 events, got %v", counts)
+	}
+}
+
+func TestDedupRequestsDeepCopy(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-release
+		fmt.Fprint(w, `{"event": "a", "properties": {"nested": {"k": "v"}, "list": [{"k": "v"}]}}`)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.DedupRequests = true
+
+	// Closed once both callers are registered with the shared download.
+	joined := make(chan struct{})
+	var waiting int32
+	dedupWaiting = func() {
+		if atomic.AddInt32(&waiting, 1) == 2 {
+			close(joined)
+		}
+	}
+	defer func() { dedupWaiting = nil }()
+
+	date, _ := time.Parse("2006-01-02", "2014-01-01")
+
+	outputs := []chan EventData{make(chan EventData, 1), make(chan EventData, 1)}
+
+	var wg sync.WaitGroup
+	for i := range outputs {
+		wg.Add(1)
+		go func(output chan EventData) {
+			defer wg.Done()
+			if _, err := mix.ExportDate(context.Background(), date, output, nil); err != nil {
+				t.Errorf("raised error: %v", err)
+			}
+		}(outputs[i])
+
+		if i == 0 {
+			<-arrived
+		}
+	}
+
+	<-joined
+	close(release)
+	wg.Wait()
+
+	first, second := <-outputs[0], <-outputs[1]
+
+	first["nested"].(map[string]interface{})["k"] = "changed"
+	first["list"].([]interface{})[0].(map[string]interface{})["k"] = "changed"
+
+	if second["nested"].(map[string]interface{})["k"] != "v" || second["list"].([]interface{})[0].(map[string]interface{})["k"] != "v" {
+		t.Errorf("modifying one caller's event changed another's: %v", second)
+	}
+}
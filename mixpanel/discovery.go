@@ -0,0 +1,49 @@
+package mixpanel
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ExportUnknownEvents is `ExportDate` for discovering newly introduced
+// events: only events whose name is not in `known` are sent to `output`.
+// Returns the names of the unknown events seen, sorted, along with the
+// stats of the export, which count only the events sent.
+//
+// Mixpanel can't be asked to leave events out, so the whole day is still
+// downloaded, but the known events are discarded as they are decoded, so
+// `RawChan`, `Tap` and `Rejects` don't see them either.
+func (m *Mixpanel) ExportUnknownEvents(ctx context.Context, date time.Time, known []string, output chan<- EventData) ([]string, Stats, error) {
+	isKnown := make(map[string]bool, len(known))
+	for _, name := range known {
+		isKnown[name] = true
+	}
+
+	var mu sync.Mutex
+	unknown := make(map[string]bool)
+
+	// Nothing but the known events is dropped, the rest are only noted.
+	ctx = withFilter(ctx, &eventFilter{
+		exclude: isKnown,
+		keep: func(ev *rawEvent) bool {
+			mu.Lock()
+			unknown[ev.Event] = true
+			mu.Unlock()
+
+			return true
+		},
+	})
+
+	stats, err := m.ExportDate(ctx, date, output, nil)
+
+	names := make([]string, 0, len(unknown))
+	for name := range unknown {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, stats, err
+}
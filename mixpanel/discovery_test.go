@@ -0,0 +1,102 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExportUnknownEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range []string{"Signed Up", "Shared Link", "Viewed Page", "Rated App", "Shared Link"} {
+			fmt.Fprintf(w, `{"event": %q, "properties": {"time": 1388534400}}`+"\n", name)
+		}
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	output := make(chan EventData, 5)
+
+	date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	names, stats, err := mix.ExportUnknownEvents(context.Background(), date, []string{"Signed Up", "Viewed Page"}, output)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	close(output)
+
+	var emitted []string
+	for event := range output {
+		emitted = append(emitted, event["event"].(string))
+	}
+
+	if expected := []string{"Shared Link", "Rated App", "Shared Link"}; !reflect.DeepEqual(emitted, expected) {
+		t.Errorf("expected only unknown events %v, got %v", expected, emitted)
+	}
+
+	if expected := []string{"Rated App", "Shared Link"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected unknown names %v, got %v", expected, names)
+	}
+
+	if stats.EventsExported != 3 {
+		t.Errorf("expected 3 events counted, got %d", stats.EventsExported)
+	}
+}
+
+func TestExportUnknownEventsRawLockstep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range []string{"Signed Up", "Shared Link", "Viewed Page", "Rated App"} {
+			fmt.Fprintf(w, `{"event": %q, "properties": {"time": 1388534400}}`+"\n", name)
+		}
+	}))
+	defer server.Close()
+
+	for _, workers := range []int{1, 4} {
+		raws := make(chan []byte, 5)
+		rejects := make(chan EventData, 5)
+
+		mix := NewWithURL("product", "key", "secret", server.URL)
+		mix.DecodeWorkers = workers
+		mix.PreserveOrder = true
+		mix.RawChan = raws
+		mix.Rejects = rejects
+
+		output := make(chan EventData, 5)
+
+		date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		if _, _, err := mix.ExportUnknownEvents(context.Background(), date, []string{"Signed Up", "Viewed Page"}, output); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		close(output)
+		close(raws)
+		close(rejects)
+
+		for event := range output {
+			raw, ok := <-raws
+			if !ok {
+				t.Fatalf("%d workers: no raw line for %v", workers, event)
+			}
+
+			var ev rawEvent
+			if err := json.Unmarshal(raw, &ev); err != nil || ev.Event != event["event"] {
+				t.Errorf("%d workers: raw %s doesn't match %v", workers, raw, event)
+			}
+		}
+
+		for raw := range raws {
+			t.Errorf("%d workers: raw line of a known event %s", workers, raw)
+		}
+
+		for event := range rejects {
+			t.Errorf("%d workers: known event rejected %v", workers, event)
+		}
+	}
+}
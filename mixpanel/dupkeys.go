@@ -0,0 +1,93 @@
+package mixpanel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// What to do with an event which has the same key more than once in one of
+// its objects, which `encoding/json` would quietly decode to the last value.
+const (
+	// DuplicateKeysLastWins keeps the event, with the last value of each
+	// duplicated key.
+	DuplicateKeysLastWins = iota
+
+	// DuplicateKeysReject leaves the event out of the output, sending it
+	// (with the last values) to `Rejects` instead if that is set, with the
+	// duplicated key named under `RejectReasonKey`.
+	DuplicateKeysReject
+)
+
+// RejectReasonKey is the key the reason an event was rejected for is
+// attached under, for events rejected by `DuplicateKeysReject`.
+const RejectReasonKey = "reject_reason"
+
+// duplicateReason returns the reason to reject the event `raw` for under
+// `DuplicateKeyPolicy`, if any.
+func (m *Mixpanel) duplicateReason(raw []byte) string {
+	if m.DuplicateKeyPolicy != DuplicateKeysReject {
+		return ""
+	}
+
+	if key, ok := duplicateKey(raw); ok {
+		return fmt.Sprintf("duplicate key %q", key)
+	}
+
+	return ""
+}
+
+// duplicateKey scans the JSON value `raw` for an object with a key in it
+// twice, at any depth, and returns the first such key. `raw` must be well
+// formed, as it is once it has been decoded.
+func duplicateKey(raw []byte) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+
+	// The keys of each object we're inside, and whether a key comes next.
+	// Arrays are nil.
+	type object struct {
+		keys map[string]bool
+		key  bool
+	}
+
+	var stack []*object
+
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return "", false
+		}
+
+		if len(stack) > 0 {
+			if top := stack[len(stack)-1]; top != nil && top.key {
+				if key, ok := tok.(string); ok {
+					if top.keys[key] {
+						return key, true
+					}
+
+					top.keys[key] = true
+					top.key = false
+					continue
+				}
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &object{keys: make(map[string]bool), key: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, nil)
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+
+		// That was a whole value, so next in the enclosing object is a key.
+		if len(stack) == 0 {
+			return "", false
+		} else if top := stack[len(stack)-1]; top != nil {
+			top.key = true
+		}
+	}
+}
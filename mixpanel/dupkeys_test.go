@@ -0,0 +1,92 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDuplicateKeyPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"event": "clean", "properties": {"time": 1388577600, "list": [{"a": 1}, {"a": 2}]}}`)
+		fmt.Fprintln(w, `{"event": "dirty", "properties": {"time": 1388577600, "plan": "free", "plan": "paid"}}`)
+	}))
+	defer server.Close()
+
+	for _, policy := range []int{DuplicateKeysLastWins, DuplicateKeysReject} {
+		for _, workers := range []int{1, 4} {
+			mix := NewWithURL("product", "key", "secret", server.URL)
+			mix.DuplicateKeyPolicy = policy
+			mix.DecodeWorkers = workers
+			mix.PreserveOrder = true
+
+			output := make(chan EventData, 2)
+			rejects := make(chan EventData, 2)
+			mix.Rejects = rejects
+
+			if _, err := mix.ExportDate(context.Background(), time.Now(), output, nil); err != nil {
+				t.Fatalf("policy %d: raised error: %v", policy, err)
+			}
+
+			close(output)
+			close(rejects)
+
+			var names []string
+			for event := range output {
+				names = append(names, event["event"].(string))
+
+				if event["event"] == "dirty" && event["plan"] != "paid" {
+					t.Errorf("policy %d: expected the last value to win, got %v", policy, event["plan"])
+				}
+			}
+
+			var rejected []EventData
+			for event := range rejects {
+				rejected = append(rejected, event)
+			}
+
+			if policy == DuplicateKeysLastWins {
+				if len(names) != 2 || len(rejected) != 0 {
+					t.Errorf("expected both events to be kept, got %v and %d rejects", names, len(rejected))
+				}
+
+				continue
+			}
+
+			if len(names) != 1 || names[0] != "clean" {
+				t.Errorf("expected only the clean event, got %v", names)
+			}
+
+			if len(rejected) != 1 || rejected[0][RejectReasonKey] != `duplicate key "plan"` {
+				t.Errorf("expected the dirty event to be rejected with a reason, got %v", rejected)
+			}
+		}
+	}
+}
+
+func TestDuplicateKey(t *testing.T) {
+	cases := []struct {
+		JSON string
+		Key  string
+	}{
+		{`{"a": 1, "b": 2}`, ""},
+		{`{"a": 1, "a": 2}`, "a"},
+		{`{"a": {"b": 1}, "b": 2}`, ""},
+		{`{"a": {"b": 1, "b": 2}}`, "b"},
+		{`{"a": [{"b": 1}, {"b": 2}], "c": []}`, ""},
+		{`{"a": [{"b": 1, "b": 2}]}`, "b"},
+		{`{"a": "b", "b": {}, "c": "a"}`, ""},
+		{`{"a": [], "a": []}`, "a"},
+		{`[1, 2, 1]`, ""},
+	}
+
+	for _, c := range cases {
+		key, ok := duplicateKey([]byte(c.JSON))
+		if ok != (c.Key != "") || key != c.Key {
+			t.Errorf("%s: expected %q, got %q", c.JSON, c.Key, key)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors for the kinds of failure callers are likely to want to
+// branch on. These are wrapped by the errors returned from the API methods,
+// so should be checked with `errors.Is`.
+var (
+	// ErrUnauthorized means Mixpanel rejected our credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrThrottled means Mixpanel kept throttling a request even after
+	// retrying it.
+	ErrThrottled = errors.New("throttled")
+
+	// ErrUnsafeRedirect means Mixpanel redirected a request somewhere our
+	// credentials wouldn't have been sent to.
+	ErrUnsafeRedirect = errors.New("redirect would drop credentials")
+
+	// ErrSignatureExpired means Mixpanel rejected a request's signature as
+	// expired or invalid. Since the signature's expiry is based on our
+	// clock, this usually means the clock is skewed (see `SelfTest`).
+	ErrSignatureExpired = errors.New("signature expired or invalid; check the system clock")
+
+	// ErrMissingSecret means a request that has to be signed was made
+	// without an API secret, as with a project that was only issued a
+	// token. See `Import` and `PeopleSet` for what works with a token.
+	ErrMissingSecret = errors.New("missing API secret")
+
+	// ErrMissingToken means an ingestion request was made without a
+	// project `Token`.
+	ErrMissingToken = errors.New("missing project token")
+
+	// ErrInvalidProjectID means `ProjectID` is set to something other than
+	// a project's numeric ID.
+	ErrInvalidProjectID = errors.New("project ID must be numeric")
+
+	// ErrSessionExpired means Mixpanel no longer recognizes the session of
+	// a paginated People export, so it can't be resumed and has to be
+	// restarted from the first page.
+	ErrSessionExpired = errors.New("people export session expired; restart the export")
+)
+
+// Phrases of the messages Mixpanel rejects expired or bad signatures with.
+// These only count in a 400 or 401 response, and are specific enough not to
+// match other things which expire (trials, tokens, sessions).
+var signatureMessages = []string{
+	"request expired",
+	"request has expired",
+	"expired signature",
+	"signature expired",
+	"signature has expired",
+	"invalid signature",
+	"signature mismatch",
+}
+
+// APIError is an error reported by the Mixpanel API itself, either as a
+// non-200 response or an error envelope (`{"error": "..."}`) in the body.
+//
+// Extract it with `errors.As`. An APIError with the appropriate `StatusCode`
+// will also match `ErrUnauthorized` or `ErrThrottled` with `errors.Is`, a 400
+// or 401 complaining about the request signature matches `ErrSignatureExpired`,
+// and one complaining about a People export session `ErrSessionExpired`.
+type APIError struct {
+	// StatusCode is the HTTP status of the response, or zero if the error
+	// came from an error envelope in an otherwise successful response.
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if e.signatureRejected() {
+		msg += " (signatures expire based on the system clock, is it skewed?)"
+	}
+
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("API error: %s", msg)
+	}
+
+	return fmt.Sprintf("API error (%d): %s", e.StatusCode, msg)
+}
+
+// signatureRejected reports whether the error is Mixpanel complaining about
+// the request signature.
+func (e *APIError) signatureRejected() bool {
+	if e.StatusCode != http.StatusBadRequest && e.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	if e.sessionRejected() {
+		return false
+	}
+
+	msg := strings.ToLower(e.Message)
+
+	for _, fragment := range signatureMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sessionRejected reports whether the error is Mixpanel complaining about
+// the `session_id` of a People export.
+func (e *APIError) sessionRejected() bool {
+	msg := strings.ToLower(e.Message)
+
+	return strings.Contains(msg, "session") &&
+		(strings.Contains(msg, "expired") || strings.Contains(msg, "invalid") || strings.Contains(msg, "not found"))
+}
+
+// Is allows matching an APIError against the sentinel errors.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrThrottled:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrSignatureExpired:
+		return e.signatureRejected()
+	case ErrSessionExpired:
+		return e.sessionRejected()
+	}
+
+	return false
+}
+
+// responseError builds an APIError from a non-200 response, pulling the
+// message out of the error envelope if there is one.
+func responseError(resp *http.Response) *APIError {
+	var envelope struct{ Error string }
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if json.Unmarshal(body, &envelope) != nil || envelope.Error == "" {
+		envelope.Error = http.StatusText(resp.StatusCode)
+	}
+
+	return &APIError{StatusCode: resp.StatusCode, Message: envelope.Error}
+}
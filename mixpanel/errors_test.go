@@ -0,0 +1,144 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": "Invalid API key", "request": "/api/2.0/export"}`)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	output := make(chan EventData)
+
+	_, err := mix.ExportDate(context.Background(), time.Now(), output, nil)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	} else if apiErr.StatusCode != 401 || apiErr.Message != "Invalid API key" {
+		t.Errorf("bad APIError: %+v", apiErr)
+	}
+}
+
+func TestErrThrottled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.ThrottleRetries = -1
+
+	output := make(chan EventData)
+
+	if _, err := mix.ExportDate(context.Background(), time.Now(), output, nil); !errors.Is(err, ErrThrottled) {
+		t.Errorf("expected ErrThrottled, got %v", err)
+	}
+}
+
+func TestAPIErrorEnvelope(t *testing.T) {
+	mix := New("product", "", "")
+	input := strings.NewReader(`{"error": "some api error"}`)
+	output := make(chan EventData)
+
+	_, err := mix.TransformEventData(input, output)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	} else if apiErr.Message != "some api error" || apiErr.StatusCode != 0 {
+		t.Errorf("bad APIError: %+v", apiErr)
+	}
+
+	if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrThrottled) {
+		t.Errorf("envelope error shouldn't match sentinels: %v", err)
+	}
+}
+
+func TestErrResponseTooLargeWrapped(t *testing.T) {
+	err := fmt.Errorf("product: %w", ErrResponseTooLarge)
+
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("expected ErrResponseTooLarge to match through wrapping")
+	}
+}
+
+func TestErrSignatureExpired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "Request expired", "request": "/api/2.0/export"}`)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	output := make(chan EventData)
+
+	_, err := mix.ExportDate(context.Background(), time.Now(), output, nil)
+	if !errors.Is(err, ErrSignatureExpired) {
+		t.Fatalf("expected ErrSignatureExpired, got %v", err)
+	} else if errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected a 400 not to match ErrUnauthorized")
+	}
+
+	if !strings.Contains(err.Error(), "clock") {
+		t.Errorf("expected a hint about the clock, got %q", err)
+	}
+
+	for _, other := range []*APIError{
+		{StatusCode: 400, Message: "Invalid where expression"},
+		{StatusCode: 400, Message: "Your trial expired"},
+		{StatusCode: 401, Message: "token expired"},
+		{StatusCode: 402, Message: "Project plan expired"},
+		{StatusCode: 500, Message: "Request has expired"},
+		{StatusCode: 0, Message: "Request has expired"},
+	} {
+		if errors.Is(other, ErrSignatureExpired) || strings.Contains(other.Error(), "clock") {
+			t.Errorf("expected unrelated errors to be left alone: %v", other)
+		}
+	}
+
+	for _, message := range []string{"Request has expired", "Expired signature", "Invalid signature", "signature mismatch"} {
+		if err := (&APIError{StatusCode: 401, Message: message}); !errors.Is(err, ErrSignatureExpired) {
+			t.Errorf("expected %q to match ErrSignatureExpired", message)
+		}
+	}
+}
+
+func TestErrMissingSecret(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "", server.URL)
+	mix.Endpoints.Query = server.URL
+
+	if _, err := mix.ExportDate(context.Background(), time.Now(), make(chan EventData, 1), nil); !errors.Is(err, ErrMissingSecret) {
+		t.Errorf("expected ErrMissingSecret from export, got %v", err)
+	}
+
+	if _, _, err := mix.VerifyDate(context.Background(), time.Now()); !errors.Is(err, ErrMissingSecret) {
+		t.Errorf("expected ErrMissingSecret from query, got %v", err)
+	}
+
+	if requests != 0 {
+		t.Errorf("expected no requests to be sent, got %d", requests)
+	}
+}
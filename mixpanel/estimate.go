@@ -0,0 +1,34 @@
+package mixpanel
+
+import (
+	"context"
+	"time"
+)
+
+// EstimateVolume estimates how many events an export of `from` through `to`
+// (inclusive) would produce, without pulling any raw data, to help budget
+// storage and time before running it.
+//
+// Returns the number of events per day, keyed by `YYYY-MM-DD`, and their
+// total. Days without any events are omitted.
+//
+// This is only an estimate: counts come from Mixpanel's reporting data,
+// which can lag behind the raw data, and late events (offline mobile
+// clients, imports) keep arriving for recent days after the fact. See
+// `VerifyDate` for the same caveats.
+func (m *Mixpanel) EstimateVolume(ctx context.Context, from, to time.Time) (eventsPerDay map[string]int64, total int64, err error) {
+	if eventsPerDay, err = m.dailyCounts(ctx, from, to); err != nil {
+		return nil, 0, err
+	}
+
+	for day, count := range eventsPerDay {
+		if count == 0 {
+			delete(eventsPerDay, day)
+			continue
+		}
+
+		total += count
+	}
+
+	return eventsPerDay, total, nil
+}
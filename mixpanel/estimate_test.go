@@ -0,0 +1,56 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEstimateVolume(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/events/names":
+			fmt.Fprint(w, `["a", "b"]`)
+		case "/events":
+			if from, to := r.URL.Query().Get("from_date"), r.URL.Query().Get("to_date"); from != "2014-01-01" || to != "2014-01-03" {
+				t.Errorf("unexpected range %s - %s", from, to)
+			}
+
+			fmt.Fprint(w, `{
+				"data": {
+					"series": ["2014-01-01", "2014-01-02", "2014-01-03"],
+					"values": {
+						"a": {"2014-01-01": 10, "2014-01-02": 20, "2014-01-03": 0},
+						"b": {"2014-01-01": 1, "2014-01-02": 2, "2014-01-03": 0}
+					}
+				},
+				"legend_size": 2
+			}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	from, _ := time.Parse("2006-01-02", "2014-01-01")
+	to, _ := time.Parse("2006-01-02", "2014-01-03")
+
+	perDay, total, err := mix.EstimateVolume(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := map[string]int64{"2014-01-01": 11, "2014-01-02": 22}
+	if !reflect.DeepEqual(perDay, expected) {
+		t.Errorf("expected %v, got %v", expected, perDay)
+	} else if total != 33 {
+		t.Errorf("expected total of 33, got %d", total)
+	}
+}
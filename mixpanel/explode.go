@@ -0,0 +1,69 @@
+package mixpanel
+
+// explode splits the decoded event `ev` into one event per element of its
+// `Explode` array. Elements which are objects have their properties merged
+// into the event (moving any existing property of the same name aside, as
+// `stamp` does), and any other element takes the place of the array.
+//
+// This happens before anything else is done to the event, so that each row
+// is hashed, stripped and limited like any other event, and gets an
+// `EventIDKey` of its own. An element can't replace the event's reserved
+// properties (see `explodeReserved`): those of its properties are set aside
+// under a prefixed key instead, as with a collision in `stamp`.
+//
+// An event whose `Explode` property is missing, empty or not an array is
+// passed through unchanged, as its only row.
+func (m *Mixpanel) explode(ev rawEvent) []rawEvent {
+	if m.Explode == "" || m.ImportShape {
+		return []rawEvent{ev}
+	}
+
+	elements, ok := ev.Properties[m.Explode].([]interface{})
+	if !ok || len(elements) == 0 {
+		return []rawEvent{ev}
+	}
+
+	rows := make([]rawEvent, 0, len(elements))
+
+	for _, element := range elements {
+		row := rawEvent{Error: ev.Error, Event: ev.Event, Properties: make(map[string]interface{}, len(ev.Properties))}
+		for k, v := range ev.Properties {
+			if k != m.Explode {
+				row.Properties[k] = v
+			}
+		}
+
+		if fields, ok := element.(map[string]interface{}); ok {
+			for k, v := range fields {
+				if m.explodeReserved(k) {
+					setAside(row.Properties, k, v)
+				} else {
+					stamp(row.Properties, k, v)
+				}
+			}
+		} else {
+			row.Properties[m.Explode] = element
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// explodeReserved reports whether `key` is one of the properties identifying
+// an event, which `explode` never lets an element replace.
+func (m *Mixpanel) explodeReserved(key string) bool {
+	for _, reserved := range alwaysKept {
+		if key == reserved {
+			return true
+		}
+	}
+
+	switch key {
+	case m.productKey(), m.eventKey(), EventIDKey, TimestampKey, ResolvedIDKey:
+		return true
+	}
+
+	return ProtectedProperties[key]
+}
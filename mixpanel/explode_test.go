@@ -0,0 +1,128 @@
+package mixpanel
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExplode(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		mix := New("product", "", "")
+		mix.Explode = "items"
+		mix.DecodeWorkers = workers
+		mix.PreserveOrder = true
+
+		input := strings.NewReader(`{"event": "purchase", "properties": {"distinct_id": "a", "items": [{"sku": "x", "price": 1}, {"sku": "y", "price": 2}, "gift card"]}}
+{"event": "purchase", "properties": {"distinct_id": "b", "items": []}}
+{"event": "purchase", "properties": {"distinct_id": "c", "items": "none"}}
+{"event": "purchase", "properties": {"distinct_id": "d"}}
+`)
+		output := make(chan EventData, 10)
+
+		stats, err := mix.TransformEventData(input, output)
+		if err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		close(output)
+
+		var rows []EventData
+		for row := range output {
+			rows = append(rows, row)
+		}
+
+		if stats.EventsExported != 6 || len(rows) != 6 {
+			t.Fatalf("%d workers: expected 6 rows, got %d (counted %d)", workers, len(rows), stats.EventsExported)
+		}
+
+		exploded := rows[:3]
+		if exploded[0]["sku"] != "x" || exploded[1]["sku"] != "y" || exploded[2]["items"] != "gift card" {
+			t.Errorf("%d workers: bad exploded rows %v", workers, exploded)
+		}
+
+		ids := make(map[interface{}]bool)
+		for _, row := range exploded {
+			if row["distinct_id"] != "a" || row["event"] != "purchase" {
+				t.Errorf("%d workers: row lost the event's properties: %v", workers, row)
+			}
+
+			if _, ok := row["items"].([]interface{}); ok {
+				t.Errorf("%d workers: row still has the array: %v", workers, row)
+			}
+
+			ids[row[EventIDKey]] = true
+		}
+
+		if len(ids) != 3 {
+			t.Errorf("%d workers: expected each row to have its own ID, got %v", workers, ids)
+		}
+
+		for i, id := range []string{"b", "c", "d"} {
+			if row := rows[3+i]; row["distinct_id"] != id {
+				t.Errorf("%d workers: expected %s to pass through unchanged, got %v", workers, id, row)
+			}
+		}
+
+		if rows[4]["items"] != "none" {
+			t.Errorf("%d workers: expected a non-array to be kept, got %v", workers, rows[4])
+		}
+	}
+}
+
+func TestExplodeTransformsRows(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		mix := New("product", "", "")
+		mix.Explode = "items"
+		mix.DecodeWorkers = workers
+		mix.PreserveOrder = true
+		mix.HashProperties = map[string]string{"email": "salt"}
+		mix.MaxProperties = 6
+		mix.IncludeImplicit = false
+
+		input := strings.NewReader(`{"event": "purchase", "properties": {"distinct_id": "a", "time": 1388577600, "items": [
+			{"email": "a@example.com", "$browser": "Chrome", "sku": "x"},
+			{"event": "fake", "time": 1, "distinct_id": "b", "product": "other", "$insert_id": "dup"}
+		]}}
+`)
+		output := make(chan EventData, 10)
+
+		if _, err := mix.TransformEventData(input, output); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		close(output)
+
+		var rows []EventData
+		for row := range output {
+			rows = append(rows, row)
+		}
+
+		if len(rows) != 2 {
+			t.Fatalf("%d workers: expected 2 rows, got %v", workers, rows)
+		}
+
+		if email := rows[0]["email"]; email != hashValue("salt", "a@example.com") {
+			t.Errorf("%d workers: expected the element's email to be hashed, got %v", workers, email)
+		} else if _, ok := rows[0]["$browser"]; ok {
+			t.Errorf("%d workers: expected the element's implicit property to be stripped, got %v", workers, rows[0])
+		}
+
+		reserved := rows[1]
+		if reserved["event"] != "purchase" || reserved["distinct_id"] != "a" || reserved["product"] != "product" {
+			t.Errorf("%d workers: element replaced the event's properties: %v", workers, reserved)
+		} else if fmt.Sprint(reserved["time"]) != "1388577600" || reserved[TimestampKey] != "2014-01-01 12:00:00" {
+			t.Errorf("%d workers: element replaced the event's time: %v", workers, reserved)
+		} else if _, ok := reserved[PropInsertID]; ok {
+			t.Errorf("%d workers: element set the event's insert ID: %v", workers, reserved)
+		}
+
+		// Besides its own, each row gets the event name, product, ID
+		// and timestamp.
+		for _, row := range rows {
+			if len(row) > mix.MaxProperties+4 {
+				t.Errorf("%d workers: expected the row to be limited, got %v", workers, row)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ExportURL returns the signed URL `ExportDate` would download `date` from,
+// with the same `moreArgs`, without sending anything. It can be handed to
+// another tool to download (within the signature's expiry), or logged.
+//
+// The signature depends only on the arguments, the secret and the `Clock`
+// (for `expire`), so with a fixed clock the URL is the same on every run, which
+// makes it suitable for golden file tests of outgoing requests.
+//
+// Requests sent as a POST under `LongURLPost` have no URL to speak of, and
+// ExportURL fails with `ErrURLTooLong` for them.
+//
+// `ctx` only matters for looking up the secret with a `SecretProvider`.
+func (m *Mixpanel) ExportURL(ctx context.Context, date time.Time, moreArgs *url.Values) (string, error) {
+	from, to := date, date
+	if m.ProjectTimezone != nil {
+		from, to, _ = m.projectWindow(date)
+	}
+
+	req, err := m.exportRequest(ctx, from, to, moreArgs)
+	if err != nil {
+		return "", err
+	}
+
+	if req.Method != "GET" {
+		return "", fmt.Errorf("%s: %s: %w", m.Product, EndpointExport, ErrURLTooLong)
+	}
+
+	return req.URL.String(), nil
+}
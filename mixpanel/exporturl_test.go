@@ -0,0 +1,85 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func TestExportURLGolden(t *testing.T) {
+	const golden = "testdata/export_url.golden"
+
+	signed := func() string {
+		mix := New("product", "key", "secret")
+		mix.Clock = fixedClock(time.Unix(1388534400, 0))
+		mix.Events = []string{"Signed Up", "Viewed Page"}
+		mix.ServerSideFilter = true
+
+		date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		u, err := mix.ExportURL(context.Background(), date, &url.Values{"where": {`properties["plan"] == "free"`}})
+		if err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		return u
+	}
+
+	u := signed()
+
+	if *updateGolden {
+		if err := ioutil.WriteFile(golden, []byte(u+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u+"\n" != string(expected) {
+		t.Errorf("signed URL changed:\nexpected %s\ngot      %s", expected, u)
+	}
+
+	if again := signed(); again != u {
+		t.Errorf("expected the same URL every time, got %s and %s", u, again)
+	}
+}
+
+func TestExportURLPost(t *testing.T) {
+	mix := New("product", "key", "secret")
+	mix.MaxURLLength = 10
+	mix.LongURLPolicy = LongURLPost
+
+	if _, err := mix.ExportURL(context.Background(), time.Now(), nil); !errors.Is(err, ErrURLTooLong) {
+		t.Errorf("expected ErrURLTooLong, got %v", err)
+	}
+}
+
+// blockingSecrets is a SecretProvider which never answers before `ctx` is
+// done.
+type blockingSecrets struct{}
+
+func (blockingSecrets) Secret(ctx context.Context) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestExportURLCancelled(t *testing.T) {
+	mix := New("product", "key", "")
+	mix.SecretProvider = blockingSecrets{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := mix.ExportURL(ctx, time.Now(), nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the secret lookup to time out, got %v", err)
+	}
+}
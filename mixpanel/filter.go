@@ -0,0 +1,120 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// setEventFilter asks Mixpanel to only export the `Events` (if any), unless
+// `ServerSideFilter` is turned off or `moreArgs` already picks the events.
+func (m *Mixpanel) setEventFilter(args url.Values, moreArgs *url.Values) {
+	if len(m.Events) == 0 || !m.ServerSideFilter {
+		return
+	} else if moreArgs != nil && moreArgs.Get("event") != "" {
+		return
+	}
+
+	// Can't fail on a slice of strings.
+	encoded, _ := json.Marshal(m.Events)
+	args.Set("event", string(encoded))
+}
+
+// eventFilter decides which events are dropped while decoding, before
+// anything else (`RawChan`, rejects, `OnEventLag`, the output) sees them. A
+// nil eventFilter drops nothing.
+//
+// - `names`, if non-nil, is the set of event names to keep.
+// - `exclude`, if non-nil, is the set of event names to drop.
+// - `keep`, if set, is called with each event not dropped otherwise, and
+//   drops those it returns false for. It may be called from
+//   several goroutines at once (see `DecodeWorkers`).
+// - `window`, if non-nil, is the span of time events have to fall in.
+// - `day`, if not zero, is the start of the day being exported, which events
+//   without a time are given under `MissingTimeExportDate`.
+type eventFilter struct {
+	names   map[string]bool
+	exclude map[string]bool
+	keep    func(ev *rawEvent) bool
+	window  *timeWindow
+	day     time.Time
+}
+
+// clientFilter returns the filter for the `Events` to keep while decoding,
+// along with any filtering asked for by `ctx` (see `withFilter`), or nil if
+// every event should be kept.
+func (m *Mixpanel) clientFilter(ctx context.Context) *eventFilter {
+	filter := filterFrom(ctx)
+	if len(m.Events) == 0 || m.ServerSideFilter {
+		return filter
+	}
+
+	names := make(map[string]bool, len(m.Events))
+	for _, name := range m.Events {
+		names[name] = true
+	}
+
+	return filter.with(func(f *eventFilter) { f.names = names })
+}
+
+// with returns a copy of the filter changed by `change`.
+func (f *eventFilter) with(change func(*eventFilter)) *eventFilter {
+	var filter eventFilter
+	if f != nil {
+		filter = *f
+	}
+
+	change(&filter)
+	return &filter
+}
+
+// withWindow returns a copy of the filter which also drops events outside
+// of `window`.
+func (f *eventFilter) withWindow(window *timeWindow) *eventFilter {
+	return f.with(func(filter *eventFilter) { filter.window = window })
+}
+
+// withDay returns a copy of the filter which knows the day being exported is
+// the one starting at `day`.
+func (f *eventFilter) withDay(day time.Time) *eventFilter {
+	return f.with(func(filter *eventFilter) { filter.day = day })
+}
+
+// drops reports whether `ev` should be dropped. Error envelopes never are,
+// so that they're still reported.
+func (f *eventFilter) drops(ev *rawEvent) bool {
+	if f == nil || ev.Error != nil {
+		return false
+	}
+
+	if f.names != nil && !f.names[ev.Event] {
+		return true
+	} else if f.exclude[ev.Event] {
+		return true
+	}
+
+	if f.window != nil && !f.window.contains(EventData(ev.Properties)) {
+		return true
+	}
+
+	return f.keep != nil && !f.keep(ev)
+}
+
+// filterKey is the context key of the filtering an export method built on
+// `ExportDate` asks it for.
+type filterKey struct{}
+
+// withFilter returns a copy of `ctx` asking the exports made with it to drop
+// events by `filter` (the `exclude` and `keep` parts of it) while decoding,
+// so that the events dropped are never seen by `RawChan` and the like, nor
+// counted in `Stats.EventsExported`.
+func withFilter(ctx context.Context, filter *eventFilter) context.Context {
+	return context.WithValue(ctx, filterKey{}, filter)
+}
+
+// filterFrom returns the filter `ctx` asks for, or nil.
+func filterFrom(ctx context.Context) *eventFilter {
+	filter, _ := ctx.Value(filterKey{}).(*eventFilter)
+	return filter
+}
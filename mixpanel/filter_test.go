@@ -0,0 +1,81 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestServerSideFilter(t *testing.T) {
+	// Ignores the event parameter, so anything filtered must have been
+	// filtered by us.
+	var param []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		param = r.URL.Query()["event"]
+
+		for _, name := range []string{"a", "b", "c", "a"} {
+			fmt.Fprintf(w, `{"event": %q, "properties": {}}`+"\n", name)
+		}
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		server  bool
+		workers int
+		param   []string
+		events  []string
+	}{
+		{true, 0, []string{`["a","c"]`}, []string{"a", "a", "b", "c"}},
+		{false, 0, nil, []string{"a", "a", "c"}},
+		{false, 2, nil, []string{"a", "a", "c"}},
+	} {
+		mix := NewWithURL("product", "key", "secret", server.URL)
+		mix.Events = []string{"a", "c"}
+		mix.ServerSideFilter = tc.server
+		mix.DecodeWorkers = tc.workers
+
+		output := make(chan EventData, 10)
+		stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil)
+		close(output)
+
+		if err != nil {
+			t.Fatalf("server=%v: raised error: %v", tc.server, err)
+		}
+
+		if !reflect.DeepEqual(param, tc.param) {
+			t.Errorf("server=%v: expected event param %q, got %q", tc.server, tc.param, param)
+		}
+
+		var names []string
+		for event := range output {
+			names = append(names, event["event"].(string))
+		}
+		sort.Strings(names)
+
+		if !reflect.DeepEqual(names, tc.events) || stats.EventsExported != len(tc.events) {
+			t.Errorf("server=%v workers=%d: expected %q, got %q (%d exported)",
+				tc.server, tc.workers, tc.events, names, stats.EventsExported)
+		}
+	}
+}
+
+func TestClientSideFilterKeepsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error": "something went wrong"}`+"\n")
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Events = []string{"a"}
+	mix.ServerSideFilter = false
+
+	if _, err := mix.ExportDate(context.Background(), time.Now(), make(chan EventData, 1), nil); err == nil {
+		t.Error("expected the error envelope to be reported")
+	}
+}
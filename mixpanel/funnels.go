@@ -0,0 +1,118 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// funnelUsersScript is the JQL which lists the users who got exactly as far
+// as a step of a funnel. Each user's events are walked in time order,
+// advancing through the steps whenever the next one comes up, and the users
+// who stopped at the step asked for are kept.
+const funnelUsersScript = `var steps = %s;
+
+function main() {
+  return Events({
+    from_date: %q,
+    to_date: %q,
+    event_selectors: steps.map(function(name) { return {event: name}; })
+  })
+  .groupByUser(function(reached, events) {
+    reached = reached || 0;
+    for (var i = 0; i < events.length && reached < steps.length; i++) {
+      if (events[i].name === steps[reached]) {
+        reached++;
+      }
+    }
+    return reached;
+  })
+  .filter(function(user) { return user.value === %d; })
+  .map(function(user) { return user.key[0]; });
+}
+`
+
+// ExportFunnelUsers streams the users who reached step `step` (counting from
+// 1) of the saved funnel `funnelID` from `from` through `to` (inclusive), but
+// not the step after it, as `{"distinct_id": ...}` records over `output`.
+// The last step lists the users who completed the funnel.
+//
+// The steps are looked up from the funnel, and the users are found with a
+// JQL query over their events. This follows the funnel's order of events,
+// but not its conversion window or any property filters on its steps, so
+// it can count users Mixpanel's own report wouldn't.
+//
+// Returns the number of users sent and possibly an error.
+func (m *Mixpanel) ExportFunnelUsers(ctx context.Context, funnelID int, step int, from, to time.Time, output chan<- EventData) (int, error) {
+	steps, err := m.funnelSteps(ctx, funnelID, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	if step < 1 || step > len(steps) {
+		return 0, fmt.Errorf("%s: funnel %d has no step %d (it has %d)", m.Product, funnelID, step, len(steps))
+	}
+
+	// Can't fail on a slice of strings.
+	encoded, _ := json.Marshal(steps)
+
+	args := m.baseArgs()
+	args.Set("script", fmt.Sprintf(funnelUsersScript, encoded, from.Format("2006-01-02"), to.Format("2006-01-02"), step))
+
+	var users []string
+	if err := m.request(ctx, "POST", EndpointJQL, args, &users); err != nil {
+		return 0, err
+	}
+
+	for i, user := range users {
+		if err := sendEvent(ctx, output, EventData{"distinct_id": user}); err != nil {
+			return i, err
+		}
+	}
+
+	return len(users), nil
+}
+
+// funnelSteps returns the names of the events making up the steps of the
+// funnel `funnelID`, in order.
+func (m *Mixpanel) funnelSteps(ctx context.Context, funnelID int, from, to time.Time) ([]string, error) {
+	args := m.baseArgs()
+	args.Set("funnel_id", strconv.Itoa(funnelID))
+	setDateRange(args, EndpointFunnels, from, to)
+
+	// Response has the form:
+	//   {"data": {"YYYY-MM-DD": {"steps": [{"event": "...", "count": 123}]}}}
+	var resp struct {
+		Data map[string]struct {
+			Steps []struct {
+				Event string
+			}
+		}
+	}
+
+	if err := m.query(ctx, EndpointFunnels, args, &resp); err != nil {
+		return nil, err
+	}
+
+	// Every day has the same steps; take the first.
+	var days []string
+	for day := range resp.Data {
+		days = append(days, day)
+	}
+
+	if len(days) == 0 {
+		return nil, fmt.Errorf("%s: funnel %d has no data from %s to %s", m.Product, funnelID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+
+	sort.Strings(days)
+
+	var steps []string
+	for _, s := range resp.Data[days[0]].Steps {
+		steps = append(steps, s.Event)
+	}
+
+	return steps, nil
+}
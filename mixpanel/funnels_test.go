@@ -0,0 +1,73 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportFunnelUsers(t *testing.T) {
+	var script string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		switch r.URL.Path {
+		case "/" + EndpointFunnels:
+			if r.Form.Get("funnel_id") != "42" || r.Form.Get("from_date") != "2014-01-01" || r.Form.Get("to_date") != "2014-01-07" {
+				t.Errorf("unexpected funnel query: %s", r.URL.RawQuery)
+			}
+
+			w.Write([]byte(`{"data": {
+				"2014-01-02": {"steps": [{"event": "signup", "count": 10}, {"event": "activate", "count": 5}, {"event": "purchase", "count": 1}]},
+				"2014-01-01": {"steps": [{"event": "signup", "count": 12}, {"event": "activate", "count": 4}, {"event": "purchase", "count": 0}]}
+			}}`))
+		case "/" + EndpointJQL:
+			if r.Method != "POST" || r.Form.Get("sig") == "" {
+				t.Errorf("expected a signed POST, got %s %v", r.Method, r.Form)
+			}
+
+			script = r.Form.Get("script")
+			w.Write([]byte(`["alice", "bob"]`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	output := make(chan EventData, 2)
+
+	from, to := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2014, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	n, err := mix.ExportFunnelUsers(context.Background(), 42, 2, from, to, output)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if n != 2 {
+		t.Errorf("expected 2 users, got %d", n)
+	}
+
+	for _, want := range []string{
+		`var steps = ["signup","activate","purchase"];`,
+		`from_date: "2014-01-01"`,
+		`to_date: "2014-01-07"`,
+		`user.value === 2;`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %s, got:\n%s", want, script)
+		}
+	}
+
+	for _, want := range []string{"alice", "bob"} {
+		if user := <-output; user["distinct_id"] != want {
+			t.Errorf("expected %s, got %v", want, user)
+		}
+	}
+
+	if _, err := mix.ExportFunnelUsers(context.Background(), 42, 4, from, to, output); err == nil {
+		t.Error("expected an error for a step past the end of the funnel")
+	}
+}
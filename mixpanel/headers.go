@@ -0,0 +1,78 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// headersKey is the context key of the headers added by `WithHeaders`.
+type headersKey struct{}
+
+// WithHeaders returns a copy of `ctx` carrying extra request headers for the
+// calls made with it, such as a correlation ID for one run. They are added on
+// top of the `Headers` field, replacing any given there under the same name.
+// Calling it again on the returned context adds to them in the same way.
+func WithHeaders(ctx context.Context, headers http.Header) context.Context {
+	merged := headersFrom(ctx).Clone()
+	if merged == nil {
+		merged = make(http.Header)
+	}
+
+	for k, vs := range headers {
+		merged[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+	}
+
+	return context.WithValue(ctx, headersKey{}, merged)
+}
+
+// headersFrom returns the headers added to `ctx` by `WithHeaders`, if any.
+func headersFrom(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(headersKey{}).(http.Header)
+	return headers
+}
+
+// setHeaders adds the configured `Headers` and those of `ctx` to `req`, if
+// it's going to one of the Mixpanel hosts we're configured with (see
+// `mixpanelHost`). Headers the request already sets itself (authentication,
+// `Accept`, ...) are never replaced.
+func (m *Mixpanel) setHeaders(ctx context.Context, req *http.Request) {
+	if !m.mixpanelHost(req.URL) {
+		return
+	}
+
+	own := make(map[string]bool, len(req.Header))
+	for k := range req.Header {
+		own[http.CanonicalHeaderKey(k)] = true
+	}
+
+	for _, headers := range []http.Header{m.Headers, headersFrom(ctx)} {
+		for k, vs := range headers {
+			if k = http.CanonicalHeaderKey(k); !own[k] {
+				req.Header[k] = append([]string(nil), vs...)
+			}
+		}
+	}
+}
+
+// stripHeaders removes the headers added by `setHeaders` from `req`.
+func (m *Mixpanel) stripHeaders(req *http.Request) {
+	for _, headers := range []http.Header{m.Headers, headersFrom(req.Context())} {
+		for k := range headers {
+			req.Header.Del(k)
+		}
+	}
+}
+
+// mixpanelHost reports whether `u` is on the host of one of the `Endpoints`.
+// Anything else, like the presigned URL an export job's result is downloaded
+// from, mustn't be sent our extra headers, which may well hold secrets.
+func (m *Mixpanel) mixpanelHost(u *url.URL) bool {
+	for _, base := range m.Endpoints.urls() {
+		if parsed, err := url.Parse(base); err == nil && parsed.Host != "" && parsed.Host == u.Host {
+			return true
+		}
+	}
+
+	return false
+}
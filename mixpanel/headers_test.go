@@ -0,0 +1,110 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeaders(t *testing.T) {
+	var got http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Endpoints.App = server.URL
+	mix.ProjectID = "1"
+	mix.ServiceAccountUser = "user"
+	mix.ServiceAccountSecret = "pass"
+	mix.Headers = http.Header{
+		"X-Gateway-Key":    {"gateway"},
+		"X-Correlation-Id": {"struct"},
+		"Authorization":    {"Bearer clobbered"},
+		"Accept":           {"text/plain"},
+	}
+
+	if _, err := mix.ExportDate(context.Background(), time.Now(), make(chan EventData, 1), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if got.Get("X-Gateway-Key") != "gateway" || got.Get("X-Correlation-Id") != "struct" {
+		t.Errorf("expected configured headers, got %v", got)
+	} else if got.Get("Accept") == "text/plain" {
+		t.Error("Accept header was replaced")
+	}
+
+	ctx := WithHeaders(context.Background(), http.Header{"x-correlation-id": {"call"}})
+	if _, err := mix.ExportPeopleBulk(ctx, make(chan EventData, 1)); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if got.Get("X-Correlation-Id") != "call" || len(got["X-Correlation-Id"]) != 1 {
+		t.Errorf("expected per call header to override, got %q", got["X-Correlation-Id"])
+	} else if got.Get("X-Gateway-Key") != "gateway" {
+		t.Error("per call headers dropped the configured ones")
+	}
+
+	if user, pass, ok := (&http.Request{Header: got}).BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("auth header was clobbered: %q", got.Get("Authorization"))
+	}
+}
+
+func TestHeadersNotSentToForeignHosts(t *testing.T) {
+	var got http.Header
+
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer foreign.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"status": "complete", "url": "%s/result"}`, foreign.URL)
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+	mix.Headers = http.Header{"X-Gateway-Key": {"gateway"}}
+
+	ctx := WithHeaders(context.Background(), http.Header{"X-Correlation-Id": {"call"}})
+	if _, err := mix.DownloadExportJob(ctx, "job1", make(chan EventData)); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if got == nil {
+		t.Fatal("result was never downloaded")
+	} else if got.Get("X-Gateway-Key") != "" || got.Get("X-Correlation-Id") != "" {
+		t.Errorf("headers leaked to a foreign host: %v", got)
+	}
+}
+
+func TestRedirectStripsHeaders(t *testing.T) {
+	mix := New("product", "key", "secret")
+	mix.Headers = http.Header{"X-Gateway-Key": {"gateway"}}
+
+	original, _ := http.NewRequest("GET", "https://data.mixpanel.com/api/2.0/export", nil)
+
+	for _, tc := range []struct {
+		target string
+		kept   bool
+	}{
+		{"https://eu.mixpanel.com/api/2.0/export", true},
+		{"https://elsewhere.example.com/export", false},
+	} {
+		req, _ := http.NewRequest("GET", tc.target, nil)
+		req.Header.Set("X-Gateway-Key", "gateway")
+
+		mix.checkRedirect(req, []*http.Request{original})
+
+		if kept := req.Header.Get("X-Gateway-Key") != ""; kept != tc.kept {
+			t.Errorf("%s: expected header kept=%v", tc.target, tc.kept)
+		}
+	}
+}
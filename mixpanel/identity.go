@@ -0,0 +1,40 @@
+package mixpanel
+
+// ResolvedIDKey is the key the canonical identity of an event is attached
+// under when `ResolveIdentity` is set.
+const ResolvedIDKey = "resolved_id"
+
+// DefaultIdentityPrecedence is the order identity properties are tried in by
+// `ResolveIdentity` when `IdentityPrecedence` isn't set: the identified user
+// of Mixpanel's ID merge model first, then the plain distinct ID, then the
+// anonymous device.
+var DefaultIdentityPrecedence = []string{PropUserID, "distinct_id", PropDeviceID}
+
+// identityPrecedence returns the properties to resolve identity from, in
+// order.
+func (m *Mixpanel) identityPrecedence() []string {
+	if len(m.IdentityPrecedence) == 0 {
+		return DefaultIdentityPrecedence
+	}
+
+	return m.IdentityPrecedence
+}
+
+// resolveIdentity returns the value of the first property in the identity
+// precedence which is set (and not empty) in `props`, or nil if none are.
+func (m *Mixpanel) resolveIdentity(props map[string]interface{}) interface{} {
+	for _, key := range m.identityPrecedence() {
+		switch value := props[key].(type) {
+		case nil:
+			continue
+		case string:
+			if value == "" {
+				continue
+			}
+		}
+
+		return props[key]
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package mixpanel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveIdentity(t *testing.T) {
+	cases := []struct {
+		Properties string
+		Expected   interface{}
+	}{
+		{`{"$user_id": "user", "distinct_id": "distinct", "$device_id": "device"}`, "user"},
+		{`{"$user_id": "user", "$device_id": "device"}`, "user"},
+		{`{"$user_id": "user"}`, "user"},
+		{`{"distinct_id": "distinct", "$device_id": "device"}`, "distinct"},
+		{`{"distinct_id": "distinct"}`, "distinct"},
+		{`{"$device_id": "device"}`, "device"},
+		{`{"$user_id": "", "distinct_id": "distinct"}`, "distinct"},
+		{`{"$user_id": null, "$device_id": "device"}`, "device"},
+		{`{"plan": "free"}`, nil},
+	}
+
+	mix := New("product", "", "")
+	mix.ResolveIdentity = true
+	mix.IncludeImplicit = false
+
+	for _, c := range cases {
+		input := strings.NewReader(`{"event": "a", "properties": ` + c.Properties + `}`)
+		output := make(chan EventData, 1)
+
+		if _, err := mix.TransformEventData(input, output); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		event := <-output
+
+		if resolved, ok := event[ResolvedIDKey]; resolved != c.Expected || (c.Expected == nil && ok) {
+			t.Errorf("%s: expected resolved_id %v, got %v", c.Properties, c.Expected, resolved)
+		}
+	}
+}
+
+func TestResolveIdentityPrecedence(t *testing.T) {
+	mix := New("product", "", "")
+	mix.ResolveIdentity = true
+	mix.IdentityPrecedence = []string{"$device_id", "distinct_id"}
+
+	input := strings.NewReader(`{"event": "a", "properties": {"$user_id": "user", "distinct_id": "distinct", "$device_id": "device", "resolved_id": "mine"}}`)
+	output := make(chan EventData, 1)
+
+	if _, err := mix.TransformEventData(input, output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	event := <-output
+
+	if event[ResolvedIDKey] != "device" {
+		t.Errorf("expected the configured precedence to be used, got %v", event[ResolvedIDKey])
+	} else if event[CollisionPrefix+ResolvedIDKey] != "mine" {
+		t.Errorf("expected an existing resolved_id to be preserved, got %v", event)
+	}
+}
@@ -0,0 +1,26 @@
+package mixpanel
+
+import "strings"
+
+// ProtectedProperties are implicit properties which are kept even when
+// `IncludeImplicit` is false, since they're needed to identify and
+// de-duplicate events.
+var ProtectedProperties = map[string]bool{
+	PropInsertID:   true,
+	PropDistinctID: true,
+}
+
+// isImplicit reports whether the property is one Mixpanel adds by itself.
+func isImplicit(key string) bool {
+	return strings.HasPrefix(key, "$") || strings.HasPrefix(key, "mp_")
+}
+
+// stripImplicit removes all implicit properties from `props`, keeping those
+// in `ProtectedProperties`.
+func stripImplicit(props map[string]interface{}) {
+	for key := range props {
+		if isImplicit(key) && !ProtectedProperties[key] {
+			delete(props, key)
+		}
+	}
+}
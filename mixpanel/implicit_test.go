@@ -0,0 +1,51 @@
+package mixpanel
+
+import (
+	"strings"
+	"testing"
+)
+
+func transformOne(t *testing.T, mix *Mixpanel, input string) EventData {
+	output := make(chan EventData, 1)
+
+	if _, err := mix.TransformEventData(strings.NewReader(input), output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	return <-output
+}
+
+const implicitEvent = `{"event": "a", "properties": {"$city": "Paris", "mp_country_code": "FR", ` +
+	`"$insert_id": "abc", "$distinct_id": "d", "distinct_id": "d", "custom": 1, "time": 1388534400}}`
+
+func TestIncludeImplicitDefault(t *testing.T) {
+	event := transformOne(t, New("product", "", ""), implicitEvent)
+
+	for _, key := range []string{"$city", "mp_country_code", "$insert_id", "custom"} {
+		if _, ok := event[key]; !ok {
+			t.Errorf("expected %s by default: %v", key, event)
+		}
+	}
+}
+
+func TestStripImplicit(t *testing.T) {
+	mix := New("product", "", "")
+	mix.IncludeImplicit = false
+
+	event := transformOne(t, mix, implicitEvent)
+
+	for _, key := range []string{"$city", "mp_country_code"} {
+		if _, ok := event[key]; ok {
+			t.Errorf("expected %s to be stripped: %v", key, event)
+		}
+	}
+
+	// Protected and custom properties, plus the ones we attach ourselves
+	// should all survive.
+	for _, key := range []string{"$insert_id", "$distinct_id", "distinct_id", "custom", "time",
+		"event", "product", EventIDKey, TimestampKey} {
+		if _, ok := event[key]; !ok {
+			t.Errorf("expected %s to survive: %v", key, event)
+		}
+	}
+}
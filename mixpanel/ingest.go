@@ -0,0 +1,108 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// ImportEvent is an event to send to Mixpanel with `Import`, in the shape
+// Mixpanel expects rather than flattened like `EventData`.
+type ImportEvent struct {
+	Event      string                 `json:"event"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// importShape restructures an event into the shape of an `ImportEvent`, for
+// `ImportShape`.
+func (m *Mixpanel) importShape(ev *rawEvent) (EventData, error) {
+	if m.Token == "" {
+		return nil, fmt.Errorf("%s: import shape: %w", m.Product, ErrMissingToken)
+	}
+
+	ev.Properties["token"] = m.Token
+
+	return EventData{"event": ev.Event, "properties": ev.Properties}, nil
+}
+
+// Import sends `events` to the ingestion API's import endpoint. Unlike the
+// export and query endpoints it authenticates with the project `Token`
+// (attached to each event), so it works for projects without an API secret.
+// The API `Key` is sent along too when there is one.
+func (m *Mixpanel) Import(ctx context.Context, events []ImportEvent) error {
+	if m.Token == "" {
+		return fmt.Errorf("%s: %s: %w", m.Product, EndpointImport, ErrMissingToken)
+	}
+
+	tokened := make([]ImportEvent, len(events))
+
+	// Don't modify the caller's properties.
+	for i, ev := range events {
+		props := make(map[string]interface{}, len(ev.Properties)+1)
+		for k, v := range ev.Properties {
+			props[k] = v
+		}
+
+		props["token"] = m.Token
+		tokened[i] = ImportEvent{Event: ev.Event, Properties: props}
+	}
+
+	args := url.Values{}
+	if m.Key != "" {
+		args.Set("api_key", m.Key)
+	}
+
+	return m.ingest(ctx, EndpointImport, args, tokened)
+}
+
+// PeopleSet sets `props` on the People profile of `distinctID`, creating the
+// profile if need be. Like `Import`, only the project `Token` is needed.
+func (m *Mixpanel) PeopleSet(ctx context.Context, distinctID string, props map[string]interface{}) error {
+	if m.Token == "" {
+		return fmt.Errorf("%s: %s: %w", m.Product, EndpointEngage, ErrMissingToken)
+	}
+
+	update := map[string]interface{}{
+		PropToken:      m.Token,
+		PropDistinctID: distinctID,
+		"$set":         props,
+	}
+
+	return m.ingest(ctx, EndpointEngage, url.Values{}, []interface{}{update})
+}
+
+// ingest sends `data` to an ingestion endpoint, in the base64 encoded form
+// they all accept, and checks the verbose response for errors.
+func (m *Mixpanel) ingest(ctx context.Context, endpoint string, args url.Values, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("%s: %s: encoding data failed: %w", m.Product, endpoint, err)
+	}
+
+	args.Set("data", base64.StdEncoding.EncodeToString(encoded))
+	args.Set("verbose", "1")
+
+	req, err := m.newFormPost(fmt.Sprintf("%s/%s", m.Endpoints.Ingestion, endpoint), endpoint, args.Encode())
+	if err != nil {
+		return fmt.Errorf("%s: building request failed: %w", m.Product, err)
+	}
+
+	// Response has the form:
+	//   {"status": 1, "error": null}
+	var result struct {
+		Status int
+		Error  string
+	}
+
+	if err := m.send(ctx, req, endpoint, &result); err != nil {
+		return err
+	}
+
+	if result.Status != 1 {
+		return fmt.Errorf("%s: %s: rejected: %s", m.Product, endpoint, result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newIngestServer records the decoded `data` of each request by path.
+func newIngestServer(t *testing.T, received map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Method != "POST" {
+			t.Errorf("expected a form POST, got %s: %v", r.Method, err)
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(r.PostForm.Get("data"))
+		if err != nil {
+			t.Errorf("bad data: %v", err)
+		}
+
+		var data interface{}
+		json.Unmarshal(raw, &data)
+		received[r.URL.Path] = data
+
+		fmt.Fprint(w, `{"status": 1, "error": null}`)
+	}))
+}
+
+func TestImportTokenOnly(t *testing.T) {
+	received := make(map[string]interface{})
+	server := newIngestServer(t, received)
+	defer server.Close()
+
+	mix := New("product", "", "")
+	mix.Endpoints.Ingestion = server.URL
+	mix.Token = "token"
+
+	props := map[string]interface{}{"distinct_id": "a", "time": 1}
+	if err := mix.Import(context.Background(), []ImportEvent{{Event: "signup", Properties: props}}); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := []interface{}{map[string]interface{}{
+		"event":      "signup",
+		"properties": map[string]interface{}{"distinct_id": "a", "time": 1.0, "token": "token"},
+	}}
+
+	if !reflect.DeepEqual(received["/import"], expected) {
+		t.Errorf("expected %v, got %v", expected, received["/import"])
+	}
+
+	if _, ok := props["token"]; ok {
+		t.Error("caller's properties were modified")
+	}
+
+	if err := mix.PeopleSet(context.Background(), "a", map[string]interface{}{"plan": "pro"}); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected = []interface{}{map[string]interface{}{
+		"$token":       "token",
+		"$distinct_id": "a",
+		"$set":         map[string]interface{}{"plan": "pro"},
+	}}
+
+	if !reflect.DeepEqual(received["/engage"], expected) {
+		t.Errorf("expected %v, got %v", expected, received["/engage"])
+	}
+}
+
+func TestImportRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": 0, "error": "token, missing or empty"}`)
+	}))
+	defer server.Close()
+
+	mix := New("product", "", "")
+	mix.Endpoints.Ingestion = server.URL
+
+	if err := mix.Import(context.Background(), nil); !errors.Is(err, ErrMissingToken) {
+		t.Errorf("expected ErrMissingToken, got %v", err)
+	}
+
+	mix.Token = "token"
+
+	if err := mix.Import(context.Background(), nil); err == nil {
+		t.Error("expected a rejected import to fail")
+	}
+}
+
+func TestImportShape(t *testing.T) {
+	mix := New("product", "key", "secret")
+	mix.Token = "token"
+	mix.ImportShape = true
+	mix.HashProperties = map[string]string{"email": "salt"}
+
+	input := strings.NewReader(`{"event": "signup", "properties": {"time": 1400000000, "distinct_id": "a", "$city": "Paris", "email": "a@example.com", "token": "old"}}`)
+	output := make(chan EventData, 1)
+
+	if _, err := mix.TransformEventData(input, output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := EventData{
+		"event": "signup",
+		"properties": map[string]interface{}{
+			"time":        json.Number("1400000000"),
+			"distinct_id": "a",
+			"$city":       "Paris",
+			"email":       hashValue("salt", "a@example.com"),
+			"token":       "token",
+		},
+	}
+
+	if event := <-output; !reflect.DeepEqual(event, expected) {
+		t.Errorf("expected %v, got %v", expected, event)
+	}
+
+	// Without a token the events couldn't be imported.
+	mix.Token = ""
+
+	if _, err := mix.TransformEventData(strings.NewReader(`{"event": "a"}`), make(chan EventData, 1)); !errors.Is(err, ErrMissingToken) {
+		t.Errorf("expected ErrMissingToken, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Number of events an `EventIterator` reads ahead of its caller.
+const iteratorBuffer = 100
+
+// EventIterator pulls the events of an export one at a time, for callers who
+// would rather not manage the channel and goroutine of `ExportDate`. Call
+// `Next` until it returns false, then check `Err`, and `Close` the iterator
+// when done with it (deferring it is fine), even if it's left unfinished.
+//
+// Range over func iterators need a newer Go than this package supports, so
+// there is no `All`. An EventIterator is not safe for concurrent use.
+type EventIterator struct {
+	events <-chan EventData
+	cancel context.CancelFunc
+
+	// Set by the export before `events` is closed.
+	stats Stats
+	err   error
+}
+
+// NewEventIterator starts exporting `date` (as `ExportDateWithOptions`
+// would) and returns an iterator over its events. The export stops when
+// `ctx` is done or the iterator is closed, whichever comes first.
+func NewEventIterator(ctx context.Context, m *Mixpanel, date time.Time, opts ExportOptions) (*EventIterator, error) {
+	args, err := opts.Args()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Product, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	events := make(chan EventData, iteratorBuffer)
+
+	it := &EventIterator{events: events, cancel: cancel}
+
+	go func() {
+		defer close(events)
+		it.stats, it.err = m.ExportDate(ctx, date, events, args)
+	}()
+
+	return it, nil
+}
+
+// Next returns the next event, waiting for it to be downloaded if need be.
+// Once the export is over (or failed), it returns false, and `Err` tells
+// which.
+func (it *EventIterator) Next() (EventData, bool) {
+	event, ok := <-it.events
+	return event, ok
+}
+
+// Err returns the error the export failed with, if any. It's only set once
+// `Next` has returned false.
+func (it *EventIterator) Err() error {
+	return it.err
+}
+
+// Stats returns the stats of the export, once `Next` has returned false.
+func (it *EventIterator) Stats() Stats {
+	return it.stats
+}
+
+// Close stops the export, if it's still going, and waits for it to release
+// its connection. The events not yet read are discarded. It's safe to call
+// more than once, and after the events have run out.
+func (it *EventIterator) Close() error {
+	it.cancel()
+
+	for range it.events {
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventIterator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("where") != `properties["plan"] == "paid"` {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"i": %d}}`+"\n", i)
+		}
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	it, err := NewEventIterator(context.Background(), mix, time.Now(), ExportOptions{Where: `properties["plan"] == "paid"`})
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+	defer it.Close()
+
+	n := 0
+	for event, ok := it.Next(); ok; event, ok = it.Next() {
+		if event["i"] != json.Number(fmt.Sprint(n)) {
+			t.Errorf("expected event %d, got %v", n, event)
+		}
+
+		n++
+	}
+
+	if n != 5 || it.Stats().EventsExported != 5 {
+		t.Errorf("expected 5 events, got %d (%d exported)", n, it.Stats().EventsExported)
+	}
+
+	if err := it.Err(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if _, ok := it.Next(); ok {
+		t.Error("expected the iterator to stay exhausted")
+	}
+
+	if _, err := NewEventIterator(context.Background(), mix, time.Now(), ExportOptions{Limit: -1}); err == nil {
+		t.Error("expected invalid options to be rejected upfront")
+	}
+}
+
+func TestEventIteratorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	it, err := NewEventIterator(context.Background(), NewWithURL("product", "key", "secret", server.URL), time.Now(), ExportOptions{})
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+	defer it.Close()
+
+	if _, ok := it.Next(); ok {
+		t.Error("expected no events")
+	}
+
+	if !errors.Is(it.Err(), ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", it.Err())
+	}
+}
+
+func TestEventIteratorClose(t *testing.T) {
+	released := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(released)
+
+		// Stream forever, until the client goes away.
+		for i := 0; r.Context().Err() == nil; i++ {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"i": %d}}`+"\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	it, err := NewEventIterator(context.Background(), NewWithURL("product", "key", "secret", server.URL), time.Now(), ExportOptions{})
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if _, ok := it.Next(); !ok {
+		t.Fatalf("expected an event, got %v", it.Err())
+	}
+
+	it.Close()
+
+	select {
+	case <-released:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected closing to release the connection")
+	}
+
+	if _, ok := it.Next(); ok {
+		t.Error("expected no more events after closing")
+	}
+}
@@ -0,0 +1,156 @@
+package mixpanel
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Status values reported for an asynchronous export job.
+const (
+	JobPending  = "pending"
+	JobRunning  = "running"
+	JobComplete = "complete"
+	JobFailed   = "failed"
+)
+
+// Default bounds on how often `DownloadExportJob` polls for job completion.
+const (
+	DefaultJobPollInterval    = 5 * time.Second
+	DefaultJobMaxPollInterval = 2 * time.Minute
+)
+
+// ExportJob describes the state of an asynchronous export job.
+//
+// - `URL` is only set once `Status` is `JobComplete`, and points at the
+//   (possibly gzipped) result file.
+// - `Error` is only set once `Status` is `JobFailed`.
+type ExportJob struct {
+	ID     string `json:"job_id"`
+	Status string
+	URL    string
+	Error  string
+}
+
+// StartExportJob submits an asynchronous export job covering `from` through
+// `to` (inclusive) and returns the ID of the job.
+//
+// For very large ranges this is more reliable than streaming each day with
+// `ExportDate`. The optional `moreArgs` parameter can be given to add
+// additional parameters to the job request, in the same way as for
+// `ExportDate`.
+func (m *Mixpanel) StartExportJob(ctx context.Context, from, to time.Time, moreArgs *url.Values) (string, error) {
+	args := m.baseArgs()
+	setDateRange(args, EndpointExportJobs, from, to)
+	mergeArgs(args, moreArgs, EndpointExportJobs)
+
+	var job ExportJob
+	if err := m.request(ctx, "POST", EndpointExportJobs, args, &job); err != nil {
+		return "", err
+	}
+
+	if job.ID == "" {
+		return "", fmt.Errorf("%s: export job submitted but no job ID returned", m.Product)
+	}
+
+	return job.ID, nil
+}
+
+// ExportJobStatus fetches the current state of the given export job.
+func (m *Mixpanel) ExportJobStatus(ctx context.Context, jobID string) (*ExportJob, error) {
+	var job ExportJob
+	if err := m.query(ctx, "export/jobs/"+url.PathEscape(jobID), m.baseArgs(), &job); err != nil {
+		return nil, err
+	}
+
+	if job.ID == "" {
+		job.ID = jobID
+	}
+
+	return &job, nil
+}
+
+// DownloadExportJob waits for the given export job to complete, then streams
+// the result file over `output` in the same way as `ExportDate`.
+//
+// Polling starts at `JobPollInterval` and doubles after each check up to
+// `JobMaxPollInterval`. The wait can be abandoned by cancelling `ctx`.
+//
+// Returns statistics about the records that have been processed and possibly
+// an error.
+func (m *Mixpanel) DownloadExportJob(ctx context.Context, jobID string, output chan<- EventData) (Stats, error) {
+	interval, maxInterval := m.JobPollInterval, m.JobMaxPollInterval
+
+	if interval <= 0 {
+		interval = DefaultJobPollInterval
+	}
+
+	if maxInterval <= 0 {
+		maxInterval = DefaultJobMaxPollInterval
+	}
+
+	var job *ExportJob
+
+	for {
+		var err error
+		if job, err = m.ExportJobStatus(ctx, jobID); err != nil {
+			return Stats{}, err
+		}
+
+		if job.Status == JobComplete {
+			break
+		} else if job.Status == JobFailed {
+			return Stats{}, fmt.Errorf("%s: export job %s failed: %s", m.Product, jobID, job.Error)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return Stats{}, fmt.Errorf("%s: waiting on export job %s: %s", m.Product, jobID, ctx.Err())
+		}
+
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+
+	req, err := http.NewRequest("GET", job.URL, nil)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: building request failed: %w", m.Product, redactError(err))
+	}
+
+	resp, err := m.do(ctx, req)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: download failed: %w", m.Product, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Stats{}, fmt.Errorf("%s: export job %s download failed: %s", m.Product, jobID, resp.Status)
+	}
+
+	body, err := maybeGunzip(resp.Body)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: export job %s: %w", m.Product, jobID, err)
+	}
+
+	return m.TransformEventData(body, output)
+}
+
+// maybeGunzip transparently decompresses `r` if it starts with the gzip magic
+// number, and otherwise passes it through untouched.
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	buf := bufio.NewReader(r)
+
+	if magic, err := buf.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(buf)
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,100 @@
+package mixpanel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExportJob(t *testing.T) {
+	body := `{"event": "a", "properties": {"b": "b0"}}
+{"event": "a", "properties": {"b": "b1"}}
+`
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(body))
+	gz.Close()
+
+	polls := 0
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/export/jobs":
+			if r.Method != "POST" {
+				t.Errorf("expected POST, got %s", r.Method)
+			} else if r.FormValue("from_date") != "2014-01-01" || r.FormValue("to_date") != "2014-01-31" {
+				t.Errorf("bad date range: %s", r.Form)
+			}
+
+			fmt.Fprint(w, `{"job_id": "job1"}`)
+		case "/export/jobs/job1":
+			// Pretend the job takes a couple polls to finish.
+			if polls++; polls < 3 {
+				fmt.Fprint(w, `{"status": "running"}`)
+			} else {
+				fmt.Fprintf(w, `{"status": "complete", "url": "%s/result"}`, server.URL)
+			}
+		case "/result":
+			w.Write(gzipped.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+	mix.JobPollInterval = time.Millisecond
+
+	from, _ := time.Parse("2006-01-02", "2014-01-01")
+	to, _ := time.Parse("2006-01-02", "2014-01-31")
+
+	ctx := context.Background()
+
+	jobID, err := mix.StartExportJob(ctx, from, to, nil)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if jobID != "job1" {
+		t.Fatalf("expected job1, got %s", jobID)
+	}
+
+	output := make(chan EventData, 2)
+
+	if stats, err := mix.DownloadExportJob(ctx, jobID, output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 2 {
+		t.Errorf("expected 2 records, got %d", stats.EventsExported)
+	} else if polls != 3 {
+		t.Errorf("expected 3 polls, got %d", polls)
+	}
+
+	for i := 0; i < 2; i++ {
+		if event := <-output; event["b"] != fmt.Sprintf("b%d", i) {
+			t.Errorf("bad event: %v", event)
+		}
+	}
+}
+
+func TestExportJobFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": "failed", "error": "out of cheese"}`)
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	output := make(chan EventData)
+
+	if _, err := mix.DownloadExportJob(context.Background(), "job1", output); err == nil {
+		t.Error("expected error for failed job")
+	} else if err.Error() != "product: export job job1 failed: out of cheese" {
+		t.Errorf("Bad error string: '%s'", err.Error())
+	}
+}
@@ -0,0 +1,94 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Default bucket bounds of a `LagHistogram`.
+var DefaultLagBuckets = []time.Duration{
+	time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// EventTime returns the time an event happened, taken from its `time`
+// property, and whether it had a usable one.
+func EventTime(event EventData) (time.Time, bool) {
+	var secs int64
+
+	switch t := event["time"].(type) {
+	case json.Number:
+		n, err := t.Int64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		secs = n
+	case int64:
+		secs = t
+	case int:
+		secs = int64(t)
+	case float64:
+		secs = int64(t)
+	default:
+		return time.Time{}, false
+	}
+
+	return time.Unix(secs, 0).UTC(), true
+}
+
+// EventLag returns how long ago (according to `Clock`) the given event
+// happened, and whether it had a usable `time` property. Events with a clock
+// skewed into the future have a negative lag.
+func (m *Mixpanel) EventLag(event EventData) (time.Duration, bool) {
+	t, ok := EventTime(event)
+	if !ok {
+		return 0, false
+	}
+
+	return m.now().Sub(t), true
+}
+
+// LagHistogram counts event lags in buckets, and can be used directly as
+// `OnEventLag`. It is safe for concurrent use.
+type LagHistogram struct {
+	// Bounds are the (ascending) upper bounds of each bucket.
+	Bounds []time.Duration
+
+	mu     sync.Mutex
+	counts []int
+}
+
+// NewLagHistogram creates a histogram with the given bucket bounds, or
+// `DefaultLagBuckets` if none are given.
+func NewLagHistogram(bounds ...time.Duration) *LagHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLagBuckets
+	}
+
+	return &LagHistogram{Bounds: bounds, counts: make([]int, len(bounds)+1)}
+}
+
+// Observe records a single lag.
+func (h *LagHistogram) Observe(lag time.Duration) {
+	i := 0
+	for i < len(h.Bounds) && lag > h.Bounds[i] {
+		i++
+	}
+
+	h.mu.Lock()
+	h.counts[i]++
+	h.mu.Unlock()
+}
+
+// Counts returns the number of lags seen in each bucket. The final count is
+// of lags greater than every bound.
+func (h *LagHistogram) Counts() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]int(nil), h.counts...)
+}
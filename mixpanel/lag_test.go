@@ -0,0 +1,76 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventLag(t *testing.T) {
+	now := time.Date(2014, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	mix := New("product", "", "")
+	mix.Clock = fixedClock(now)
+
+	var lags []time.Duration
+	mix.OnEventLag = func(lag time.Duration) {
+		lags = append(lags, lag)
+	}
+
+	input := strings.NewReader(strings.Join([]string{
+		`{"event": "a", "properties": {"time": 1388620800}}`, // now
+		`{"event": "b", "properties": {"time": 1388620740}}`, // a minute ago
+		`{"event": "c", "properties": {"time": 1388534400}}`, // a day ago
+		`{"event": "d", "properties": {"time": 1388624400}}`, // an hour from now
+		`{"event": "e", "properties": {}}`,
+	}, "\n"))
+
+	output := make(chan EventData, 5)
+	if _, err := mix.TransformEventData(input, output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := []time.Duration{0, time.Minute, 24 * time.Hour, -time.Hour}
+	if !reflect.DeepEqual(lags, expected) {
+		t.Errorf("expected lags %v, got %v", expected, lags)
+	}
+
+	for i := range expected {
+		event := <-output
+		if lag, ok := mix.EventLag(event); !ok || lag != expected[i] {
+			t.Errorf("%s: expected lag %v, got %v (%v)", event["event"], expected[i], lag, ok)
+		}
+	}
+
+	if _, ok := mix.EventLag(<-output); ok {
+		t.Error("expected no lag for event without a time")
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	expected := time.Unix(1388534400, 0).UTC()
+
+	for _, value := range []interface{}{json.Number("1388534400"), int64(1388534400), 1388534400, float64(1388534400)} {
+		if got, ok := EventTime(EventData{"time": value}); !ok || !got.Equal(expected) {
+			t.Errorf("%T: expected %v, got %v (%v)", value, expected, got, ok)
+		}
+	}
+
+	if _, ok := EventTime(EventData{"time": "yesterday"}); ok {
+		t.Error("expected string time to be unusable")
+	}
+}
+
+func TestLagHistogram(t *testing.T) {
+	h := NewLagHistogram(time.Minute, time.Hour)
+
+	for _, lag := range []time.Duration{-time.Second, time.Second, time.Minute, 2 * time.Minute, 2 * time.Hour} {
+		h.Observe(lag)
+	}
+
+	if counts := h.Counts(); !reflect.DeepEqual(counts, []int{3, 1, 1}) {
+		t.Errorf("expected [3 1 1], got %v", counts)
+	}
+}
@@ -0,0 +1,47 @@
+package mixpanel
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redactURL returns `raw` with its credentials (see `redactedArgs`) and any
+// password replaced by `Redacted`. A URL which doesn't parse loses its whole
+// query, since there's no telling which parts of it are credentials.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		if i := strings.IndexByte(raw, '?'); i >= 0 {
+			return raw[:i] + "?" + Redacted
+		}
+
+		return raw
+	}
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+		}
+	}
+
+	if u.RawQuery != "" {
+		u.RawQuery = redactArgs(u.Query()).Encode()
+	}
+
+	return u.String()
+}
+
+// redactError redacts the URL of a `*url.Error`, which is what both
+// `http.NewRequest` and `http.Client.Do` fail with, and which would otherwise
+// put a signed request's `api_key` and `sig` (or a presigned download's
+// signature) into our errors, and from there into logs. A copy is returned,
+// so the error still works with `errors.Is` and its `Timeout` method. Other
+// errors are returned as is.
+func redactError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+
+	return &url.Error{Op: urlErr.Op, URL: redactURL(urlErr.URL), Err: urlErr.Err}
+}
@@ -0,0 +1,147 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// Lexicon entity types.
+const (
+	EntityEvent   = "event"
+	EntityProfile = "profile"
+)
+
+// LexiconSchema is the full set of schemas defined in a project's Lexicon,
+// grouped by the type of entity they describe.
+type LexiconSchema struct {
+	Events   []SchemaEntity
+	Profiles []SchemaEntity
+}
+
+// SchemaEntity describes a single event (or profile) in the Lexicon, along
+// with its properties. Properties are sorted by name.
+type SchemaEntity struct {
+	EntityType  string
+	Name        string
+	Description string
+	Hidden      bool
+	Properties  []SchemaProperty
+}
+
+// SchemaProperty describes a single property of a SchemaEntity.
+type SchemaProperty struct {
+	Name        string
+	Type        string
+	Description string
+	Hidden      bool
+}
+
+// Mixpanel's representation of the metadata attached to schemas and their
+// properties.
+type lexiconMetadata struct {
+	Mixpanel struct {
+		Hidden bool
+	} `json:"com.mixpanel"`
+}
+
+// lexiconResponse is the raw response of the Lexicon schemas endpoints.
+type lexiconResponse struct {
+	Status  string
+	Results []struct {
+		EntityType string
+		Name       string
+		SchemaJSON struct {
+			Description string
+			Properties  map[string]struct {
+				Type        string
+				Description string
+				Metadata    lexiconMetadata
+			}
+			Metadata lexiconMetadata
+		} `json:"schemaJson"`
+	}
+}
+
+// entities converts the raw Lexicon response into SchemaEntities.
+func (r *lexiconResponse) entities() []SchemaEntity {
+	entities := make([]SchemaEntity, 0, len(r.Results))
+
+	for _, result := range r.Results {
+		entity := SchemaEntity{
+			EntityType:  result.EntityType,
+			Name:        result.Name,
+			Description: result.SchemaJSON.Description,
+			Hidden:      result.SchemaJSON.Metadata.Mixpanel.Hidden,
+		}
+
+		for name, prop := range result.SchemaJSON.Properties {
+			entity.Properties = append(entity.Properties, SchemaProperty{
+				Name:        name,
+				Type:        prop.Type,
+				Description: prop.Description,
+				Hidden:      prop.Metadata.Mixpanel.Hidden,
+			})
+		}
+
+		sort.Slice(entity.Properties, func(i, j int) bool {
+			return entity.Properties[i].Name < entity.Properties[j].Name
+		})
+
+		entities = append(entities, entity)
+	}
+
+	return entities
+}
+
+// GetLexiconSchema fetches every schema defined in the project's Lexicon.
+//
+// Requires `ProjectID` and service account credentials to be set.
+func (m *Mixpanel) GetLexiconSchema(ctx context.Context) (*LexiconSchema, error) {
+	entities, err := m.fetchSchemas(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	schema := new(LexiconSchema)
+
+	for _, entity := range entities {
+		switch entity.EntityType {
+		case EntityEvent:
+			schema.Events = append(schema.Events, entity)
+		case EntityProfile:
+			schema.Profiles = append(schema.Profiles, entity)
+		}
+	}
+
+	return schema, nil
+}
+
+// ListSchemaEntities fetches the Lexicon schemas for a single entity type
+// (e.g. `EntityEvent`).
+//
+// Requires `ProjectID` and service account credentials to be set.
+func (m *Mixpanel) ListSchemaEntities(ctx context.Context, entityType string) ([]SchemaEntity, error) {
+	return m.fetchSchemas(ctx, entityType)
+}
+
+// fetchSchemas requests the project's schemas, optionally restricted to one
+// entity type.
+func (m *Mixpanel) fetchSchemas(ctx context.Context, entityType string) ([]SchemaEntity, error) {
+	if m.ProjectID == "" {
+		return nil, fmt.Errorf("%s: Lexicon requests require a project ID", m.Product)
+	}
+
+	endpoint := fmt.Sprintf("projects/%s/schemas", url.PathEscape(m.ProjectID))
+	if entityType != "" {
+		endpoint += "/" + url.PathEscape(entityType)
+	}
+
+	var resp lexiconResponse
+	if err := m.appQuery(ctx, endpoint, url.Values{}, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.entities(), nil
+}
@@ -0,0 +1,118 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const lexiconPayload = `{
+  "status": "ok",
+  "results": [
+    {
+      "entityType": "event",
+      "name": "Signed Up",
+      "schemaJson": {
+        "description": "A user created an account",
+        "properties": {
+          "plan": {"type": "string", "description": "Plan chosen"},
+          "referrer": {"type": "string", "metadata": {"com.mixpanel": {"hidden": true}}}
+        },
+        "metadata": {"com.mixpanel": {"hidden": false}}
+      }
+    },
+    {
+      "entityType": "event",
+      "name": "Old Event",
+      "schemaJson": {"metadata": {"com.mixpanel": {"hidden": true}}}
+    },
+    {
+      "entityType": "profile",
+      "name": "$user",
+      "schemaJson": {"properties": {"$email": {"type": "string"}}}
+    }
+  ]
+}`
+
+func newLexiconServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"status": "error", "error": "bad credentials"}`)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/projects/123/schemas", "/projects/123/schemas/event":
+			fmt.Fprint(w, lexiconPayload)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestGetLexiconSchema(t *testing.T) {
+	server := newLexiconServer(t)
+	defer server.Close()
+
+	mix := New("product", "", "")
+	mix.Endpoints.App = server.URL
+	mix.ProjectID = "123"
+	mix.ServiceAccountUser = "user"
+	mix.ServiceAccountSecret = "pass"
+
+	schema, err := mix.GetLexiconSchema(context.Background())
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if len(schema.Events) != 2 || len(schema.Profiles) != 1 {
+		t.Fatalf("expected 2 events and 1 profile, got %+v", schema)
+	}
+
+	signup := schema.Events[0]
+	if signup.Name != "Signed Up" || signup.Description != "A user created an account" || signup.Hidden {
+		t.Errorf("bad event: %+v", signup)
+	}
+
+	expected := []SchemaProperty{
+		{Name: "plan", Type: "string", Description: "Plan chosen"},
+		{Name: "referrer", Type: "string", Hidden: true},
+	}
+
+	if len(signup.Properties) != len(expected) {
+		t.Fatalf("expected %d properties, got %+v", len(expected), signup.Properties)
+	}
+
+	for i, prop := range expected {
+		if signup.Properties[i] != prop {
+			t.Errorf("expected %+v, got %+v", prop, signup.Properties[i])
+		}
+	}
+
+	if !schema.Events[1].Hidden {
+		t.Errorf("expected hidden event: %+v", schema.Events[1])
+	}
+}
+
+func TestListSchemaEntitiesAuth(t *testing.T) {
+	server := newLexiconServer(t)
+	defer server.Close()
+
+	mix := New("product", "", "")
+	mix.Endpoints.App = server.URL
+	mix.ProjectID = "123"
+
+	if _, err := mix.ListSchemaEntities(context.Background(), EntityEvent); err == nil {
+		t.Error("expected error without service account credentials")
+	}
+
+	mix.ServiceAccountUser = "user"
+	mix.ServiceAccountSecret = "wrong"
+
+	if _, err := mix.ListSchemaEntities(context.Background(), EntityEvent); err == nil {
+		t.Error("expected error with bad service account credentials")
+	}
+}
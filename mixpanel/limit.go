@@ -0,0 +1,50 @@
+package mixpanel
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrResponseTooLarge is returned when an export's response body is larger
+// than the configured `MaxBytes`.
+var ErrResponseTooLarge = errors.New("response exceeded the maximum allowed size")
+
+// limitedReader passes reads through to `r`, keeping count of how many bytes
+// have been read, and fails with `ErrResponseTooLarge` if there is more than
+// `limit` bytes of data available. A limit of zero means no limit.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+
+	// Counts the bytes read towards `InFlightStats` too, if set.
+	progress *progress
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.limit > 0 {
+		remaining := l.limit - l.read
+
+		// We've hit the limit, so the only question is whether
+		// there's anything left to read.
+		if remaining <= 0 {
+			var probe [1]byte
+			n, err := l.r.Read(probe[:])
+			if n > 0 {
+				return 0, ErrResponseTooLarge
+			}
+
+			return 0, err
+		}
+
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	l.progress.read(n)
+
+	return n, err
+}
@@ -0,0 +1,60 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxBytes(t *testing.T) {
+	line := `{"event": "a", "properties": {"b": "c"}}` + "\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat(line, 100))
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	// Enough for two and a half events.
+	mix.MaxBytes = int64(len(line)*2 + len(line)/2)
+
+	output := make(chan EventData, 100)
+
+	stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil)
+	if err != ErrResponseTooLarge {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+
+	if stats.EventsExported != 2 || len(output) != 2 {
+		t.Errorf("expected 2 events, got %d (%d sent)", stats.EventsExported, len(output))
+	}
+
+	if stats.BytesRead != mix.MaxBytes {
+		t.Errorf("expected %d bytes read, got %d", mix.MaxBytes, stats.BytesRead)
+	}
+}
+
+func TestMaxBytesExact(t *testing.T) {
+	body := `{"event": "a", "properties": {"b": "c"}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.MaxBytes = int64(len(body))
+
+	output := make(chan EventData, 1)
+
+	if stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil); err != nil {
+		t.Errorf("raised error: %v", err)
+	} else if stats.EventsExported != 1 {
+		t.Errorf("expected 1 event, got %d", stats.EventsExported)
+	}
+}
@@ -0,0 +1,78 @@
+package mixpanel
+
+import (
+	"context"
+	"sort"
+)
+
+// What to do with an event that has more than `MaxProperties` properties.
+const (
+	// OversizedTruncate keeps the event, but only with its first
+	// `MaxProperties` properties (by name), always including the ones in
+	// `alwaysKept` and `ProtectedProperties`.
+	OversizedTruncate = iota
+
+	// OversizedDrop leaves the event out of the output entirely, sending it
+	// to `Rejects` instead if that is set.
+	OversizedDrop
+)
+
+// limitProperties enforces `MaxProperties` on a decoded event, truncating
+// its properties if that's the policy. Reports whether the event was over
+// the limit.
+func (m *Mixpanel) limitProperties(ev *rawEvent) bool {
+	if m.MaxProperties <= 0 || len(ev.Properties) <= m.MaxProperties {
+		return false
+	}
+
+	if m.OversizedPolicy == OversizedTruncate {
+		truncateProperties(ev.Properties, m.MaxProperties)
+	}
+
+	return true
+}
+
+// truncateProperties cuts `props` down to `max` properties, keeping the
+// identifying ones first and then the rest in name order, so that the same
+// event is always truncated the same way.
+func truncateProperties(props map[string]interface{}, max int) {
+	keep := make(map[string]bool, max)
+
+	protected := make([]string, 0, len(ProtectedProperties))
+	for key := range ProtectedProperties {
+		protected = append(protected, key)
+	}
+
+	sort.Strings(protected)
+
+	for _, key := range append(append([]string(nil), alwaysKept...), protected...) {
+		if _, ok := props[key]; ok && len(keep) < max {
+			keep[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if len(keep) < max {
+			keep[key] = true
+		} else if !keep[key] {
+			delete(props, key)
+		}
+	}
+}
+
+// reject hands an event dropped from the output to `Rejects`, if set, unless
+// `ctx` is done first.
+func (m *Mixpanel) reject(ctx context.Context, event EventData) error {
+	if m.Rejects == nil {
+		return nil
+	}
+
+	return sendEvent(ctx, m.Rejects, event)
+}
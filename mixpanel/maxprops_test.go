@@ -0,0 +1,75 @@
+package mixpanel
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// oversizedInput is a normal event followed by one with 20 extra properties.
+func oversizedInput() *strings.Reader {
+	var props []string
+	for i := 0; i < 20; i++ {
+		props = append(props, fmt.Sprintf(`"p%02d": %d`, i, i))
+	}
+
+	return strings.NewReader(`{"event": "small", "properties": {"distinct_id": "a", "x": 1}}` + "\n" +
+		`{"event": "big", "properties": {"distinct_id": "b", ` + strings.Join(props, ", ") + `}}`)
+}
+
+func TestMaxPropertiesTruncate(t *testing.T) {
+	mix := New("product", "", "")
+	mix.MaxProperties = 5
+
+	output := make(chan EventData, 2)
+
+	stats, err := mix.TransformEventData(oversizedInput(), output)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 2 || stats.OversizedEvents != 1 {
+		t.Fatalf("expected 2 events with 1 oversized, got %+v", stats)
+	}
+
+	<-output
+	big := <-output
+
+	// distinct_id plus the first 4 by name, then what mixport adds.
+	for _, key := range []string{"distinct_id", "p00", "p01", "p02", "p03", "event", "product", EventIDKey} {
+		if _, ok := big[key]; !ok {
+			t.Errorf("expected %s to be kept: %v", key, big)
+		}
+	}
+
+	if _, ok := big["p04"]; ok || len(big) != 8 {
+		t.Errorf("expected event to be truncated: %v", big)
+	}
+}
+
+func TestMaxPropertiesDrop(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		rejects := make(chan EventData, 1)
+
+		mix := New("product", "", "")
+		mix.MaxProperties = 5
+		mix.OversizedPolicy = OversizedDrop
+		mix.Rejects = rejects
+		mix.DecodeWorkers = workers
+
+		output := make(chan EventData, 2)
+
+		stats, err := mix.TransformEventData(oversizedInput(), output)
+		if err != nil {
+			t.Fatalf("workers=%d: raised error: %v", workers, err)
+		} else if stats.EventsExported != 1 || stats.OversizedEvents != 1 {
+			t.Fatalf("workers=%d: expected 1 event with 1 oversized, got %+v", workers, stats)
+		}
+
+		if event := <-output; event["event"] != "small" {
+			t.Errorf("workers=%d: expected small event, got %v", workers, event)
+		}
+
+		if reject := <-rejects; reject["event"] != "big" || len(reject) != 24 {
+			t.Errorf("workers=%d: expected big event to be rejected whole, got %v", workers, reject)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// What to do with an event that has no `time` property, and so can't be
+// placed in time based partitions.
+const (
+	// MissingTimeKeep exports the event as it is, without a time.
+	MissingTimeKeep = iota
+
+	// MissingTimeDrop leaves the event out of the output.
+	MissingTimeDrop
+
+	// MissingTimeReject leaves the event out of the output, sending it to
+	// `Rejects` instead if that is set.
+	MissingTimeReject
+
+	// MissingTimeExportDate gives the event the start of the day being
+	// exported as its time. Without a day to go by (`ReplayFile` and
+	// `TransformEventData`), the event is kept as it is.
+	MissingTimeExportDate
+)
+
+// exportDay returns the start of the day an export of `from` onwards is for,
+// which events without a time are given under `MissingTimeExportDate`: the
+// start of `window` if there is one, otherwise midnight UTC on `from`'s date.
+func exportDay(from time.Time, window *timeWindow) time.Time {
+	if window != nil {
+		return window.start
+	}
+
+	year, month, day := from.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// fillMissingTime reports whether `ev` is missing its `time`, giving it the
+// filter's day if that's the `MissingTimePolicy`. Error envelopes never have
+// a time, and don't count.
+func (m *Mixpanel) fillMissingTime(ev *rawEvent, filter *eventFilter) bool {
+	if ev.Error != nil {
+		return false
+	} else if t, ok := ev.Properties["time"]; ok && t != nil {
+		return false
+	}
+
+	if m.MissingTimePolicy == MissingTimeExportDate && filter != nil && !filter.day.IsZero() {
+		if ev.Properties == nil {
+			ev.Properties = make(map[string]interface{})
+		}
+
+		ev.Properties["time"] = json.Number(strconv.FormatInt(filter.day.Unix(), 10))
+	}
+
+	return true
+}
@@ -0,0 +1,83 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMissingTimePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"event": "timed", "properties": {"time": 1388577600}}`)
+		fmt.Fprintln(w, `{"event": "untimed", "properties": {"distinct_id": "a"}}`)
+	}))
+	defer server.Close()
+
+	date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		Name     string
+		Policy   int
+		Output   []string
+		Rejected int
+		Time     interface{}
+	}{
+		{"keep", MissingTimeKeep, []string{"timed", "untimed"}, 0, nil},
+		{"drop", MissingTimeDrop, []string{"timed"}, 0, nil},
+		{"reject", MissingTimeReject, []string{"timed"}, 1, nil},
+		{"export date", MissingTimeExportDate, []string{"timed", "untimed"}, 0, "2014-01-01 00:00:00"},
+	}
+
+	for _, c := range cases {
+		for _, workers := range []int{1, 4} {
+			mix := NewWithURL("product", "key", "secret", server.URL)
+			mix.MissingTimePolicy = c.Policy
+			mix.DecodeWorkers = workers
+			mix.PreserveOrder = true
+
+			output := make(chan EventData, 2)
+			rejects := make(chan EventData, 2)
+			mix.Rejects = rejects
+
+			stats, err := mix.ExportDate(context.Background(), date, output, nil)
+			if err != nil {
+				t.Fatalf("%s: raised error: %v", c.Name, err)
+			}
+
+			close(output)
+			close(rejects)
+
+			if stats.MissingTime != 1 {
+				t.Errorf("%s (%d workers): expected 1 event missing time, got %d", c.Name, workers, stats.MissingTime)
+			}
+
+			var names []string
+			for event := range output {
+				names = append(names, event["event"].(string))
+
+				if event["event"] == "untimed" && event[TimestampKey] != c.Time {
+					t.Errorf("%s (%d workers): expected time %v, got %v", c.Name, workers, c.Time, event[TimestampKey])
+				}
+			}
+
+			if fmt.Sprint(names) != fmt.Sprint(c.Output) {
+				t.Errorf("%s (%d workers): expected %v, got %v", c.Name, workers, c.Output, names)
+			}
+
+			rejected := 0
+			for event := range rejects {
+				if event["event"] != "untimed" {
+					t.Errorf("%s: rejected the wrong event %v", c.Name, event)
+				}
+				rejected++
+			}
+
+			if rejected != c.Rejected {
+				t.Errorf("%s (%d workers): expected %d rejected, got %d", c.Name, workers, c.Rejected, rejected)
+			}
+		}
+	}
+}
@@ -1,20 +1,42 @@
 package mixpanel
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
 	"github.com/nu7hatch/gouuid"
+	"golang.org/x/sync/singleflight"
 	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-// The official base URL
-const MixpanelBaseURL = "https://data.mixpanel.com/api/2.0/export"
+// The official base URL of the raw data export API.
+const MixpanelDataURL = "https://data.mixpanel.com/api/2.0"
+
+// The official URL of the raw export endpoint itself.
+const MixpanelBaseURL = MixpanelDataURL + "/" + EndpointExport
+
+// The official base URL of the query (reporting) API, used for things like
+// event counts rather than raw data.
+const MixpanelQueryURL = "https://mixpanel.com/api/2.0"
+
+// The official base URL of the ingestion API, used to send data to Mixpanel
+// rather than to export it.
+const MixpanelIngestURL = "https://api.mixpanel.com"
+
+// The official base URL of the app API, used for project management things
+// like the Lexicon schemas.
+const MixpanelAppURL = "https://mixpanel.com/api/app"
 
 // Key into the EventData map that contains the UUID of this event. Name is
 // chosen to make collisions with actual keys very unlikely.
@@ -24,13 +46,348 @@ const EventIDKey = "$__$$event_id"
 // compatible timestamp of this event.
 const TimestampKey = "$__$$timestamp"
 
+// Endpoints are the base URLs of the APIs Mixpanel serves from different
+// hosts. Each method uses the one for its API, with the endpoint's path
+// appended.
+//
+// - `Data` serves raw exports.
+// - `Query` serves the query (reporting) API, and export jobs.
+// - `Ingestion` is sent data by `Import` and `PeopleSet`.
+// - `App` serves the app API, which authenticates with a service account.
+type Endpoints struct {
+	Data      string
+	Query     string
+	Ingestion string
+	App       string
+}
+
+// DefaultEndpoints returns the official endpoints.
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		Data:      MixpanelDataURL,
+		Query:     MixpanelQueryURL,
+		Ingestion: MixpanelIngestURL,
+		App:       MixpanelAppURL,
+	}
+}
+
+// urls returns every base URL.
+func (e Endpoints) urls() []string {
+	return []string{e.Data, e.Query, e.Ingestion, e.App}
+}
+
 // Mixpanel struct represents a set of credentials used to access the Mixpanel
 // API for a particular product.
+//
+// A single Mixpanel may be used from multiple goroutines at once: every call
+// keeps its own state (including its Stats), and the only state shared
+// between calls is internally synchronized. The exported fields are
+// configuration, and must not be modified while calls are in flight. Note
+// that callbacks such as `OnThrottle` may be called concurrently when the
+// struct is shared.
 type Mixpanel struct {
 	Product string
 	Key     string
 	Secret  string
-	BaseURL string
+
+	// SecretProvider, if set, is asked for the secret instead of using
+	// `Secret`, so that a rotated secret is picked up without a restart.
+	// Its secret is cached for `SecretTTL` (default `DefaultSecretTTL`);
+	// a negative TTL asks it for every request.
+	SecretProvider SecretProvider
+	SecretTTL      time.Duration
+
+	// Endpoints are the base URLs requests are sent to, by API. They
+	// default to `DefaultEndpoints`.
+	Endpoints Endpoints
+
+	// Token is the project token `Import` and `PeopleSet` authenticate
+	// with instead of `Secret`.
+	Token string
+
+	// CompressEndpoints holds the endpoints (such as `EndpointImport`)
+	// whose POST bodies are gzipped, with `Content-Encoding: gzip`, once
+	// they're at least `CompressMinBytes` long (default
+	// `DefaultCompressMinBytes`). Only list endpoints which accept
+	// compressed requests; Mixpanel's ingestion endpoints do.
+	CompressEndpoints map[string]bool
+	CompressMinBytes  int
+
+	// ProjectTimezone is the timezone of the Mixpanel project. Mixpanel
+	// interprets the `from_date` and `to_date` of an export, and buckets
+	// events into days, in this timezone.
+	//
+	// When set, the dates given to `ExportDate` are taken to mean the day
+	// in the date's own location instead; the project days overlapping it
+	// are requested, and the events outside of it are dropped. When nil,
+	// dates are passed through as they are, and so are project days.
+	ProjectTimezone *time.Location
+
+	// Bucket, if set, is the data bucket raw data is exported from, for
+	// accounts which split their data across buckets. It's sent (and
+	// signed) with the raw export and export job requests. Other
+	// endpoints can't be limited to a bucket, so they fail with
+	// `ErrBucketUnsupported` rather than answering for the default one.
+	Bucket string
+
+	// ProjectID is the numeric ID of the project. It's required by the app
+	// API endpoints, and by the other endpoints for projects which have
+	// migrated to service accounts. When set, it's sent (and signed) as
+	// `project_id` with every signed request; requests fail with
+	// `ErrInvalidProjectID` if it isn't numeric.
+	ProjectID string
+
+	// Service account credentials, used by the endpoints which don't
+	// support key and secret authentication.
+	ServiceAccountUser   string
+	ServiceAccountSecret string
+
+	// Headers are added to every request to the hosts of the `Endpoints`,
+	// for things like an API gateway key. Use `WithHeaders` to add or
+	// override headers for a single call. Neither can replace the headers
+	// a request sets itself, such as its `Authorization`, and both are
+	// dropped from redirects to untrusted hosts (see `RedirectHosts`).
+	Headers http.Header
+
+	// RedirectHosts lists extra host names which credentials may follow a
+	// redirect to. The original host and any `mixpanel.com` host are
+	// always trusted.
+	RedirectHosts []string
+
+	// MaxURLLength is the longest GET request URL we are willing to send,
+	// since longer ones (big `where` filters, for example) tend to be
+	// rejected with a confusing 414. Zero uses `DefaultMaxURLLength`. What
+	// happens to longer URLs is decided by `LongURLPolicy`, and
+	// `OnLongURL` (if set) is told about every one.
+	MaxURLLength  int
+	LongURLPolicy int
+	OnLongURL     func(endpoint string, length int)
+
+	// Clock is used for all reads of the current time. Defaults to the
+	// real wall clock when nil.
+	Clock Clock
+
+	// Events, when non-empty, restricts exports to the events with these
+	// names. With `ServerSideFilter` (the default) Mixpanel is asked to
+	// only send those, which saves bandwidth; otherwise every event is
+	// downloaded and the rest are dropped while decoding.
+	Events           []string
+	ServerSideFilter bool
+
+	// Keep, when non-empty, restricts the properties retained on each
+	// event to those listed (in addition to `event`, `product`,
+	// `distinct_id` and `time`, which are always kept). Everything else is
+	// discarded during decoding.
+	Keep []string
+
+	// ThrottleRetries is the number of times a throttled request is retried
+	// before giving up. Zero uses `DefaultThrottleRetries`, and a negative
+	// value disables retrying entirely.
+	ThrottleRetries int
+
+	// Breaker, if set, is consulted before every request, and stops
+	// requests being made at all for a while after repeated failures.
+	// It may be shared between Mixpanel structs.
+	Breaker *CircuitBreaker
+
+	// OnThrottle, if set, is called every time Mixpanel throttles a request,
+	// just before waiting `retryAfter` to retry it. `attempt` counts from 1.
+	OnThrottle func(retryAfter time.Duration, attempt int)
+
+	// OnPeoplePage, if set, is called by `ExportPeople` and
+	// `ExportPeopleResume` after all of the profiles of each page have
+	// been sent, with the session and number of that page. It is progress
+	// to checkpoint for resuming.
+	OnPeoplePage func(sessionID string, page int)
+
+	// AuditLogger, if set, is called with a record of every request just
+	// before it is sent (including retries and redirects), with the
+	// signature, API key and authentication redacted. It may be called
+	// concurrently.
+	AuditLogger func(record AuditRecord)
+
+	// OnEventLag, if set, is called with the lag (age, according to
+	// `Clock`) of every exported event that has a `time` property. See
+	// `LagHistogram` for a ready made consumer. It may be called
+	// concurrently when `DecodeWorkers` is set.
+	OnEventLag func(lag time.Duration)
+
+	// ProductKey and EventKey are the names of the keys the product and
+	// event names are attached to each record under. They default to
+	// `DefaultProductKey` and `DefaultEventKey`. Note that the exporters in
+	// `exports` expect the defaults.
+	ProductKey string
+	EventKey   string
+
+	// IdleTimeout, if positive, aborts an export with `ErrStalled` when
+	// its response body sends nothing for this long, as happens when a
+	// connection hangs mid-stream without being closed. Unlike a deadline
+	// on `ctx`, it doesn't limit how long a steadily streaming export may
+	// take, and time spent waiting on the output channel doesn't count.
+	IdleTimeout time.Duration
+
+	// SinkGracePeriod is how long `ExportDateToSink` keeps writing the
+	// events already exported after its context is cancelled, before
+	// flushing and closing the sink. Defaults to `DefaultSinkGracePeriod`.
+	SinkGracePeriod time.Duration
+
+	// ReadBufferSize is the size of the buffer response bodies are read
+	// through before being decoded, default `DefaultReadBufferSize`. The
+	// larger it is, the fewer (and larger) reads a fast stream takes.
+	ReadBufferSize int
+
+	// MaxBytes aborts an export with `ErrResponseTooLarge` once more than
+	// this many bytes of response body have been read. Zero means no limit.
+	MaxBytes int64
+
+	// MaxProperties, if positive, guards against pathological events by
+	// limiting how many properties an event may have. What happens to
+	// events over the limit is decided by `OversizedPolicy`, and they are
+	// counted in `Stats.OversizedEvents`.
+	MaxProperties   int
+	OversizedPolicy int
+
+	// MissingTimePolicy decides what happens to events without a `time`
+	// property, which can't be partitioned by time: kept as they are
+	// (`MissingTimeKeep`, the default), dropped, sent to `Rejects`, or
+	// given the start of the day being exported. They are counted in
+	// `Stats.MissingTime` whatever the policy.
+	MissingTimePolicy int
+
+	// DuplicateKeyPolicy decides what happens to events with a key given
+	// more than once in the same object, which are otherwise decoded with
+	// the last value of the key (`DuplicateKeysLastWins`, the default).
+	// Checking for them means scanning every event once more.
+	DuplicateKeyPolicy int
+
+	// RawChan, if set, receives the original bytes of every event, exactly
+	// as they were in the response, before any transformation. The raw
+	// bytes of an event are always sent just before the event itself is
+	// sent to the output channel, from the same goroutine, so the two
+	// streams correspond one to one. Both must be read in lockstep (or be
+	// buffered), or the export will block. Events which are dropped aren't
+	// sent to either. The raw bytes of an event split by `Explode` are sent
+	// before each of its rows.
+	RawChan chan<- []byte
+
+	// Tap, if set, is called with the raw bytes (as with `RawChan`) of
+	// every event sent to the output channel, for sampling or live
+	// metrics. It's called from a goroutine of its own, and never holds
+	// up the export: when it falls more than `TapBuffer` events behind,
+	// events are left out of the tap, and counted in `Stats.TapDropped`.
+	// Exports wait for it to see the events it hasn't dropped before
+	// returning.
+	Tap func(event []byte)
+
+	// OverflowPolicy decides what happens when the consumer of the output
+	// channel can't keep up: the export waits for it (`OverflowBlock`, the
+	// default), or, for tailing where fresh events matter more than every
+	// event, up to `OverflowBuffer` (default `DefaultOverflowBuffer`)
+	// events are queued for it and any more are dropped, counted in
+	// `Stats.Dropped`. `RawChan` and `Tap` still see the events dropped.
+	OverflowPolicy int
+	OverflowBuffer int
+
+	// Explode, if set, names an array property; each event is sent once
+	// per element of it, with the element's properties merged into the
+	// event (or the element itself in place of the array, if it isn't an
+	// object). Events without a non-empty array there are sent unchanged.
+	// Each row is then treated as an event of its own (hashed, stripped
+	// of implicit properties and limited to `MaxProperties`), and counts
+	// separately in `Stats.EventsExported`. Element properties can't
+	// replace the event's identifying ones, such as `time` and
+	// `distinct_id`; they're kept under a `CollisionPrefix`ed name instead.
+	// Not applied with `ImportShape`.
+	Explode string
+
+	// Rejects, if set, receives the events which were dropped from the
+	// output (see `OversizedDrop`). It must be drained while exporting.
+	Rejects chan<- EventData
+
+	// Transactions, if set, receives a record per transaction of every
+	// People profile exported, from its `$transactions` (see
+	// `ProfileTransactions`). It must be drained while exporting.
+	Transactions chan<- EventData
+
+	// IncludeImplicit controls whether the properties Mixpanel attaches to
+	// events by itself (`$city`, `mp_country_code`, ...) are included in
+	// the output. Defaults to true. When false, every `$` and `mp_`
+	// prefixed property is dropped, except for the identifiers in
+	// `ProtectedProperties`.
+	IncludeImplicit bool
+
+	// ResolveIdentity attaches a canonical identity to each event under
+	// `ResolvedIDKey`, so that joins stay consistent across Mixpanel's ID
+	// merge transition: the value of the first of `IdentityPrecedence`
+	// (default `DefaultIdentityPrecedence`) the event has. It's resolved
+	// before implicit properties are dropped, so `$user_id` and
+	// `$device_id` count even without `IncludeImplicit`. Events with none
+	// of them get no `resolved_id`. To hash it along with the properties
+	// it comes from, add it to `HashProperties`.
+	ResolveIdentity    bool
+	IdentityPrecedence []string
+
+	// Checksum and ChecksumByEvent have `StreamDate` and `ExportDateToSink`
+	// compute a SHA-256 of the events they write, overall and per event
+	// type, returned in `Stats.Checksum` and `Stats.EventChecksums`. They
+	// can be kept alongside the output to check later that it wasn't
+	// truncated or corrupted.
+	Checksum        bool
+	ChecksumByEvent bool
+
+	// ImportShape emits events the way `Import` sends them (`{"event":
+	// name, "properties": {..., "token": Token}}`) rather than flattened,
+	// so that an export can be replayed into a project as is. None of the
+	// keys mixport attaches (`EventIDKey`, `TimestampKey`, product and
+	// event) are added; `time`, `distinct_id` and the other properties are
+	// kept as exported. Requires `Token`.
+	ImportShape bool
+
+	// DecodeWorkers, if greater than one, splits newline delimited
+	// responses into chunks of lines which are decoded and transformed by
+	// this many goroutines. Events are sent in their original order only
+	// if `PreserveOrder` is also set.
+	DecodeWorkers int
+	PreserveOrder bool
+
+	// HashProperties maps property names to a salt. The values of these
+	// properties are replaced with the hex encoded SHA-256 of the salt
+	// followed by the value, so they can still be used as join keys
+	// without exposing the original value.
+	HashProperties map[string]string
+
+	// DedupRequests makes concurrent, identical `ExportDate` calls share a
+	// single HTTP download rather than each pulling the same data.
+	DedupRequests bool
+
+	// SplitOnFailure has an export which fails before sending any events,
+	// as exports of very busy days can, retried as `SplitParts` (default
+	// `DefaultSplitParts`) requests, each for a slice of the day selected
+	// with a `where` on the time, plus one for events without a time.
+	// The slices are sent one after the other, so a failure part way
+	// through leaves the earlier ones sent. Failures splitting can't help
+	// (bad credentials or arguments, throttling, cancellation) aren't
+	// retried.
+	SplitOnFailure bool
+	SplitParts     int
+
+	// JobPollInterval and JobMaxPollInterval bound how often an export job
+	// is polled for completion. Zero values use the package defaults.
+	JobPollInterval    time.Duration
+	JobMaxPollInterval time.Duration
+
+	// Tracks in flight downloads when `DedupRequests` is set.
+	inflight singleflight.Group
+
+	// The progress of the exports running, for `InFlightStats`.
+	progressMu sync.Mutex
+	inProgress map[*progress]bool
+
+	// The secret last fetched from `SecretProvider`, and when to fetch it
+	// again.
+	secretMu      sync.Mutex
+	cachedSecret  string
+	secretExpires time.Time
 }
 
 // EventData is a representation of each individual JSON record spit out of the
@@ -38,60 +395,110 @@ type Mixpanel struct {
 type EventData map[string]interface{}
 
 // New creates a Mixpanel object with the given API credentials and uses the
-// official API URL.
+// official API URLs.
 func New(product, key, secret string) *Mixpanel {
-	return NewWithURL(product, key, secret, MixpanelBaseURL)
-}
-
-// NewWithURL creates a Mixpanel object with the given API credentials and a
-// custom Mixpanel API URL.
-//
-// I doubt this will ever be useful but there you go.
-func NewWithURL(product, key, secret, baseURL string) *Mixpanel {
 	m := new(Mixpanel)
 	m.Product = product
 	m.Key = key
 	m.Secret = secret
-	m.BaseURL = baseURL
+	m.Endpoints = DefaultEndpoints()
+	m.IncludeImplicit = true
+	m.ServerSideFilter = true
+	return m
+}
+
+// NewWithURL creates a Mixpanel object with the given API credentials which
+// sends every request under `baseURL`, such as a proxy in front of all of
+// Mixpanel's APIs, by setting all of the `Endpoints` to it. A trailing
+// `/export`, from when this only set the raw export URL, is dropped.
+//
+// Set `Endpoints` instead to put the APIs on different hosts.
+func NewWithURL(product, key, secret, baseURL string) *Mixpanel {
+	prefix := strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/"+EndpointExport)
+
+	m := New(product, key, secret)
+	m.Endpoints = Endpoints{Data: prefix, Query: prefix, Ingestion: prefix, App: prefix}
 	return m
 }
 
+// WithSecret returns a copy of the Mixpanel object using `secret` as its API
+// secret, leaving the original untouched. When rotating secrets, the copy
+// can be checked (with `SelfTest`, say) before being adopted, without
+// changing the secret of a Mixpanel object that may be in use.
+//
+// Everything configured is copied as it is, so the copy shares the
+// original's maps, slices, channels, callbacks and `Breaker`, but not its
+// `SecretProvider`, which `secret` replaces. Nothing about
+// downloads in progress is: the copy has its own `DedupRequests` and
+// `InFlightStats` bookkeeping.
+func (m *Mixpanel) WithSecret(secret string) *Mixpanel {
+	c := new(Mixpanel)
+
+	// Copying the struct as a whole would copy its locks too.
+	src, dst := reflect.ValueOf(m).Elem(), reflect.ValueOf(c).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if field := dst.Field(i); field.CanSet() {
+			field.Set(src.Field(i))
+		}
+	}
+
+	c.Secret = secret
+	c.SecretProvider = nil
+	return c
+}
+
 // Add the cryptographic signature that Mixpanel API requests require.
 //
 // Algorithm:
-// - join key=value pairs
-// - sort the pairs alphabetically
-// - appending a secret
+// - sort the parameters alphabetically by key (any existing `sig` excluded)
+// - join key=value pairs, with no separator
+// - append the secret
 // - take MD5 hex digest.
-func (m *Mixpanel) addSignature(args *url.Values) {
+func (m *Mixpanel) addSignature(args *url.Values, secret string) {
 	hash := md5.New()
 
-	var params []string
-	for k, vs := range *args {
-		for _, v := range vs {
-			params = append(params, fmt.Sprintf("%s=%s", k, v))
+	var keys []string
+	for k := range *args {
+		if k != "sig" {
+			keys = append(keys, k)
 		}
 	}
 
-	sort.StringSlice(params).Sort()
+	// Sort on the keys themselves rather than on the joined pairs, which
+	// differ when a key contains a character sorting before '='.
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range (*args)[k] {
+			io.WriteString(hash, k+"="+v)
+		}
+	}
 
-	io.WriteString(hash, strings.Join(params, "")+m.Secret)
+	io.WriteString(hash, secret)
 	args.Set("sig", fmt.Sprintf("%x", hash.Sum(nil)))
 }
 
 // Generate the initial, base arguments that should be common to all Mixpanel
 // API requests being created here.
-func (m *Mixpanel) makeArgs(date time.Time) url.Values {
+func (m *Mixpanel) baseArgs() url.Values {
 	args := url.Values{}
 
 	args.Set("format", "json")
 	args.Set("api_key", m.Key)
-	args.Set("expire", fmt.Sprintf("%d", time.Now().Unix()+10000))
+	args.Set("expire", fmt.Sprintf("%d", m.now().Unix()+10000))
 
-	day := date.Format("2006-01-02")
+	if m.ProjectID != "" {
+		args.Set("project_id", m.ProjectID)
+	}
 
-	args.Set("from_date", day)
-	args.Set("to_date", day)
+	return args
+}
+
+// Generate the base arguments for a request covering a single day.
+func (m *Mixpanel) makeArgs(date time.Time) url.Values {
+	args := m.baseArgs()
+
+	setDateRange(args, EndpointExport, date, date)
 
 	return args
 }
@@ -100,33 +507,259 @@ func (m *Mixpanel) makeArgs(date time.Time) url.Values {
 // transformed JSON blobs as byte strings over the send-only channel passed
 // to the function.
 //
-// Returns the number of records that have been processed during the run and
-// possibly an error.
+// Returns statistics about the records that have been processed during the
+// run and possibly an error.
 //
 // The optional `moreArgs` parameter can be given to add additional URL
-// parameters to the API request.
-func (m *Mixpanel) ExportDate(date time.Time, output chan<- EventData, moreArgs *url.Values) (int, error) {
-	args := m.makeArgs(date)
-
-	if moreArgs != nil {
-		for k, vs := range *moreArgs {
-			for _, v := range vs {
-				args.Add(k, v)
-			}
+// parameters to the API request. `ExportOptions` (see
+// `ExportDateWithOptions`) is less error prone for the common ones.
+//
+// If `DedupRequests` is set, concurrent calls for the same day and arguments
+// share a single download (see `exportShared`).
+//
+// Cancelling `ctx` ends the export even while it's waiting on `output` (or
+// `RawChan` and `Rejects`), so a consumer that gives up on the channel should
+// cancel it rather than just stop reading.
+func (m *Mixpanel) ExportDate(ctx context.Context, date time.Time, output chan<- EventData, moreArgs *url.Values) (Stats, error) {
+	// Sharing a download with exports filtering it differently would give
+	// the wrong events.
+	if m.DedupRequests && filterFrom(ctx) == nil {
+		return m.exportShared(ctx, date, output, moreArgs)
+	}
+
+	return m.exportDate(ctx, date, output, moreArgs)
+}
+
+// exportDate does the actual work of `ExportDate`.
+func (m *Mixpanel) exportDate(ctx context.Context, date time.Time, output chan<- EventData, moreArgs *url.Values) (Stats, error) {
+	if m.ProjectTimezone != nil {
+		return m.exportLocalDate(ctx, date, output, moreArgs)
+	}
+
+	return m.exportRange(ctx, date, date, output, moreArgs)
+}
+
+// exportLocalDate is the `ProjectTimezone` flavor of `exportDate`.
+func (m *Mixpanel) exportLocalDate(ctx context.Context, date time.Time, output chan<- EventData, moreArgs *url.Values) (Stats, error) {
+	from, to, window := m.projectWindow(date)
+
+	return m.exportWindow(ctx, from, to, window, output, moreArgs)
+}
+
+// exportRange downloads the project days `from` through `to` (inclusive).
+func (m *Mixpanel) exportRange(ctx context.Context, from, to time.Time, output chan<- EventData, moreArgs *url.Values) (Stats, error) {
+	return m.exportWindow(ctx, from, to, nil, output, moreArgs)
+}
+
+// exportWindow is `exportRange`, dropping events outside of `window` (if
+// non-nil) while decoding, and falling back to `SplitOnFailure` if need be.
+func (m *Mixpanel) exportWindow(ctx context.Context, from, to time.Time, window *timeWindow, output chan<- EventData, moreArgs *url.Values) (Stats, error) {
+	stats, err := m.exportOnce(ctx, from, to, window, output, moreArgs)
+	if err == nil || !m.SplitOnFailure || stats.EventsExported > 0 || !splittable(ctx, err) {
+		return stats, err
+	}
+
+	return m.exportSplit(ctx, from, to, window, output, moreArgs, stats)
+}
+
+// exportOnce is `exportWindow` as a single request.
+func (m *Mixpanel) exportOnce(ctx context.Context, from, to time.Time, window *timeWindow, output chan<- EventData, moreArgs *url.Values) (Stats, error) {
+	req, err := m.exportRequest(ctx, from, to, moreArgs)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resp, err := m.do(ctx, req)
+
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: download failed: %w", m.Product, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Stats{}, fmt.Errorf("%s: %w", m.Product, responseError(resp))
+	}
+
+	var input io.Reader = resp.Body
+	if m.IdleTimeout > 0 {
+		input = newIdleReader(resp.Body, m.IdleTimeout, cancel)
+	}
+
+	ctx, done := m.startProgress(ctx)
+	defer done()
+
+	body := &limitedReader{r: input, limit: m.MaxBytes, progress: progressFrom(ctx)}
+
+	stats, err := m.transform(ctx, body, output, window, exportDay(from, window))
+	stats.BytesRead = body.read
+
+	return stats, err
+}
+
+// exportRequest builds the signed raw export request for the project days
+// `from` through `to` (inclusive).
+func (m *Mixpanel) exportRequest(ctx context.Context, from, to time.Time, moreArgs *url.Values) (*http.Request, error) {
+	args := m.baseArgs()
+	setDateRange(args, EndpointExport, from, to)
+	m.setEventFilter(args, moreArgs)
+
+	mergeArgs(args, moreArgs, EndpointExport)
+
+	req, err := m.signedRequest(ctx, "GET", fmt.Sprintf("%s/%s", m.Endpoints.Data, EndpointExport), EndpointExport, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// We can handle either newline delimited or plain JSON, but prefer the
+	// former.
+	req.Header.Set("Accept", "application/x-ndjson, application/json;q=0.9")
+
+	return req, nil
+}
+
+// utf8BOM is the byte order mark some proxies put in front of responses,
+// which the JSON decoder would choke on.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// startsWithArray skips any leading whitespace and byte order marks in `r`
+// and reports whether the first thing after them is the start of a JSON
+// array.
+func startsWithArray(r *bufio.Reader) (bool, error) {
+	for {
+		if prefix, err := r.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+			r.Discard(len(utf8BOM))
+			continue
 		}
+
+		c, err := r.ReadByte()
+		if err != nil {
+			return false, err
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+
+		return c == '[', r.UnreadByte()
+	}
+}
+
+// query performs a signed GET request against one of the query API endpoints
+// and decodes the JSON response into `v`.
+func (m *Mixpanel) query(ctx context.Context, endpoint string, args url.Values, v interface{}) error {
+	return m.request(ctx, "GET", endpoint, args, v)
+}
+
+// request performs a signed request against one of the query API endpoints
+// and decodes the JSON response into `v`.
+func (m *Mixpanel) request(ctx context.Context, method, endpoint string, args url.Values, v interface{}) error {
+	req, err := m.newRequest(ctx, method, endpoint, args)
+	if err != nil {
+		return err
+	}
+
+	return m.send(ctx, req, endpoint, v)
+}
+
+// newRequest builds a signed request against one of the query API endpoints.
+func (m *Mixpanel) newRequest(ctx context.Context, method, endpoint string, args url.Values) (*http.Request, error) {
+	return m.signedRequest(ctx, method, fmt.Sprintf("%s/%s", m.Endpoints.Query, endpoint), endpoint, args)
+}
+
+// signedRequest builds a signed request to `base`, for `endpoint`. For POST
+// requests the arguments are sent form encoded in the body rather than in the
+// URL. GET requests with overly long URLs are handled according to
+// `LongURLPolicy`.
+func (m *Mixpanel) signedRequest(ctx context.Context, method, base, endpoint string, args url.Values) (*http.Request, error) {
+	// Signing with an empty secret would give a valid looking signature
+	// that Mixpanel always rejects.
+	secret, err := m.apiSecret(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.ProjectID != "" {
+		if _, err := strconv.ParseUint(m.ProjectID, 10, 64); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w: %q", m.Product, endpoint, ErrInvalidProjectID, m.ProjectID)
+		}
+	}
+
+	if err := m.setBucket(endpoint, args); err != nil {
+		return nil, err
+	}
+
+	m.addSignature(&args, secret)
+
+	encoded := args.Encode()
+
+	if method == "GET" {
+		if method, err = m.checkURLLength(endpoint, len(base)+1+len(encoded)); err != nil {
+			return nil, err
+		}
+	}
+
+	var req *http.Request
+
+	if method == "POST" {
+		req, err = m.newFormPost(base, endpoint, encoded)
+	} else {
+		req, err = http.NewRequest(method, fmt.Sprintf("%s?%s", base, encoded), nil)
 	}
 
-	m.addSignature(&args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: building request failed: %w", m.Product, redactError(err))
+	}
 
-	resp, err := http.Get(fmt.Sprintf("%s?%s", m.BaseURL, args.Encode()))
+	return req, nil
+}
+
+// appQuery performs a GET request against one of the app API endpoints, which
+// authenticate with a service account rather than a signature, and decodes
+// the JSON response into `v`.
+func (m *Mixpanel) appQuery(ctx context.Context, endpoint string, args url.Values, v interface{}) error {
+	if m.ServiceAccountUser == "" || m.ServiceAccountSecret == "" {
+		return fmt.Errorf("%s: %s requires service account credentials", m.Product, endpoint)
+	}
 
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s?%s", m.Endpoints.App, endpoint, args.Encode()), nil)
 	if err != nil {
-		return 0, fmt.Errorf("%s: download failed: %s", m.Product, err)
+		return fmt.Errorf("%s: building request failed: %w", m.Product, redactError(err))
+	}
+
+	req.SetBasicAuth(m.ServiceAccountUser, m.ServiceAccountSecret)
+
+	return m.send(ctx, req, endpoint, v)
+}
+
+// send performs the given request and decodes the JSON response into `v`,
+// turning Mixpanel's error responses into errors.
+func (m *Mixpanel) send(ctx context.Context, req *http.Request, endpoint string, v interface{}) error {
+	resp, err := m.do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%s: %s request failed: %w", m.Product, endpoint, err)
 	}
 
 	defer resp.Body.Close()
 
-	return m.TransformEventData(resp.Body, output)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s: %w", m.Product, endpoint, responseError(resp))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+
+	// Don't default all numeric values to float, same as for events.
+	decoder.UseNumber()
+
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%s: %s: Failed to parse JSON: %w", m.Product, endpoint, err)
+	}
+
+	return nil
 }
 
 // TransformEventData reads JSON objects line by line from `input`, performs a
@@ -135,55 +768,261 @@ func (m *Mixpanel) ExportDate(date time.Time, output chan<- EventData, moreArgs
 // The transformation effectively folds the properties map into the top level
 // and attaches product information.
 //
-// Returns the number of records that have been processed during the run and
-// possibly an error.
+// Returns statistics about the records that have been processed during the
+// run and possibly an error.
+//
+// Records are normally separated by newlines, but a single top level JSON
+// array of records is accepted as well.
 //
 // Input : `{"event": "...", "properties": {"k": "v"}}`
 // Output: `{"event": "...", "product: "...", "k": "v", ...}`
-func (m *Mixpanel) TransformEventData(input io.Reader, output chan<- EventData) (int, error) {
-	decoder := json.NewDecoder(input)
+//
+// The `product` and `event` key names can be changed with `ProductKey` and
+// `EventKey`. If an event already has a property with one of these names,
+// the original value is kept under `CollisionPrefix` + name.
+func (m *Mixpanel) TransformEventData(input io.Reader, output chan<- EventData) (Stats, error) {
+	return m.transform(context.Background(), input, output, nil, time.Time{})
+}
+
+// DefaultReadBufferSize is the default size of the buffer response bodies are
+// read through, the bufio default. Larger buffers make for far fewer reads
+// (see `BenchmarkReadBufferSize*`), but since decoding dominates they don't
+// make exports any faster.
+const DefaultReadBufferSize = 4096
+
+// readBufferSize returns the size of the buffer to read responses through.
+func (m *Mixpanel) readBufferSize() int {
+	if m.ReadBufferSize <= 0 {
+		return DefaultReadBufferSize
+	}
+
+	return m.ReadBufferSize
+}
+
+// transform does the work of `TransformEventData`, also dropping events
+// outside of `window` (if non-nil). `day`, if not zero, is the start of the
+// day being exported, for `MissingTimeExportDate`. It gives up with `ctx`'s
+// error if that is done while waiting to send.
+func (m *Mixpanel) transform(ctx context.Context, input io.Reader, output chan<- EventData, window *timeWindow, day time.Time) (Stats, error) {
+	// These are durations rather than readings of the current time, so
+	// they don't go by `Clock`.
+	start := time.Now()
+	timed := &timedReader{r: input}
+
+	tp := m.startTap()
+	st, staged := m.startStage(ctx, output)
+
+	stats, err := m.transformTapped(ctx, timed, staged, window, day, tp)
+	stats.setTimings(time.Since(start), timed.wait)
+	stats.TapDropped = tp.stop()
+	st.stop(&stats)
+
+	return stats, err
+}
+
+// transformTapped is `transform`, passing the events sent on to `tp`.
+func (m *Mixpanel) transformTapped(ctx context.Context, input io.Reader, output chan<- EventData, window *timeWindow, day time.Time, tp *tap) (Stats, error) {
+	filter := m.clientFilter(ctx)
+	if window != nil {
+		filter = filter.withWindow(window)
+	}
+
+	if m.MissingTimePolicy == MissingTimeExportDate && !day.IsZero() {
+		filter = filter.withDay(day)
+	}
+
+	// Keep track of the records we've processed.
+	var stats Stats
+	prog := progressFrom(ctx)
+
+	buffered := bufio.NewReaderSize(input, m.readBufferSize())
+
+	isArray, err := startsWithArray(buffered)
+	if isAbort(err) {
+		return stats, err
+	} else if err != nil && err != io.EOF {
+		return stats, fmt.Errorf("%s: Failed to read response: %w", m.Product, err)
+	}
+
+	// Newline delimited records are independent of each other, so they can
+	// be decoded in parallel.
+	if m.DecodeWorkers > 1 && !isArray {
+		return m.transformConcurrent(ctx, buffered, output, filter, tp)
+	}
+
+	decoder := json.NewDecoder(buffered)
 
 	// Don't default all numeric values to float
 	decoder.UseNumber()
 
-	// Keep track of the number of records we've processed.
-	numLines := 0
+	// Step inside the array so that each element can be decoded just as
+	// if it were part of a stream.
+	if isArray {
+		if _, err := decoder.Token(); isAbort(err) {
+			return stats, err
+		} else if err != nil {
+			return stats, fmt.Errorf("%s: Failed to parse JSON: %w", m.Product, err)
+		}
+	}
+
+	keep := m.keepSet()
 
-	for ; ; numLines++ {
-		var ev struct {
-			Error      *string
-			Event      string
-			Properties map[string]interface{}
+	for {
+		var ev rawEvent
+
+		if isArray && !decoder.More() {
+			// Consume the closing bracket to make sure the array
+			// wasn't truncated.
+			if _, err := decoder.Token(); isAbort(err) {
+				return stats, err
+			} else if err != nil {
+				return stats, fmt.Errorf("%s: Failed to parse JSON: %w", m.Product, err)
+			}
+
+			break
 		}
 
-		if err := decoder.Decode(&ev); err == io.EOF {
+		raw, err := m.decodeNext(decoder, keep, &ev)
+		if err == io.EOF {
 			break
+		} else if isAbort(err) {
+			return stats, err
 		} else if err != nil {
-			return numLines, fmt.Errorf("%s: Failed to parse JSON: %s", m.Product, err)
-		} else if ev.Error != nil {
-			return numLines, fmt.Errorf("%s: API error: %s", m.Product, *ev.Error)
+			return stats, fmt.Errorf("%s: Failed to parse JSON: %w", m.Product, err)
 		}
 
-		if id, err := uuid.NewV4(); err == nil {
-			ev.Properties[EventIDKey] = id.String()
-		} else {
-			return numLines, fmt.Errorf("%s: generating UUID failed: %s", m.Product, err)
+		if filter.drops(&ev) {
+			continue
+		}
+
+		missingTime := m.fillMissingTime(&ev, filter)
+		if missingTime {
+			stats.MissingTime++
+			prog.missTime(1)
+
+			if m.MissingTimePolicy == MissingTimeDrop {
+				continue
+			}
+		}
+
+		for _, ev := range m.explode(ev) {
+			oversized := m.limitProperties(&ev)
+
+			event, err := m.transformEvent(&ev)
+			if err != nil {
+				return stats, err
+			}
+
+			if reason := m.duplicateReason(raw); reason != "" {
+				event[RejectReasonKey] = reason
+
+				if err := m.reject(ctx, event); err != nil {
+					return stats, err
+				}
+
+				continue
+			}
+
+			if missingTime && m.MissingTimePolicy == MissingTimeReject {
+				if err := m.reject(ctx, event); err != nil {
+					return stats, err
+				}
+
+				continue
+			}
+
+			if oversized {
+				stats.OversizedEvents++
+				prog.oversize(1)
+
+				if m.OversizedPolicy == OversizedDrop {
+					if err := m.reject(ctx, event); err != nil {
+						return stats, err
+					}
+
+					continue
+				}
+			}
+
+			sending := time.Now()
+
+			if m.RawChan != nil {
+				if err := sendRaw(ctx, m.RawChan, raw); err != nil {
+					return stats, err
+				}
+			}
+
+			if err := sendEvent(ctx, output, event); err != nil {
+				return stats, err
+			}
+
+			stats.outputWait += time.Since(sending)
+
+			tp.send(raw)
+
+			stats.EventsExported++
+			prog.sent()
 		}
+	}
+
+	return stats, nil
+}
+
+// transformEvent performs the transformation described by
+// `TransformEventData` on a single decoded record.
+func (m *Mixpanel) transformEvent(ev *rawEvent) (EventData, error) {
+	if ev.Error != nil {
+		return nil, fmt.Errorf("%s: %w", m.Product, &APIError{Message: *ev.Error})
+	}
 
-		if prop, ok := ev.Properties["time"].(json.Number); ok {
-			if uts, err := prop.Int64(); err == nil {
-				tstamp := time.Unix(uts, 0).UTC()
+	if ev.Properties == nil {
+		ev.Properties = make(map[string]interface{})
+	}
+
+	var resolvedID interface{}
+	if m.ResolveIdentity && !m.ImportShape {
+		resolvedID = m.resolveIdentity(ev.Properties)
+	}
+
+	if !m.IncludeImplicit {
+		stripImplicit(ev.Properties)
+	}
+
+	if prop, ok := ev.Properties["time"].(json.Number); ok {
+		if uts, err := prop.Int64(); err == nil {
+			tstamp := time.Unix(uts, 0).UTC()
+
+			if !m.ImportShape {
 				ev.Properties[TimestampKey] = tstamp.Format("2006-01-02 15:04:05")
-			} else {
-				return numLines, fmt.Errorf("%s: converting Timestamp failed: %s", m.Product, err)
 			}
+
+			if m.OnEventLag != nil {
+				m.OnEventLag(m.now().Sub(tstamp))
+			}
+		} else {
+			return nil, fmt.Errorf("%s: converting Timestamp failed: %w", m.Product, err)
 		}
+	}
+
+	if m.ImportShape {
+		m.hashProperties(ev.Properties)
+		return m.importShape(ev)
+	}
+
+	if id, err := uuid.NewV4(); err == nil {
+		ev.Properties[EventIDKey] = id.String()
+	} else {
+		return nil, fmt.Errorf("%s: generating UUID failed: %w", m.Product, err)
+	}
 
-		ev.Properties["product"] = m.Product
-		ev.Properties["event"] = ev.Event
+	stamp(ev.Properties, m.productKey(), m.Product)
+	stamp(ev.Properties, m.eventKey(), ev.Event)
 
-		output <- ev.Properties
+	if resolvedID != nil {
+		stamp(ev.Properties, ResolvedIDKey, resolvedID)
 	}
 
-	return numLines, nil
+	m.hashProperties(ev.Properties)
+
+	return ev.Properties, nil
 }
@@ -1,14 +1,44 @@
 package mixpanel
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 func TestAddSignature(t *testing.T) {
-	// TODO: write me
+	mix := New("product", "key", "secret")
+
+	args := url.Values{}
+	args.Set("format", "json")
+	args.Set("api_key", "key")
+	args.Set("expire", "1388534400")
+	args.Set("a-b", "2")
+	args.Set("a", "1")
+
+	// md5("a=1" + "a-b=2" + "api_key=key" + "expire=1388534400" + "format=json" + "secret")
+	expected := "c0828ef246a0cade70d4589443446af2"
+
+	mix.addSignature(&args, mix.Secret)
+	if sig := args.Get("sig"); sig != expected {
+		t.Errorf("expected sig %s, got %s", expected, sig)
+	}
+
+	// Re-signing must ignore the existing signature.
+	mix.addSignature(&args, mix.Secret)
+	if sig := args.Get("sig"); sig != expected {
+		t.Errorf("expected re-signed sig %s, got %s", expected, sig)
+	}
 }
 
 func TestMakeArgs(t *testing.T) {
@@ -40,10 +70,10 @@ func TestTransformEventData(t *testing.T) {
 	output := make(chan EventData)
 
 	go func() {
-		if num, err := mix.TransformEventData(input, output); err != nil {
+		if stats, err := mix.TransformEventData(input, output); err != nil {
 			t.Errorf("raised error: %v", err)
-		} else if num != 3 {
-			t.Errorf("expected to see 3 records, saw %d", num)
+		} else if stats.EventsExported != 3 {
+			t.Errorf("expected to see 3 records, saw %d", stats.EventsExported)
 		}
 	}()
 
@@ -76,12 +106,12 @@ func TestTransformEventDataApiError(t *testing.T) {
 
 	output := make(chan EventData)
 
-	if num, err := mix.TransformEventData(input, output); err == nil {
+	if stats, err := mix.TransformEventData(input, output); err == nil {
 		t.Error("Expected error on bad json")
 	} else if err.Error() != "product: API error: some api error" {
 		t.Errorf("Bad error string: '%s'", err.Error())
-	} else if num != 0 {
-		t.Errorf("Expected 0 records, got %d", num)
+	} else if stats.EventsExported != 0 {
+		t.Errorf("Expected 0 records, got %d", stats.EventsExported)
 	}
 }
 
@@ -92,10 +122,10 @@ func TestTransformEventDataBadJson(t *testing.T) {
 
 	output := make(chan EventData, 1)
 
-	if num, err := mix.TransformEventData(input, output); err == nil {
+	if stats, err := mix.TransformEventData(input, output); err == nil {
 		t.Error("Expected error on bad json")
-	} else if num != 1 {
-		t.Errorf("Expected 1 record, got %d", num)
+	} else if stats.EventsExported != 1 {
+		t.Errorf("Expected 1 record, got %d", stats.EventsExported)
 	}
 
 	event := <-output
@@ -120,10 +150,10 @@ func TestExtractUnixTimestamp(t *testing.T) {
 	input := strings.NewReader(`{"event": "a", "properties": {"time": 1095379200}}`)
 	output := make(chan EventData, 1)
 
-	if num, err := mix.TransformEventData(input, output); err != nil {
+	if stats, err := mix.TransformEventData(input, output); err != nil {
 		t.Error("Got error on valid json: ", err)
-	} else if num != 1 {
-		t.Errorf("Expected 1 record, got %d", num)
+	} else if stats.EventsExported != 1 {
+		t.Errorf("Expected 1 record, got %d", stats.EventsExported)
 	}
 
 	event := <-output
@@ -136,6 +166,164 @@ func TestExportDate(t *testing.T) {
 	// TODO: write me
 }
 
+func TestProjectIDSigned(t *testing.T) {
+	mix := New("product", "key", "secret")
+	date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	signedQuery := func() url.Values {
+		u, err := mix.ExportURL(context.Background(), date, nil)
+		if err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		parsed, _ := url.Parse(u)
+		return parsed.Query()
+	}
+
+	if query := signedQuery(); query.Get("project_id") != "" {
+		t.Errorf("expected no project_id when unset, got %v", query)
+	}
+
+	mix.ProjectID = "12345"
+	query := signedQuery()
+
+	if query.Get("project_id") != "12345" {
+		t.Fatalf("expected project_id=12345, got %v", query)
+	}
+
+	// Re-signing gives the same signature only if project_id was signed.
+	sig := query.Get("sig")
+	mix.addSignature(&query, mix.Secret)
+
+	if query.Get("sig") != sig {
+		t.Error("project_id wasn't included in the signature")
+	}
+
+	query.Del("project_id")
+	mix.addSignature(&query, mix.Secret)
+
+	if query.Get("sig") == sig {
+		t.Error("signature doesn't depend on project_id")
+	}
+
+	mix.ProjectID = "my-project"
+	if _, err := mix.ExportURL(context.Background(), date, nil); !errors.Is(err, ErrInvalidProjectID) {
+		t.Errorf("expected ErrInvalidProjectID, got %v", err)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	hits := make(map[string][]string)
+
+	// One server per API, each recording the paths it was asked for.
+	var mu sync.Mutex
+	serve := func(api string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[api] = append(hits[api], r.URL.Path)
+			mu.Unlock()
+
+			switch api {
+			case "query":
+				fmt.Fprint(w, `{"results": {}}`)
+			case "ingestion":
+				fmt.Fprint(w, `{"status": 1}`)
+			case "app":
+				fmt.Fprint(w, `{"results": []}`)
+			}
+		}))
+	}
+
+	servers := make(map[string]*httptest.Server)
+	for _, api := range []string{"data", "query", "ingestion", "app"} {
+		servers[api] = serve(api)
+		defer servers[api].Close()
+	}
+
+	mix := New("product", "key", "secret")
+	mix.Token = "token"
+	mix.ProjectID = "1"
+	mix.ServiceAccountUser = "user"
+	mix.ServiceAccountSecret = "pass"
+	mix.Endpoints = Endpoints{
+		Data:      servers["data"].URL,
+		Query:     servers["query"].URL,
+		Ingestion: servers["ingestion"].URL,
+		App:       servers["app"].URL,
+	}
+
+	ctx := context.Background()
+	date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := mix.ExportDate(ctx, date, make(chan EventData, 1), nil); err != nil {
+		t.Errorf("ExportDate raised error: %v", err)
+	}
+
+	if _, err := mix.SegmentationAggregate(ctx, "e", "x", AggregateSum, date, date); err != nil {
+		t.Errorf("SegmentationAggregate raised error: %v", err)
+	}
+
+	if err := mix.Import(ctx, []ImportEvent{{Event: "e"}}); err != nil {
+		t.Errorf("Import raised error: %v", err)
+	}
+
+	if _, err := mix.ListSchemaEntities(ctx, "event"); err != nil {
+		t.Errorf("ListSchemaEntities raised error: %v", err)
+	}
+
+	expected := map[string]string{"data": "/export", "query": "/segmentation/sum", "ingestion": "/import", "app": "/projects/1/schemas/event"}
+
+	for api, path := range expected {
+		if len(hits[api]) == 0 || hits[api][0] != path {
+			t.Errorf("expected %s to be asked for %s, got %v", api, path, hits[api])
+		}
+	}
+}
+
+func TestNewWithURL(t *testing.T) {
+	for _, base := range []string{"https://proxy/mp", "https://proxy/mp/", "https://proxy/mp/export"} {
+		mix := NewWithURL("product", "key", "secret", base)
+
+		for _, u := range mix.Endpoints.urls() {
+			if u != "https://proxy/mp" {
+				t.Errorf("%s: expected every endpoint under https://proxy/mp, got %+v", base, mix.Endpoints)
+				break
+			}
+		}
+	}
+}
+
+func TestWithSecret(t *testing.T) {
+	mix := New("product", "key", "old")
+	mix.Events = []string{"a"}
+	mix.Clock = fixedClock(time.Unix(1388534400, 0))
+
+	rotated := mix.WithSecret("new")
+
+	if rotated.Secret != "new" || mix.Secret != "old" {
+		t.Fatalf("expected only the copy to change, got %q and %q", rotated.Secret, mix.Secret)
+	}
+
+	if rotated.Product != "product" || rotated.Key != "key" || rotated.Endpoints.Data != mix.Endpoints.Data || len(rotated.Events) != 1 || !rotated.IncludeImplicit {
+		t.Errorf("expected the rest of the configuration to be copied, got %+v", rotated)
+	}
+
+	date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	oldURL, _ := mix.ExportURL(context.Background(), date, nil)
+	newURL, _ := rotated.ExportURL(context.Background(), date, nil)
+
+	if oldURL == newURL {
+		t.Error("expected the copy to sign with the new secret")
+	}
+
+	_, done := rotated.startProgress(context.Background())
+	defer done()
+
+	if len(mix.inProgress) != 0 {
+		t.Error("expected the copy not to share in flight bookkeeping")
+	}
+}
+
 func BenchmarkTransformEventData(b *testing.B) {
 	mix := New("product", "", "")
 	input := strings.NewReader(
@@ -150,3 +338,204 @@ func BenchmarkTransformEventData(b *testing.B) {
 	}
 	close(output)
 }
+
+func TestTransformEventDataArray(t *testing.T) {
+	events := []string{
+		`{"event": "a0", "properties": {"b": "b0", "c": 1}}`,
+		`{"event": "a1", "properties": {"b": "b1", "c": 2}}`,
+	}
+
+	inputs := []string{
+		strings.Join(events, "\n"),
+		"  [" + strings.Join(events, ",\n") + "]\n",
+	}
+
+	var outputs [][]EventData
+
+	for _, in := range inputs {
+		mix := New("product", "", "")
+		output := make(chan EventData, 2)
+
+		if stats, err := mix.TransformEventData(strings.NewReader(in), output); err != nil {
+			t.Fatalf("raised error: %v", err)
+		} else if stats.EventsExported != 2 {
+			t.Fatalf("expected 2 records, got %d", stats.EventsExported)
+		}
+
+		close(output)
+
+		var got []EventData
+		for event := range output {
+			// The UUID will differ between runs.
+			delete(event, EventIDKey)
+			got = append(got, event)
+		}
+
+		outputs = append(outputs, got)
+	}
+
+	stream, array := outputs[0], outputs[1]
+
+	for i := range stream {
+		if fmt.Sprint(stream[i]) != fmt.Sprint(array[i]) {
+			t.Errorf("stream and array differ: %v != %v", stream[i], array[i])
+		}
+	}
+}
+
+func TestTransformEventDataPreamble(t *testing.T) {
+	events := `{"event": "a0", "properties": {"c": 1}}` + "\n" + `{"event": "a1", "properties": {"c": 2}}` + "\n"
+
+	inputs := map[string]string{
+		"BOM":             "\xEF\xBB\xBF" + events,
+		"newlines":        "\n\r\n  \n" + events,
+		"BOM, then space": "\xEF\xBB\xBF \n" + events,
+		"BOM, array":      "\xEF\xBB\xBF\n[" + strings.Replace(strings.TrimSpace(events), "\n", ",", -1) + "]",
+	}
+
+	for name, in := range inputs {
+		for _, workers := range []int{1, 4} {
+			mix := New("product", "", "")
+			mix.DecodeWorkers = workers
+			mix.PreserveOrder = true
+
+			output := make(chan EventData, 2)
+
+			stats, err := mix.TransformEventData(strings.NewReader(in), output)
+			if err != nil {
+				t.Errorf("%s (%d workers): raised error: %v", name, workers, err)
+				continue
+			} else if stats.EventsExported != 2 {
+				t.Errorf("%s (%d workers): expected 2 records, got %d", name, workers, stats.EventsExported)
+				continue
+			}
+
+			if first := <-output; first["event"] != "a0" {
+				t.Errorf("%s (%d workers): expected a0 first, got %v", name, workers, first)
+			}
+		}
+	}
+}
+
+func TestTransformEventDataTruncatedArray(t *testing.T) {
+	mix := New("product", "", "")
+	input := strings.NewReader(`[{"event": "a", "properties": {}}`)
+	output := make(chan EventData, 1)
+
+	if _, err := mix.TransformEventData(input, output); err == nil {
+		t.Error("Expected error on truncated array")
+	}
+}
+
+func TestReadBufferBoundary(t *testing.T) {
+	// Every event is longer than the buffer, so each spans several fills.
+	padding := strings.Repeat("x", 100)
+	var lines []string
+	for i := 0; i < 50; i++ {
+		lines = append(lines, fmt.Sprintf(`{"event": "e%d", "properties": {"pad": "%s", "n": %d}}`, i, padding, i))
+	}
+
+	inputs := map[string]string{
+		"lines": strings.Join(lines, "\n"),
+		"array": "[" + strings.Join(lines, ",\n") + "]",
+	}
+
+	for name, input := range inputs {
+		for _, workers := range []int{1, 4} {
+			mix := New("product", "", "")
+			mix.ReadBufferSize = 64
+			mix.DecodeWorkers = workers
+			mix.PreserveOrder = true
+
+			output := make(chan EventData, len(lines))
+
+			if _, err := mix.TransformEventData(strings.NewReader(input), output); err != nil {
+				t.Fatalf("%s/%d: %v", name, workers, err)
+			}
+			close(output)
+
+			i := 0
+			for event := range output {
+				if event["event"] != fmt.Sprintf("e%d", i) || event["pad"] != padding || event["n"] != json.Number(fmt.Sprint(i)) {
+					t.Errorf("%s/%d: bad event %d: %v", name, workers, i, event)
+				}
+				i++
+			}
+
+			if i != len(lines) {
+				t.Errorf("%s/%d: expected %d events, got %d", name, workers, len(lines), i)
+			}
+		}
+	}
+}
+
+// countingReader counts the reads made of the wrapped reader.
+type countingReader struct {
+	r     io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.r.Read(p)
+}
+
+// benchmarkReadBufferSize reads the fixture over an OS pipe, so that each read
+// of the body is a syscall as it would be for a socket.
+func benchmarkReadBufferSize(b *testing.B, size, workers int) {
+	body := []byte(strings.Repeat(`{"event": "a2", "properties": {"a": null, "b": "b2", "c": true, "d": ["foo"]}}`+"\n", 50000))
+
+	mix := New("product", "", "")
+	mix.ReadBufferSize = size
+	mix.DecodeWorkers = workers
+
+	reads := 0
+
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		go func() {
+			w.Write(body)
+			w.Close()
+		}()
+
+		output := make(chan EventData, 1000)
+		go func() {
+			for range output {
+			}
+		}()
+
+		input := &countingReader{r: r}
+		if _, err := mix.TransformEventData(input, output); err != nil {
+			b.Fatal(err)
+		}
+		close(output)
+		r.Close()
+
+		reads += input.reads
+	}
+
+	b.ReportMetric(float64(reads)/float64(b.N), "reads/op")
+}
+
+func BenchmarkReadBufferSize4K(b *testing.B) {
+	benchmarkReadBufferSize(b, DefaultReadBufferSize, 1)
+}
+
+func BenchmarkReadBufferSize64K(b *testing.B) {
+	benchmarkReadBufferSize(b, 64*1024, 1)
+}
+
+func BenchmarkReadBufferSize4KWorkers4(b *testing.B) {
+	benchmarkReadBufferSize(b, DefaultReadBufferSize, 4)
+}
+
+func BenchmarkReadBufferSize64KWorkers4(b *testing.B) {
+	benchmarkReadBufferSize(b, 64*1024, 4)
+}
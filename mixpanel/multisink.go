@@ -0,0 +1,138 @@
+package mixpanel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How a `NewMultiSink` deals with one of its sinks failing.
+const (
+	// MultiSinkFailFast fails the whole sink, and so the export, as soon as
+	// any of its sinks fails.
+	MultiSinkFailFast = iota
+
+	// MultiSinkCollectAll stops writing to a sink once it has failed, but
+	// carries on with the others. The errors are returned, together, by
+	// `Flush` (and `Close`), or by `Write` once every sink has failed.
+	MultiSinkCollectAll
+)
+
+// MultiSinkError collects the errors of the sinks of a multi sink.
+// `Errors` has the error of each sink by position, nil for the sinks which
+// didn't fail.
+type MultiSinkError struct {
+	Errors []error
+}
+
+func (e *MultiSinkError) Error() string {
+	var msgs []string
+	for i, err := range e.Errors {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("sink %d: %s", i, err))
+		}
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the error of the first sink which failed, so that
+// `errors.Is` and `errors.As` see through to it.
+func (e *MultiSinkError) Unwrap() error {
+	for _, err := range e.Errors {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// multiSink is the Sink returned by `NewMultiSink`.
+type multiSink struct {
+	sinks  []Sink
+	policy int
+	errs   []error
+}
+
+// NewMultiSink returns a Sink which writes each event to every one of
+// `sinks`, in order, and flushes and closes them all. What happens when one
+// of them fails is up to `policy` (`MultiSinkFailFast` or
+// `MultiSinkCollectAll`). Errors come back as a `*MultiSinkError`.
+//
+// Each event is written to one sink after the other, so a slow sink holds up
+// the others, and the export, just as it would on its own. Give a sink a
+// buffer of its own (a buffered `NewChanSink` feeding it from another
+// goroutine, say) if that isn't wanted.
+func NewMultiSink(policy int, sinks ...Sink) Sink {
+	return &multiSink{sinks: sinks, policy: policy, errs: make([]error, len(sinks))}
+}
+
+// err returns the errors so far, if there were any.
+func (s *multiSink) err() error {
+	for _, err := range s.errs {
+		if err != nil {
+			return &MultiSinkError{Errors: append([]error(nil), s.errs...)}
+		}
+	}
+
+	return nil
+}
+
+// healthy reports whether any sink is still there to be written to.
+func (s *multiSink) healthy() bool {
+	for _, err := range s.errs {
+		if err == nil {
+			return true
+		}
+	}
+
+	return len(s.sinks) == 0
+}
+
+func (s *multiSink) Write(event []byte) error {
+	for i, sink := range s.sinks {
+		if s.errs[i] != nil {
+			continue
+		}
+
+		if err := sink.Write(event); err != nil {
+			s.errs[i] = err
+
+			if s.policy == MultiSinkFailFast {
+				return s.err()
+			}
+		}
+	}
+
+	if !s.healthy() {
+		return s.err()
+	}
+
+	return nil
+}
+
+func (s *multiSink) Flush() error {
+	for i, sink := range s.sinks {
+		if s.errs[i] != nil {
+			continue
+		}
+
+		if err := sink.Flush(); err != nil {
+			s.errs[i] = err
+		}
+	}
+
+	return s.err()
+}
+
+// Close closes every sink, failed or not, and returns the errors of all of
+// them, including those from before.
+func (s *multiSink) Close() error {
+	for i, sink := range s.sinks {
+		if err := sink.Close(); err != nil && s.errs[i] == nil {
+			s.errs[i] = err
+		}
+	}
+
+	return s.err()
+}
@@ -0,0 +1,75 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMultiSink(t *testing.T) {
+	server := newSinkServer(3)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	archive, queue := &memorySink{}, &memorySink{}
+
+	stats, err := mix.ExportDateToSink(context.Background(), time.Now(), NewMultiSink(MultiSinkFailFast, archive, queue), nil)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 3 {
+		t.Errorf("expected 3 events, got %d", stats.EventsExported)
+	}
+
+	for name, sink := range map[string]*memorySink{"archive": archive, "queue": queue} {
+		if calls := strings.Join(sink.calls, ","); calls != "write,write,write,flush,close" {
+			t.Errorf("%s: unexpected call order: %s", name, calls)
+		}
+	}
+
+	for i := range archive.events {
+		if string(archive.events[i]) != string(queue.events[i]) {
+			t.Errorf("sinks got different events: %s and %s", archive.events[i], queue.events[i])
+		}
+	}
+}
+
+func TestMultiSinkErrors(t *testing.T) {
+	server := newSinkServer(3)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	failure := errors.New("broker down")
+
+	// Failing fast abandons the export, and every sink, on the first error.
+	good, bad := &memorySink{}, &memorySink{writeErr: failure}
+
+	_, err := mix.ExportDateToSink(context.Background(), time.Now(), NewMultiSink(MultiSinkFailFast, good, bad), nil)
+
+	var merr *MultiSinkError
+	if !errors.Is(err, failure) || !errors.As(err, &merr) || merr.Errors[0] != nil {
+		t.Errorf("expected the second sink's error, got %v", err)
+	}
+
+	if calls := strings.Join(good.calls, ","); calls != "write,close" {
+		t.Errorf("unexpected call order for the healthy sink: %s", calls)
+	}
+
+	// Collecting carries on with the healthy sink, and reports the failure
+	// at the end.
+	good, bad = &memorySink{}, &memorySink{writeErr: failure}
+
+	_, err = mix.ExportDateToSink(context.Background(), time.Now(), NewMultiSink(MultiSinkCollectAll, good, bad), nil)
+	if !errors.Is(err, failure) {
+		t.Errorf("expected the failure to be reported, got %v", err)
+	}
+
+	if calls := strings.Join(good.calls, ","); calls != "write,write,write,flush,close" {
+		t.Errorf("unexpected call order for the healthy sink: %s", calls)
+	}
+
+	if calls := strings.Join(bad.calls, ","); calls != "write,close" {
+		t.Errorf("unexpected call order for the failed sink: %s", calls)
+	}
+}
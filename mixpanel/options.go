@@ -0,0 +1,145 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MaxExportLimit is the largest `limit` the raw export endpoint accepts.
+const MaxExportLimit = 100000
+
+// ErrInvalidOptions is wrapped by the error returned for `ExportOptions`
+// which don't make a valid request.
+var ErrInvalidOptions = errors.New("invalid export options")
+
+// ExportOptions are the optional parameters of a raw export request, as an
+// alternative to passing `moreArgs` by hand.
+//
+// - `Where` is a Mixpanel expression events must match.
+// - `Events` limits the export to events with these names. It overrides
+//   the `Events` field of the Mixpanel struct.
+// - `Limit` caps the number of events returned, up to `MaxExportLimit`.
+//   Zero means no limit.
+// - `Bucket` selects the data bucket to export from.
+// - `Extra` holds any other parameters, passed through as they are.
+type ExportOptions struct {
+	Where  string
+	Events []string
+	Limit  int
+	Bucket string
+	Extra  url.Values
+}
+
+// Args validates the options and translates them to the query parameters of
+// an export request, suitable for use as `moreArgs`.
+func (o ExportOptions) Args() (*url.Values, error) {
+	args := url.Values{}
+
+	for k, vs := range o.Extra {
+		for _, v := range vs {
+			args.Add(k, v)
+		}
+	}
+
+	if o.Where != "" {
+		args.Set("where", o.Where)
+	}
+
+	if len(o.Events) > 0 {
+		for _, name := range o.Events {
+			if name == "" {
+				return nil, fmt.Errorf("%w: empty event name", ErrInvalidOptions)
+			}
+		}
+
+		// Can't fail on a slice of strings.
+		encoded, _ := json.Marshal(o.Events)
+		args.Set("event", string(encoded))
+	}
+
+	if o.Limit < 0 || o.Limit > MaxExportLimit {
+		return nil, fmt.Errorf("%w: limit %d is outside of 0-%d", ErrInvalidOptions, o.Limit, MaxExportLimit)
+	} else if o.Limit > 0 {
+		args.Set("limit", strconv.Itoa(o.Limit))
+	}
+
+	if o.Bucket != "" {
+		args.Set("bucket", o.Bucket)
+	}
+
+	return &args, nil
+}
+
+// ExportDateWithOptions is `ExportDate`, taking its extra parameters from
+// `opts` rather than a raw `moreArgs`.
+func (m *Mixpanel) ExportDateWithOptions(ctx context.Context, date time.Time, output chan<- EventData, opts ExportOptions) (Stats, error) {
+	args, err := opts.Args()
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: %w", m.Product, err)
+	}
+
+	return m.ExportDate(ctx, date, output, args)
+}
+
+// StreamDateWithOptions is `StreamDate`, taking its extra parameters from
+// `opts` rather than a raw `moreArgs`.
+func (m *Mixpanel) StreamDateWithOptions(ctx context.Context, date time.Time, w io.Writer, opts ExportOptions) (Stats, error) {
+	args, err := opts.Args()
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: %w", m.Product, err)
+	}
+
+	return m.StreamDate(ctx, date, w, args)
+}
+
+// ExportDateToSinkWithOptions is `ExportDateToSink`, taking its extra
+// parameters from `opts` rather than a raw `moreArgs`. The sink is still
+// closed if the options are invalid.
+func (m *Mixpanel) ExportDateToSinkWithOptions(ctx context.Context, date time.Time, sink Sink, opts ExportOptions) (Stats, error) {
+	args, err := opts.Args()
+	if err != nil {
+		sink.Close()
+		return Stats{}, fmt.Errorf("%s: %w", m.Product, err)
+	}
+
+	return m.ExportDateToSink(ctx, date, sink, args)
+}
+
+// peopleArgs validates `opts` as the parameters of a People export, which
+// only understands `Where` (and whatever is in `Extra`).
+func (o ExportOptions) peopleArgs() (*url.Values, error) {
+	if len(o.Events) > 0 || o.Limit != 0 || o.Bucket != "" {
+		return nil, fmt.Errorf("%w: People exports only take Where and Extra", ErrInvalidOptions)
+	}
+
+	return o.Args()
+}
+
+// ExportPeopleWithOptions is `ExportPeople`, filtering profiles by
+// `opts.Where` and passing on `opts.Extra` (`output_properties`, say). The
+// other options are for events only, and are rejected.
+func (m *Mixpanel) ExportPeopleWithOptions(ctx context.Context, output chan<- EventData, opts ExportOptions) (int, error) {
+	args, err := opts.peopleArgs()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", m.Product, err)
+	}
+
+	return m.exportPeoplePages(ctx, output, args, "", 0)
+}
+
+// ExportPeopleConcurrentWithOptions is `ExportPeopleConcurrent`, taking its
+// parameters from `opts` as `ExportPeopleWithOptions` does.
+func (m *Mixpanel) ExportPeopleConcurrentWithOptions(ctx context.Context, concurrency int, output chan<- EventData, opts ExportOptions) (int, error) {
+	args, err := opts.peopleArgs()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", m.Product, err)
+	}
+
+	return m.exportPeopleConcurrent(ctx, concurrency, output, args)
+}
@@ -0,0 +1,163 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExportOptionsArgs(t *testing.T) {
+	for _, tc := range []struct {
+		opts     ExportOptions
+		param    string
+		expected string
+	}{
+		{ExportOptions{Where: `properties["a"] == 1`}, "where", `properties["a"] == 1`},
+		{ExportOptions{Events: []string{"a", "b c"}}, "event", `["a","b c"]`},
+		{ExportOptions{Limit: 10}, "limit", "10"},
+		{ExportOptions{Bucket: "eu"}, "bucket", "eu"},
+		{ExportOptions{Extra: url.Values{"x": {"y"}}}, "x", "y"},
+	} {
+		args, err := tc.opts.Args()
+		if err != nil {
+			t.Errorf("%+v: raised error: %v", tc.opts, err)
+		} else if got := args.Get(tc.param); got != tc.expected {
+			t.Errorf("%+v: expected %s=%s, got %q", tc.opts, tc.param, tc.expected, got)
+		} else if len(*args) != 1 {
+			t.Errorf("%+v: expected only %s, got %v", tc.opts, tc.param, *args)
+		}
+	}
+
+	for _, opts := range []ExportOptions{
+		{Limit: -1},
+		{Limit: MaxExportLimit + 1},
+		{Events: []string{"a", ""}},
+	} {
+		if _, err := opts.Args(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("%+v: expected ErrInvalidOptions, got %v", opts, err)
+		}
+	}
+}
+
+func TestExportDateWithOptions(t *testing.T) {
+	var query url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Events = []string{"ignored"}
+
+	opts := ExportOptions{Where: "true", Events: []string{"a"}, Limit: 5, Bucket: "b"}
+	if _, err := mix.ExportDateWithOptions(context.Background(), time.Now(), make(chan EventData), opts); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	for param, expected := range map[string]string{"where": "true", "event": `["a"]`, "limit": "5", "bucket": "b"} {
+		if got := query[param]; len(got) != 1 || got[0] != expected {
+			t.Errorf("expected %s=%s, got %q", param, expected, got)
+		}
+	}
+
+	// Every option must be covered by the signature.
+	signed := url.Values{}
+	for k, vs := range query {
+		if k != "sig" {
+			signed[k] = vs
+		}
+	}
+	mix.addSignature(&signed, mix.Secret)
+
+	if sig := query.Get("sig"); sig == "" || sig != signed.Get("sig") {
+		t.Errorf("expected signature %s, got %s", signed.Get("sig"), sig)
+	}
+
+	if _, err := mix.ExportDateWithOptions(context.Background(), time.Now(), make(chan EventData), ExportOptions{Limit: -1}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("expected ErrInvalidOptions, got %v", err)
+	}
+}
+
+func TestWithOptionsVariants(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries = make(map[string]url.Values)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries[r.URL.Path] = r.URL.Query()
+		mu.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, "engage") {
+			fmt.Fprint(w, `{"page": 0, "page_size": 1000, "results": []}`)
+		}
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Endpoints.Query = server.URL
+
+	expect := func(name, param, expected string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for path, query := range queries {
+			if got := query.Get(param); got != expected {
+				t.Errorf("%s (%s): expected %s=%s, got %q", name, path, param, expected, got)
+			}
+		}
+
+		queries = make(map[string]url.Values)
+	}
+
+	ctx := context.Background()
+	opts := ExportOptions{Where: `properties["a"] == 1`, Limit: 5}
+
+	var buf bytes.Buffer
+	if _, err := mix.StreamDateWithOptions(ctx, time.Now(), &buf, opts); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+	expect("StreamDateWithOptions", "limit", "5")
+
+	if _, err := mix.ExportDateToSinkWithOptions(ctx, time.Now(), &memorySink{}, opts); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+	expect("ExportDateToSinkWithOptions", "limit", "5")
+
+	if _, err := mix.ExportAll(ctx, time.Now(), make(chan EventData, 1), ExportAllOptions{EventOptions: opts, Where: "people"}); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	mu.Lock()
+	if queries["/export"].Get("limit") != "5" || queries["/engage"].Get("where") != "people" {
+		t.Errorf("ExportAll: unexpected requests %v", queries)
+	}
+	queries = make(map[string]url.Values)
+	mu.Unlock()
+
+	peopleOpts := ExportOptions{Where: "people", Extra: url.Values{"output_properties": {`["$email"]`}}}
+
+	if _, err := mix.ExportPeopleWithOptions(ctx, make(chan EventData), peopleOpts); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+	expect("ExportPeopleWithOptions", "output_properties", `["$email"]`)
+
+	if _, err := mix.ExportPeopleConcurrentWithOptions(ctx, 2, make(chan EventData), peopleOpts); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+	expect("ExportPeopleConcurrentWithOptions", "where", "people")
+
+	if _, err := mix.ExportPeopleWithOptions(ctx, make(chan EventData), opts); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("expected event options to be rejected for people, got %v", err)
+	}
+}
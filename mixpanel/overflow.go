@@ -0,0 +1,125 @@
+package mixpanel
+
+import "context"
+
+// What to do with events when the consumer of the output channel falls
+// behind.
+const (
+	// OverflowBlock waits for the consumer, holding up the export (and so
+	// the download) until it catches up. Nothing is lost.
+	OverflowBlock = iota
+
+	// OverflowDropOldest makes room for each new event by dropping the
+	// oldest of those waiting for the consumer.
+	OverflowDropOldest
+
+	// OverflowDropNewest drops new events until the consumer has made room
+	// for them.
+	OverflowDropNewest
+)
+
+// DefaultOverflowBuffer is how many events can be waiting for the consumer
+// before `OverflowPolicy` starts dropping them, when `OverflowBuffer` is
+// zero.
+const DefaultOverflowBuffer = 1024
+
+// overflowBuffer returns the configured `OverflowBuffer`, using the default
+// if unset.
+func (m *Mixpanel) overflowBuffer() int {
+	if m.OverflowBuffer <= 0 {
+		return DefaultOverflowBuffer
+	}
+
+	return m.OverflowBuffer
+}
+
+// stage queues the events of an export on their way to the output channel,
+// from a goroutine of its own, dropping them by `OverflowPolicy` rather than
+// making the export wait for the consumer. A nil stage does nothing.
+type stage struct {
+	events chan EventData
+	done   chan struct{}
+
+	// Set by the goroutine before `done` is closed.
+	sent    int
+	dropped int
+}
+
+// startStage starts staging events for `output`, unless the policy is to
+// block. Returns the channel the export should send to instead, if so.
+func (m *Mixpanel) startStage(ctx context.Context, output chan<- EventData) (*stage, chan<- EventData) {
+	if m.OverflowPolicy == OverflowBlock {
+		return nil, output
+	}
+
+	s := &stage{events: make(chan EventData), done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+		s.run(ctx, output, m.overflowBuffer(), m.OverflowPolicy)
+	}()
+
+	return s, s.events
+}
+
+// run takes events as they come, and sends them on to `output` as fast as it
+// will take them, keeping at most `max` of them waiting.
+func (s *stage) run(ctx context.Context, output chan<- EventData, max, policy int) {
+	var queue []EventData
+
+	for in := s.events; in != nil || len(queue) > 0; {
+		// Only try to send when there's something to send.
+		var (
+			out  chan<- EventData
+			next EventData
+		)
+
+		if len(queue) > 0 {
+			out, next = output, queue[0]
+		}
+
+		select {
+		case event, ok := <-in:
+			if !ok {
+				in = nil
+				continue
+			}
+
+			if len(queue) == max {
+				s.dropped++
+
+				if policy == OverflowDropNewest {
+					continue
+				}
+
+				queue[0] = nil
+				queue = queue[1:]
+			}
+
+			queue = append(queue, event)
+		case out <- next:
+			queue[0] = nil
+			queue = queue[1:]
+			s.sent++
+		case <-ctx.Done():
+			// The export gives up sending too, so nothing more is
+			// coming.
+			return
+		}
+	}
+}
+
+// stop waits for the queued events to be sent (or `ctx` to be done), and
+// sets the stats of what was sent and dropped. The export has to be done
+// sending.
+func (s *stage) stop(stats *Stats) {
+	if s == nil {
+		return
+	}
+
+	close(s.events)
+	<-s.done
+
+	stats.EventsExported = s.sent
+	stats.Dropped = s.dropped
+}
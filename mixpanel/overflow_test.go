@@ -0,0 +1,101 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOverflowPolicy(t *testing.T) {
+	cases := []struct {
+		Policy   int
+		Received []int
+	}{
+		{OverflowDropOldest, []int{7, 8, 9}},
+		{OverflowDropNewest, []int{0, 1, 2}},
+	}
+
+	for _, c := range cases {
+		mix := New("product", "key", "secret")
+		mix.OverflowPolicy = c.Policy
+		mix.OverflowBuffer = 3
+
+		output := make(chan EventData)
+		st, staged := mix.startStage(context.Background(), output)
+
+		// Nothing is reading the output yet, so every event has to be
+		// queued or dropped.
+		for i := 0; i < 10; i++ {
+			staged <- EventData{"i": i}
+		}
+
+		received := make(chan []int)
+		go func() {
+			var got []int
+			for event := range output {
+				got = append(got, event["i"].(int))
+			}
+			received <- got
+		}()
+
+		var stats Stats
+		st.stop(&stats)
+		close(output)
+
+		if got := <-received; !reflect.DeepEqual(got, c.Received) {
+			t.Errorf("policy %d: expected %v, got %v", c.Policy, c.Received, got)
+		}
+
+		if stats.EventsExported != 3 || stats.Dropped != 7 {
+			t.Errorf("policy %d: expected 3 exported and 7 dropped, got %+v", c.Policy, stats)
+		}
+	}
+}
+
+func TestOverflowPolicyExport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"i": %d}}`+"\n", i)
+		}
+	}))
+	defer server.Close()
+
+	for _, policy := range []int{OverflowBlock, OverflowDropOldest, OverflowDropNewest} {
+		mix := NewWithURL("product", "key", "secret", server.URL)
+		mix.OverflowPolicy = policy
+		mix.OverflowBuffer = 2
+
+		output := make(chan EventData)
+		received := make(chan int)
+
+		go func() {
+			n := 0
+			for range output {
+				time.Sleep(time.Millisecond)
+				n++
+			}
+			received <- n
+		}()
+
+		stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil)
+		close(output)
+
+		if err != nil {
+			t.Fatalf("policy %d: raised error: %v", policy, err)
+		}
+
+		n := <-received
+
+		if n != stats.EventsExported || stats.EventsExported+stats.Dropped != 100 {
+			t.Errorf("policy %d: %d received, inconsistent with %+v", policy, n, stats)
+		}
+
+		if blocked := policy == OverflowBlock; blocked != (stats.Dropped == 0) {
+			t.Errorf("policy %d: unexpected %d dropped", policy, stats.Dropped)
+		}
+	}
+}
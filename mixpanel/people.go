@@ -0,0 +1,298 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// peoplePage is a single page of results returned by the engage endpoint.
+//
+// `Total` is only reported on the first page of a session.
+type peoplePage struct {
+	Page      int
+	PageSize  int    `json:"page_size"`
+	SessionID string `json:"session_id"`
+	Total     int
+	Results   []struct {
+		DistinctID string                 `json:"$distinct_id"`
+		Properties map[string]interface{} `json:"$properties"`
+	}
+}
+
+// fetchPeoplePage requests one page of People profiles from the engage
+// endpoint, with the extra parameters `filter` (which may be nil).
+// `sessionID` should be empty when requesting the first page.
+func (m *Mixpanel) fetchPeoplePage(ctx context.Context, filter *url.Values, sessionID string, page int) (*peoplePage, error) {
+	args := m.baseArgs()
+	mergeArgs(args, filter, EndpointEngage)
+
+	if sessionID != "" {
+		args.Set("session_id", sessionID)
+		args.Set("page", strconv.Itoa(page))
+	}
+
+	var result peoplePage
+	if err := m.query(ctx, EndpointEngage, args, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// sendPeoplePage performs the same sort of transformation on People profiles
+// that `TransformEventData` does for events, and hands them off to `output`.
+//
+// Output: `{"$distinct_id": "...", "product": "...", "k": "v", ...}`
+func (m *Mixpanel) sendPeoplePage(ctx context.Context, page *peoplePage, output chan<- EventData) (int, error) {
+	for i, profile := range page.Results {
+		data := EventData(profile.Properties)
+		if data == nil {
+			data = make(EventData)
+		}
+
+		data[PropDistinctID] = profile.DistinctID
+		stamp(data, m.productKey(), m.Product)
+
+		// The profile belongs to whoever receives it from `output`, so
+		// its transactions have to be pulled out before it's sent.
+		var txs []EventData
+		if m.Transactions != nil {
+			txs = ProfileTransactions(data)
+			for _, tx := range txs {
+				stamp(tx, m.productKey(), m.Product)
+			}
+		}
+
+		select {
+		case output <- data:
+		case <-ctx.Done():
+			return i, ctx.Err()
+		}
+
+		for _, tx := range txs {
+			if err := sendEvent(ctx, m.Transactions, tx); err != nil {
+				return i + 1, err
+			}
+		}
+	}
+
+	return len(page.Results), nil
+}
+
+// ExportPeople downloads all People profiles matching the (optional) `where`
+// expression, streaming each over the `output` channel.
+//
+// Returns the number of profiles that have been processed and possibly an
+// error.
+//
+// If `OnPeoplePage` is set, it's told the session and page number of every
+// page once its profiles have been sent, so that a crashed export can pick up
+// where it left off with `ExportPeopleResume`.
+func (m *Mixpanel) ExportPeople(ctx context.Context, output chan<- EventData, where string) (int, error) {
+	return m.exportPeoplePages(ctx, output, whereArgs(where), "", 0)
+}
+
+// whereArgs returns the parameters filtering People profiles by `where`, if
+// it isn't empty.
+func whereArgs(where string) *url.Values {
+	if where == "" {
+		return nil
+	}
+
+	return &url.Values{"where": {where}}
+}
+
+// ExportPeopleResume continues a paginated `ExportPeople` from page
+// `startPage` of the session `sessionID`. To carry on after the last page
+// reported to `OnPeoplePage`, pass that page's number plus one. `where`
+// should be the same as given to the export being resumed, since it's sent
+// with every page request.
+//
+// Sessions are only valid for a limited time. If Mixpanel no longer knows
+// the session, an error matching `ErrSessionExpired` is returned, and the
+// export has to be restarted with `ExportPeople`.
+func (m *Mixpanel) ExportPeopleResume(ctx context.Context, sessionID string, startPage int, output chan<- EventData, where string) (int, error) {
+	if sessionID == "" {
+		return 0, fmt.Errorf("%s: resuming a people export requires a session ID", m.Product)
+	}
+
+	return m.exportPeoplePages(ctx, output, whereArgs(where), sessionID, startPage)
+}
+
+// exportPeoplePages pages through the engage endpoint from `page` onwards,
+// starting a new session if `sessionID` is empty.
+func (m *Mixpanel) exportPeoplePages(ctx context.Context, output chan<- EventData, filter *url.Values, sessionID string, page int) (int, error) {
+	total := 0
+
+	for ; ; page++ {
+		result, err := m.fetchPeoplePage(ctx, filter, sessionID, page)
+		if err != nil {
+			return total, err
+		}
+
+		num, err := m.sendPeoplePage(ctx, result, output)
+		total += num
+
+		if err != nil {
+			return total, err
+		}
+
+		if result.SessionID != "" {
+			sessionID = result.SessionID
+		}
+
+		if m.OnPeoplePage != nil {
+			m.OnPeoplePage(sessionID, page)
+		}
+
+		// A short page means we've reached the end.
+		if result.PageSize == 0 || len(result.Results) < result.PageSize {
+			return total, nil
+		}
+	}
+}
+
+// ExportPeopleConcurrent is like `ExportPeople`, but once the first page has
+// told us how many profiles there are, the remaining pages are fetched with
+// up to `concurrency` requests in flight at once.
+//
+// Profiles from different pages may be interleaved on `output` in any
+// order. If any page fails, or `ctx` is cancelled, outstanding requests are
+// abandoned and the first error is returned.
+func (m *Mixpanel) ExportPeopleConcurrent(ctx context.Context, concurrency int, output chan<- EventData, where string) (int, error) {
+	return m.exportPeopleConcurrent(ctx, concurrency, output, whereArgs(where))
+}
+
+// exportPeopleConcurrent does the work of `ExportPeopleConcurrent`, with the
+// extra parameters `filter`.
+func (m *Mixpanel) exportPeopleConcurrent(ctx context.Context, concurrency int, output chan<- EventData, filter *url.Values) (int, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	first, err := m.fetchPeoplePage(ctx, filter, "", 0)
+	if err != nil {
+		return 0, err
+	}
+
+	total, err := m.sendPeoplePage(ctx, first, output)
+	if err != nil || first.PageSize == 0 || first.Total <= first.PageSize {
+		return total, err
+	}
+
+	numPages := (first.Total + first.PageSize - 1) / first.PageSize
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	// Record the first error seen and tell everyone else to stop.
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for page := range pages {
+				result, err := m.fetchPeoplePage(ctx, filter, first.SessionID, page)
+				if err != nil {
+					fail(err)
+					return
+				}
+
+				num, err := m.sendPeoplePage(ctx, result, output)
+
+				mu.Lock()
+				total += num
+				mu.Unlock()
+
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for page := 1; page < numPages; page++ {
+		select {
+		case pages <- page:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(pages)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = fmt.Errorf("%s: people export cancelled: %s", m.Product, ctx.Err())
+	}
+
+	return total, firstErr
+}
+
+// ExportPeopleBulk downloads every People profile in the project through the
+// bulk people export endpoint, streaming each over `output` in the same form
+// as `ExportPeople`.
+//
+// For projects with millions of profiles this scales much better than the
+// engage endpoint. The endpoint is paginated with an opaque cursor, which is
+// followed until the server stops returning one.
+//
+// Requires `ProjectID` and service account credentials to be set.
+func (m *Mixpanel) ExportPeopleBulk(ctx context.Context, output chan<- EventData) (int, error) {
+	if m.ProjectID == "" {
+		return 0, fmt.Errorf("%s: bulk people export requires a project ID", m.Product)
+	}
+
+	endpoint := fmt.Sprintf("projects/%s/people/export", url.PathEscape(m.ProjectID))
+
+	total := 0
+	cursor := ""
+
+	for {
+		args := url.Values{}
+		if cursor != "" {
+			args.Set("cursor", cursor)
+		}
+
+		var page struct {
+			peoplePage
+			NextCursor string `json:"next_cursor"`
+		}
+
+		if err := m.appQuery(ctx, endpoint, args, &page); err != nil {
+			return total, err
+		}
+
+		num, err := m.sendPeoplePage(ctx, &page.peoplePage, output)
+		total += num
+
+		if err != nil || page.NextCursor == "" {
+			return total, err
+		}
+
+		cursor = page.NextCursor
+	}
+}
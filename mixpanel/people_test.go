@@ -0,0 +1,260 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// newPeopleServer fakes the engage endpoint, serving `total` profiles in pages
+// of `pageSize`.
+func newPeopleServer(t *testing.T, total, pageSize int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := 0
+
+		if r.URL.Query().Get("session_id") != "" {
+			page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+		}
+
+		var results []map[string]interface{}
+		for i := page * pageSize; i < total && i < (page+1)*pageSize; i++ {
+			results = append(results, map[string]interface{}{
+				"$distinct_id": fmt.Sprintf("user%d", i),
+				"$properties":  map[string]interface{}{"page": page},
+			})
+		}
+
+		resp := map[string]interface{}{
+			"page":       page,
+			"page_size":  pageSize,
+			"session_id": "session",
+			"status":     "ok",
+			"results":    results,
+		}
+
+		if page == 0 {
+			resp["total"] = total
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+// collectPeople drains profiles into a map of distinct_id -> times seen.
+func collectPeople(output <-chan EventData, done chan<- map[string]int) {
+	seen := make(map[string]int)
+	for profile := range output {
+		seen[profile["$distinct_id"].(string)]++
+	}
+	done <- seen
+}
+
+func TestExportPeople(t *testing.T) {
+	server := newPeopleServer(t, 7, 2)
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	output := make(chan EventData)
+	done := make(chan map[string]int)
+	go collectPeople(output, done)
+
+	num, err := mix.ExportPeople(context.Background(), output, "")
+	close(output)
+	seen := <-done
+
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if num != 7 || len(seen) != 7 {
+		t.Errorf("expected 7 profiles, got %d (%d unique)", num, len(seen))
+	}
+}
+
+func TestExportPeopleConcurrent(t *testing.T) {
+	server := newPeopleServer(t, 23, 3)
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	for _, concurrency := range []int{1, 2, 4, 16} {
+		output := make(chan EventData)
+		done := make(chan map[string]int)
+		go collectPeople(output, done)
+
+		num, err := mix.ExportPeopleConcurrent(context.Background(), concurrency, output, "")
+		close(output)
+		seen := <-done
+
+		if err != nil {
+			t.Fatalf("concurrency=%d: raised error: %v", concurrency, err)
+		} else if num != 23 || len(seen) != 23 {
+			t.Errorf("concurrency=%d: expected 23 profiles, got %d (%d unique)",
+				concurrency, num, len(seen))
+		}
+
+		for id, count := range seen {
+			if count != 1 {
+				t.Errorf("concurrency=%d: saw %s %d times", concurrency, id, count)
+			}
+		}
+	}
+}
+
+func TestExportPeopleConcurrentCancel(t *testing.T) {
+	server := newPeopleServer(t, 100, 2)
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Nobody reads past the first profile, so the export can only finish
+	// by noticing the cancellation.
+	output := make(chan EventData)
+	go func() {
+		<-output
+		cancel()
+	}()
+
+	if _, err := mix.ExportPeopleConcurrent(ctx, 4, output, ""); err == nil {
+		t.Error("expected an error after cancellation")
+	}
+}
+
+func TestExportPeopleBulk(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"results": [{"$distinct_id": "user0"}, {"$distinct_id": "user1"}], "next_cursor": "c1"}`,
+		"c1": `{"results": [{"$distinct_id": "user2"}], "next_cursor": "c2"}`,
+		"c2": `{"results": [{"$distinct_id": "user3"}, {"$distinct_id": "user4"}]}`,
+	}
+
+	var cursors []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/123/people/export" {
+			http.NotFound(w, r)
+			return
+		} else if _, _, ok := r.BasicAuth(); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+
+		fmt.Fprint(w, pages[cursor])
+	}))
+	defer server.Close()
+
+	mix := New("product", "", "")
+	mix.Endpoints.App = server.URL
+	mix.ProjectID = "123"
+	mix.ServiceAccountUser = "user"
+	mix.ServiceAccountSecret = "pass"
+
+	output := make(chan EventData)
+	done := make(chan map[string]int)
+	go collectPeople(output, done)
+
+	num, err := mix.ExportPeopleBulk(context.Background(), output)
+	close(output)
+	seen := <-done
+
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if num != 5 || len(seen) != 5 {
+		t.Errorf("expected 5 profiles, got %d (%d unique)", num, len(seen))
+	}
+
+	if len(cursors) != 3 || cursors[1] != "c1" || cursors[2] != "c2" {
+		t.Errorf("expected cursor to be followed, got %q", cursors)
+	}
+}
+
+func TestExportPeopleResume(t *testing.T) {
+	people := newPeopleServer(t, 7, 2)
+	defer people.Close()
+
+	var (
+		mu     sync.Mutex
+		wheres []string
+	)
+
+	// Check every resumed page keeps the original filter.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		wheres = append(wheres, r.URL.Query().Get("where"))
+		mu.Unlock()
+
+		http.Redirect(w, r, people.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	var progress []int
+	mix.OnPeoplePage = func(sessionID string, page int) {
+		if sessionID != "session" {
+			t.Errorf("expected session to be reported, got %q", sessionID)
+		}
+		progress = append(progress, page)
+	}
+
+	output := make(chan EventData)
+	done := make(chan map[string]int)
+	go collectPeople(output, done)
+
+	num, err := mix.ExportPeopleResume(context.Background(), "session", 2, output, `properties["plan"] == "pro"`)
+	close(output)
+	seen := <-done
+
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if num != 3 || len(seen) != 3 {
+		t.Errorf("expected 3 profiles, got %d (%d unique)", num, len(seen))
+	}
+
+	for _, id := range []string{"user4", "user5", "user6"} {
+		if seen[id] != 1 {
+			t.Errorf("expected %s once, saw it %d times", id, seen[id])
+		}
+	}
+
+	if len(progress) != 2 || progress[0] != 2 || progress[1] != 3 {
+		t.Errorf("expected progress through pages 2 and 3, got %v", progress)
+	}
+
+	if len(wheres) != 2 || wheres[0] != `properties["plan"] == "pro"` || wheres[1] != wheres[0] {
+		t.Errorf("expected where on every resumed page, got %q", wheres)
+	}
+}
+
+func TestExportPeopleResumeExpired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "Invalid session_id: session has expired"}`)
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	_, err := mix.ExportPeopleResume(context.Background(), "stale", 5, make(chan EventData), "")
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("expected ErrSessionExpired, got %v", err)
+	} else if errors.Is(err, ErrSignatureExpired) {
+		t.Errorf("session expiry mistaken for signature expiry: %v", err)
+	}
+}
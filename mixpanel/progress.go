@@ -0,0 +1,92 @@
+package mixpanel
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// progress counts what one export has done so far, for `InFlightStats`. Its
+// fields are only accessed atomically, and kept first so they're 64-bit
+// aligned on 32-bit platforms. A nil progress counts nothing.
+type progress struct {
+	events    int64
+	bytes     int64
+	oversized int64
+	noTime    int64
+}
+
+// progressKey is the context key of the progress of the export running.
+type progressKey struct{}
+
+// startProgress registers a new in flight export, returning a copy of `ctx`
+// carrying its progress, and the function to call once it's done.
+func (m *Mixpanel) startProgress(ctx context.Context) (context.Context, func()) {
+	p := &progress{}
+
+	m.progressMu.Lock()
+	if m.inProgress == nil {
+		m.inProgress = make(map[*progress]bool)
+	}
+	m.inProgress[p] = true
+	m.progressMu.Unlock()
+
+	done := func() {
+		m.progressMu.Lock()
+		delete(m.inProgress, p)
+		m.progressMu.Unlock()
+	}
+
+	return context.WithValue(ctx, progressKey{}, p), done
+}
+
+// progressFrom returns the progress of the export `ctx` belongs to, or nil.
+func progressFrom(ctx context.Context) *progress {
+	p, _ := ctx.Value(progressKey{}).(*progress)
+	return p
+}
+
+func (p *progress) sent() {
+	if p != nil {
+		atomic.AddInt64(&p.events, 1)
+	}
+}
+
+func (p *progress) read(n int) {
+	if p != nil {
+		atomic.AddInt64(&p.bytes, int64(n))
+	}
+}
+
+func (p *progress) oversize(n int) {
+	if p != nil && n > 0 {
+		atomic.AddInt64(&p.oversized, int64(n))
+	}
+}
+
+func (p *progress) missTime(n int) {
+	if p != nil && n > 0 {
+		atomic.AddInt64(&p.noTime, int64(n))
+	}
+}
+
+// InFlightStats returns a snapshot of the Stats of the exports running right
+// now, added together: how many events they've sent so far, how many bytes
+// they've read, and so on. It's safe to call from another goroutine while
+// exporting, so a progress display can poll it rather than counting events
+// itself. Finished exports no longer count; their totals are in the Stats
+// they returned. Checksums are only computed at the end, so are never set.
+func (m *Mixpanel) InFlightStats() Stats {
+	var stats Stats
+
+	m.progressMu.Lock()
+	defer m.progressMu.Unlock()
+
+	for p := range m.inProgress {
+		stats.EventsExported += int(atomic.LoadInt64(&p.events))
+		stats.BytesRead += atomic.LoadInt64(&p.bytes)
+		stats.OversizedEvents += int(atomic.LoadInt64(&p.oversized))
+		stats.MissingTime += int(atomic.LoadInt64(&p.noTime))
+	}
+
+	return stats
+}
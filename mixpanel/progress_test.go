@@ -0,0 +1,92 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInFlightStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"time": %d}}`+"\n", 1388534400+i)
+		}
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	output := make(chan EventData)
+
+	// Poll from another goroutine for as long as the export runs, which is
+	// what -race is watching.
+	stop := make(chan struct{})
+	polled := make(chan int)
+
+	go func() {
+		last := 0
+		for {
+			select {
+			case <-stop:
+				polled <- last
+				return
+			default:
+			}
+
+			// Drops to zero once the export is over.
+			n := mix.InFlightStats().EventsExported
+			if n == 0 {
+				continue
+			} else if n < last {
+				t.Errorf("count went backwards: %d after %d", n, last)
+			}
+			last = n
+		}
+	}()
+
+	result := make(chan Stats)
+	go func() {
+		stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil)
+		if err != nil {
+			t.Errorf("raised error: %v", err)
+		}
+		result <- stats
+	}()
+
+	for i := 0; i < 5; i++ {
+		<-output
+	}
+
+	// The sixth event can't be sent until it's read, so the count settles
+	// at five.
+	deadline := time.Now().Add(5 * time.Second)
+	for mix.InFlightStats().EventsExported != 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 5 events in flight, got %+v", mix.InFlightStats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if stats := mix.InFlightStats(); stats.BytesRead == 0 {
+		t.Errorf("expected bytes to be counted, got %+v", stats)
+	}
+
+	for i := 0; i < 5; i++ {
+		<-output
+	}
+
+	if stats := <-result; stats.EventsExported != 10 {
+		t.Errorf("expected 10 events, got %d", stats.EventsExported)
+	}
+
+	close(stop)
+	if last := <-polled; last > 10 {
+		t.Errorf("polled an impossible count %d", last)
+	}
+
+	if stats := mix.InFlightStats(); stats.EventsExported != 0 || stats.BytesRead != 0 {
+		t.Errorf("expected a finished export to stop counting, got %+v", stats)
+	}
+}
@@ -0,0 +1,235 @@
+package mixpanel
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Properties which are retained even if they aren't explicitly listed in
+// `Keep`.
+var alwaysKept = []string{"event", "product", "distinct_id", "time"}
+
+// rawEvent is a single record as it comes out of the raw export API.
+type rawEvent struct {
+	Error      *string
+	Event      string
+	Properties map[string]interface{}
+}
+
+// projectedProperties decodes a properties object, keeping only the keys in
+// `keep`. The properties are scanned in place, and the values of every other
+// key are skipped over without ever being decoded or copied, which saves a lot
+// of allocation on property heavy events.
+type projectedProperties struct {
+	keep  map[string]bool
+	props map[string]interface{}
+}
+
+func (p *projectedProperties) UnmarshalJSON(data []byte) error {
+	// The decoder has already validated `data` by the time we see it, so
+	// the scanning below can take well formed JSON for granted.
+	i := skipSpace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		// Most likely `null`, leave the properties empty.
+		return nil
+	}
+
+	p.props = make(map[string]interface{}, len(p.keep))
+
+	for i = skipSpace(data, i+1); i < len(data) && data[i] != '}'; {
+		keyEnd := skipValue(data, i)
+		rawKey := data[i+1 : keyEnd-1]
+
+		valueStart := skipSpace(data, skipSpace(data, keyEnd)+1)
+		valueEnd := skipValue(data, valueStart)
+
+		// No allocation for the lookup unless the key has escapes.
+		kept := false
+		key := ""
+		if bytes.IndexByte(rawKey, '\\') < 0 {
+			if kept = p.keep[string(rawKey)]; kept {
+				key = string(rawKey)
+			}
+		} else if err := json.Unmarshal(data[i:keyEnd], &key); err != nil {
+			return err
+		} else {
+			kept = p.keep[key]
+		}
+
+		if kept {
+			v, err := decodeValue(data[valueStart:valueEnd])
+			if err != nil {
+				return err
+			}
+
+			p.props[key] = v
+		}
+
+		// Past the value, and the comma after it, if any.
+		if i = skipSpace(data, valueEnd); i < len(data) && data[i] == ',' {
+			i = skipSpace(data, i+1)
+		}
+	}
+
+	return nil
+}
+
+// decodeValue decodes a single JSON value like `decoder.UseNumber()` would,
+// without the overhead of a decoder for the common scalar cases.
+func decodeValue(value []byte) (interface{}, error) {
+	switch value[0] {
+	case '"':
+		if bytes.IndexByte(value, '\\') < 0 {
+			return string(value[1 : len(value)-1]), nil
+		}
+	case 't':
+		return true, nil
+	case 'f':
+		return false, nil
+	case 'n':
+		return nil, nil
+	case '{', '[':
+	default:
+		return json.Number(value), nil
+	}
+
+	var v interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// skipSpace returns the index of the first non whitespace byte of `data` at
+// or after `i`.
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+		i++
+	}
+
+	return i
+}
+
+// endsScalar reports whether `c` is the first byte after a number, `true`,
+// `false` or `null`.
+func endsScalar(c byte) bool {
+	switch c {
+	case ',', '}', ']', ' ', '\t', '\n', '\r':
+		return true
+	}
+
+	return false
+}
+
+// skipValue returns the index just past the (well formed) JSON value starting
+// at `data[i]`.
+func skipValue(data []byte, i int) int {
+	depth := 0
+
+	for ; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '"':
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+		case '{', '[':
+			depth++
+			continue
+		case '}', ']':
+			depth--
+		default:
+			if depth > 0 {
+				continue
+			}
+
+			// Scalars run up to the next delimiter.
+			for i < len(data) && !endsScalar(data[i]) {
+				i++
+			}
+
+			return i
+		}
+
+		if depth == 0 {
+			return i + 1
+		}
+	}
+
+	return i
+}
+
+// keepSet builds the lookup table of properties to keep when projecting, or
+// nil if every property should be kept.
+func (m *Mixpanel) keepSet() map[string]bool {
+	if len(m.Keep) == 0 {
+		return nil
+	}
+
+	keep := make(map[string]bool, len(m.Keep)+len(alwaysKept))
+
+	for _, key := range alwaysKept {
+		keep[key] = true
+	}
+
+	for _, key := range m.Keep {
+		keep[key] = true
+	}
+
+	return keep
+}
+
+// decodeEvent reads the next record from `decoder` into `ev`, discarding any
+// properties not in `keep` (if it is non-nil).
+func decodeEvent(decoder *json.Decoder, keep map[string]bool, ev *rawEvent) error {
+	if keep == nil {
+		return decoder.Decode(ev)
+	}
+
+	projected := struct {
+		Error      *string
+		Event      string
+		Properties projectedProperties
+	}{Properties: projectedProperties{keep: keep}}
+
+	if err := decoder.Decode(&projected); err != nil {
+		return err
+	}
+
+	ev.Error = projected.Error
+	ev.Event = projected.Event
+	ev.Properties = projected.Properties.props
+
+	return nil
+}
+
+// decodeRawEvent is like `decodeEvent`, but also returns the exact bytes of
+// the record, for `RawChan`.
+func decodeRawEvent(decoder *json.Decoder, keep map[string]bool, ev *rawEvent) (json.RawMessage, error) {
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	inner := json.NewDecoder(bytes.NewReader(raw))
+	inner.UseNumber()
+
+	return raw, decodeEvent(inner, keep, ev)
+}
+
+// decodeNext decodes the next record with whichever of `decodeEvent` and
+// `decodeRawEvent` is needed. The raw bytes are nil unless `RawChan`, `Tap`
+// or `DuplicateKeysReject` need them.
+func (m *Mixpanel) decodeNext(decoder *json.Decoder, keep map[string]bool, ev *rawEvent) (json.RawMessage, error) {
+	if m.RawChan == nil && m.Tap == nil && m.DuplicateKeyPolicy != DuplicateKeysReject {
+		return nil, decodeEvent(decoder, keep, ev)
+	}
+
+	return decodeRawEvent(decoder, keep, ev)
+}
@@ -0,0 +1,108 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeepProjection(t *testing.T) {
+	mix := New("product", "", "")
+	mix.Keep = []string{"a"}
+
+	input := strings.NewReader(`{"event": "e", "properties": {"a": 1, "b": {"nested": [1, 2]}, "c": "c", "distinct_id": "d", "time": 1095379200}}`)
+	output := make(chan EventData, 1)
+
+	if stats, err := mix.TransformEventData(input, output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 1 {
+		t.Fatalf("expected 1 record, got %d", stats.EventsExported)
+	}
+
+	event := <-output
+
+	for _, key := range []string{"a", "event", "product", "distinct_id", "time", EventIDKey, TimestampKey} {
+		if _, ok := event[key]; !ok {
+			t.Errorf("expected %s to be kept: %v", key, event)
+		}
+	}
+
+	for _, key := range []string{"b", "c"} {
+		if _, ok := event[key]; ok {
+			t.Errorf("expected %s to be discarded: %v", key, event)
+		}
+	}
+}
+
+var heavyEvent = `{"event": "e", "properties": {"distinct_id": "d", "time": 1095379200, ` +
+	`"a": "aaaaaaaa", "b": ["b", "b", "b", "b"], "c": {"c": {"c": "c"}}, "d": "dddddddd", ` +
+	`"e": [{"e": 1}, {"e": 2}], "f": "ffffffff", "g": "gggggggg", "h": {"h": ["h", "h"]}}}`
+
+func benchmarkTransform(b *testing.B, keep []string) {
+	mix := New("product", "", "")
+	mix.Keep = keep
+
+	input := strings.NewReader(strings.Repeat(heavyEvent, b.N))
+	output := make(chan EventData, 100)
+
+	go func() {
+		for range output {
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	mix.TransformEventData(input, output)
+	close(output)
+}
+
+func BenchmarkTransformEventDataFull(b *testing.B) {
+	benchmarkTransform(b, nil)
+}
+
+func BenchmarkTransformEventDataKeep(b *testing.B) {
+	benchmarkTransform(b, []string{"a"})
+}
+
+func TestKeepProjectionValues(t *testing.T) {
+	mix := New("product", "", "")
+	mix.Keep = []string{"s", "esc", "n", "f", "t", "nil", "obj", "list", `we"ird`}
+
+	input := strings.NewReader(`{"event": "e", "properties": {
+		"skip": {"a": ["}", "]", "\"", {"b": 1}]}, "s": "plain", "esc": "a\"bé",
+		"n": -1.5e3, "f": false, "t": true, "nil": null, "obj": {"k": [1, "v"]},
+		"skip2": "x\\", "list": [], "we\"ird": 7, "skip3": 12}}`)
+	output := make(chan EventData, 1)
+
+	if _, err := mix.TransformEventData(input, output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	event := <-output
+
+	expected := map[string]interface{}{
+		"s":      "plain",
+		"esc":    "a\"bé",
+		"n":      json.Number("-1.5e3"),
+		"f":      false,
+		"t":      true,
+		"nil":    nil,
+		"obj":    map[string]interface{}{"k": []interface{}{json.Number("1"), "v"}},
+		"list":   []interface{}{},
+		`we"ird`: json.Number("7"),
+	}
+
+	for key, value := range expected {
+		if got, ok := event[key]; !ok || !reflect.DeepEqual(got, value) {
+			t.Errorf("%s: expected %#v, got %#v", key, value, got)
+		}
+	}
+
+	for _, key := range []string{"skip", "skip2", "skip3"} {
+		if _, ok := event[key]; ok {
+			t.Errorf("expected %s to be discarded", key)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRawChan(t *testing.T) {
+	lines := []string{
+		`{"event": "a", "properties": {"n": 1}}`,
+		`{"event":"b","properties":{"n":2,"x":[1, 2]}}`,
+		`{"event": "c", "properties": {"n": 3}}`,
+	}
+
+	for _, workers := range []int{0, 2} {
+		raws := make(chan []byte, 3)
+
+		mix := New("product", "", "")
+		mix.RawChan = raws
+		mix.DecodeWorkers = workers
+		mix.PreserveOrder = true
+
+		output := make(chan EventData, 3)
+
+		if _, err := mix.TransformEventData(strings.NewReader(strings.Join(lines, "\n")), output); err != nil {
+			t.Fatalf("workers=%d: raised error: %v", workers, err)
+		}
+
+		close(raws)
+		close(output)
+
+		i := 0
+		for raw := range raws {
+			event := <-output
+
+			if string(raw) != lines[i] {
+				t.Errorf("workers=%d: expected raw %s, got %s", workers, lines[i], raw)
+			}
+
+			var original rawEvent
+			if err := json.Unmarshal(raw, &original); err != nil || original.Event != event["event"] {
+				t.Errorf("workers=%d: raw %s doesn't correspond to %v", workers, raw, event)
+			}
+
+			i++
+		}
+
+		if i != 3 {
+			t.Errorf("workers=%d: expected 3 raw events, got %d", workers, i)
+		}
+	}
+}
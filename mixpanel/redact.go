@@ -0,0 +1,23 @@
+package mixpanel
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// hashProperties replaces the value of each property listed in
+// `HashProperties` with a salted SHA-256 hash of the value. Missing and nil
+// properties are left alone.
+func (m *Mixpanel) hashProperties(props map[string]interface{}) {
+	for key, salt := range m.HashProperties {
+		if value, ok := props[key]; ok && value != nil {
+			props[key] = hashValue(salt, value)
+		}
+	}
+}
+
+// hashValue returns the hex encoded SHA-256 of `salt` followed by the string
+// representation of `value`.
+func hashValue(salt string, value interface{}) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(salt+fmt.Sprintf("%v", value))))
+}
@@ -0,0 +1,49 @@
+package mixpanel
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func hashedEmail(t *testing.T, salt string) interface{} {
+	mix := New("product", "", "")
+	mix.HashProperties = map[string]string{"email": salt, "missing": salt}
+
+	input := strings.NewReader(`{"event": "a", "properties": {"email": "user@example.com", "other": "x"}}`)
+	output := make(chan EventData, 1)
+
+	if _, err := mix.TransformEventData(input, output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	event := <-output
+
+	if event["other"] != "x" {
+		t.Errorf("unlisted property was changed: %v", event["other"])
+	}
+
+	if _, ok := event["missing"]; ok {
+		t.Errorf("missing property was added: %v", event)
+	}
+
+	return event["email"]
+}
+
+func TestHashProperties(t *testing.T) {
+	first, second := hashedEmail(t, "salt"), hashedEmail(t, "salt")
+
+	expected := fmt.Sprintf("%x", sha256.Sum256([]byte("saltuser@example.com")))
+	if first != expected {
+		t.Errorf("expected %s, got %v", expected, first)
+	}
+
+	if first != second {
+		t.Errorf("hash isn't deterministic: %v != %v", first, second)
+	}
+
+	if other := hashedEmail(t, "pepper"); other == first {
+		t.Errorf("different salts gave the same hash: %v", other)
+	}
+}
@@ -0,0 +1,86 @@
+package mixpanel
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Following more redirects than this is almost certainly a loop.
+const maxRedirects = 10
+
+// client returns the HTTP client used for every request, which follows
+// redirects according to `checkRedirect` (auditing those it follows).
+func (m *Mixpanel) client() *http.Client {
+	return &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		err := m.checkRedirect(req, via)
+		if err == nil {
+			m.audit(req, 0)
+		}
+
+		return err
+	}}
+}
+
+// checkRedirect decides whether to follow a redirect (Mixpanel uses them for
+// things like region routing) from `via` to `req`.
+//
+// Go drops the `Authorization` header when a redirect crosses hosts. When the
+// destination is trusted (see `trustedRedirect`) we put it back, otherwise we
+// refuse to follow rather than failing later with a confusing 401. Signed
+// requests carry their `api_key` and signature in the URL, so we refuse
+// redirects that lose them, and those that would hand them to an untrusted
+// host.
+func (m *Mixpanel) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("%s: stopped after %d redirects", m.Product, len(via))
+	}
+
+	original := via[0]
+
+	// Go carries custom headers over to the redirect, which is only fine
+	// for hosts we'd trust with our credentials.
+	if !m.trustedRedirect(original, req) {
+		m.stripHeaders(req)
+	}
+
+	if query := original.URL.Query(); query.Get("sig") != "" || query.Get("api_key") != "" {
+		if req.URL.Query().Get("sig") != query.Get("sig") || !m.trustedRedirect(original, req) {
+			return fmt.Errorf("%s: redirect to %s: %w", m.Product, req.URL.Host, ErrUnsafeRedirect)
+		}
+	}
+
+	auth := original.Header.Get("Authorization")
+	if auth == "" || req.Header.Get("Authorization") != "" {
+		return nil
+	}
+
+	if !m.trustedRedirect(original, req) {
+		return fmt.Errorf("%s: redirect to %s: %w", m.Product, req.URL.Host, ErrUnsafeRedirect)
+	}
+
+	req.Header.Set("Authorization", auth)
+	return nil
+}
+
+// trustedRedirect reports whether credentials sent to `from` may also be sent
+// to `to`. Downgrades from HTTPS never are.
+func (m *Mixpanel) trustedRedirect(from, to *http.Request) bool {
+	if from.URL.Scheme == "https" && to.URL.Scheme != "https" {
+		return false
+	}
+
+	host := to.URL.Hostname()
+
+	if host == from.URL.Hostname() || host == "mixpanel.com" || strings.HasSuffix(host, ".mixpanel.com") {
+		return true
+	}
+
+	for _, trusted := range m.RedirectHosts {
+		if host == trusted {
+			return true
+		}
+	}
+
+	return false
+}
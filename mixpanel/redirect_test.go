@@ -0,0 +1,138 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRedirectServer 307s every request to the same path and query on
+// `target`.
+func newRedirectServer(target string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	}))
+}
+
+func TestRedirectKeepsSignature(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `{"event": "a", "properties": {}}`)
+	}))
+	defer target.Close()
+
+	redirect := newRedirectServer(target.URL)
+	defer redirect.Close()
+
+	mix := NewWithURL("product", "key", "secret", redirect.URL)
+	output := make(chan EventData, 1)
+
+	if stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 1 {
+		t.Errorf("expected 1 event, got %d", stats.EventsExported)
+	}
+}
+
+func TestRedirectKeepsAuthorization(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `{"results": [{"$distinct_id": "user0"}]}`)
+	}))
+	defer target.Close()
+
+	// Use a different host name for the redirect, so that Go would strip
+	// the credentials by itself.
+	redirect := newRedirectServer(strings.Replace(target.URL, "127.0.0.1", "localhost", 1))
+	defer redirect.Close()
+
+	mix := New("product", "", "")
+	mix.Endpoints.App = redirect.URL
+	mix.ProjectID = "123"
+	mix.ServiceAccountUser = "user"
+	mix.ServiceAccountSecret = "pass"
+
+	output := make(chan EventData, 1)
+
+	if _, err := mix.ExportPeopleBulk(context.Background(), output); !errors.Is(err, ErrUnsafeRedirect) {
+		t.Fatalf("expected ErrUnsafeRedirect for untrusted host, got %v", err)
+	}
+
+	mix.RedirectHosts = []string{"localhost"}
+
+	if num, err := mix.ExportPeopleBulk(context.Background(), output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if num != 1 {
+		t.Errorf("expected 1 profile, got %d", num)
+	}
+}
+
+func TestTrustedRedirect(t *testing.T) {
+	mix := New("product", "", "")
+
+	tests := []struct {
+		from, to string
+		trusted  bool
+	}{
+		{"https://mixpanel.com/a", "https://mixpanel.com/b", true},
+		{"https://mixpanel.com/a", "https://eu.mixpanel.com/a", true},
+		{"https://mixpanel.com/a", "http://eu.mixpanel.com/a", false},
+		{"https://mixpanel.com/a", "https://evilmixpanel.com/a", false},
+		{"https://mixpanel.com/a", "https://example.com/a", false},
+	}
+
+	for _, test := range tests {
+		from, _ := http.NewRequest("GET", test.from, nil)
+		to, _ := http.NewRequest("GET", test.to, nil)
+
+		if got := mix.trustedRedirect(from, to); got != test.trusted {
+			t.Errorf("%s -> %s: expected %v, got %v", test.from, test.to, test.trusted, got)
+		}
+	}
+}
+
+func TestRedirectSignedToUntrustedHost(t *testing.T) {
+	var leaked bool
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api_key") != "" {
+			leaked = true
+		}
+
+		fmt.Fprint(w, `{"event": "a", "properties": {}}`)
+	}))
+	defer target.Close()
+
+	redirect := newRedirectServer(strings.Replace(target.URL, "127.0.0.1", "localhost", 1))
+	defer redirect.Close()
+
+	mix := NewWithURL("product", "key", "secret", redirect.URL)
+	output := make(chan EventData, 1)
+
+	if _, err := mix.ExportDate(context.Background(), time.Now(), output, nil); !errors.Is(err, ErrUnsafeRedirect) {
+		t.Fatalf("expected ErrUnsafeRedirect for untrusted host, got %v", err)
+	} else if leaked {
+		t.Fatal("signed URL was sent to the untrusted host")
+	}
+
+	mix.RedirectHosts = []string{"localhost"}
+
+	if stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 1 {
+		t.Errorf("expected 1 event, got %d", stats.EventsExported)
+	}
+}
@@ -0,0 +1,35 @@
+package mixpanel
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// ExportYesterday exports the day before today, as seen from the timezone
+// `loc` (UTC if nil) according to the configured Clock.
+//
+// Mixpanel buckets events into days using the project's timezone, so `loc`
+// should normally be the project's timezone rather than the local one.
+// Otherwise, a job scheduled shortly after midnight might ask for a day that
+// hasn't ended yet for the project.
+func (m *Mixpanel) ExportYesterday(ctx context.Context, loc *time.Location, output chan<- EventData, moreArgs *url.Values) (Stats, error) {
+	return m.ExportDate(ctx, m.today(loc).AddDate(0, 0, -1), output, moreArgs)
+}
+
+// ExportToday exports the (incomplete) current day, as seen from the
+// timezone `loc` (UTC if nil). See `ExportYesterday`.
+func (m *Mixpanel) ExportToday(ctx context.Context, loc *time.Location, output chan<- EventData, moreArgs *url.Values) (Stats, error) {
+	return m.ExportDate(ctx, m.today(loc), output, moreArgs)
+}
+
+// today returns midnight of the current day in `loc`.
+func (m *Mixpanel) today(loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	year, month, day := m.now().In(loc).Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,52 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExportYesterdayToday(t *testing.T) {
+	var requested []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("from_date") != q.Get("to_date") {
+			t.Errorf("expected a single day, got %s to %s", q.Get("from_date"), q.Get("to_date"))
+		}
+
+		requested = append(requested, q.Get("from_date"))
+	}))
+	defer server.Close()
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	// 20:00 UTC on the 1st is already 05:00 on the 2nd in Tokyo.
+	mix.Clock = fixedClock(time.Date(2015, 3, 1, 20, 0, 0, 0, time.UTC))
+
+	output := make(chan EventData)
+	ctx := context.Background()
+
+	mix.ExportYesterday(ctx, tokyo, output, nil)
+	mix.ExportToday(ctx, tokyo, output, nil)
+	mix.ExportYesterday(ctx, nil, output, nil)
+
+	expected := []string{"2015-03-01", "2015-03-02", "2015-02-28"}
+
+	if len(requested) != len(expected) {
+		t.Fatalf("expected %d requests, got %v", len(expected), requested)
+	}
+
+	for i := range expected {
+		if requested[i] != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], requested[i])
+		}
+	}
+}
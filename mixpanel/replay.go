@@ -0,0 +1,160 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ReplayFile reads a file of raw export data (as returned by the export API,
+// one `{"event": "...", "properties": {...}}` object per line, optionally
+// gzipped) and streams it over `output` exactly as `ExportDate` would,
+// applying the same transformations and options.
+//
+// This is useful for developing and testing downstream consumers without
+// talking to Mixpanel at all. The output channel and the returned Stats are
+// the same as `ExportDate`'s, so a consumer can be pointed at either.
+//
+// A file has no day of its own, so the `ProjectTimezone` window isn't
+// applied; use `ReplayDate` for that.
+func (m *Mixpanel) ReplayFile(ctx context.Context, path string, output chan<- EventData) (Stats, error) {
+	return m.replay(ctx, path, nil, time.Time{}, output)
+}
+
+// ReplayDate is `ReplayFile` for a file holding the export of `date`. If
+// `ProjectTimezone` is set, events outside of the day are dropped just like
+// `ExportDate` would drop them.
+func (m *Mixpanel) ReplayDate(ctx context.Context, path string, date time.Time, output chan<- EventData) (Stats, error) {
+	var window *timeWindow
+	if m.ProjectTimezone != nil {
+		_, _, window = m.projectWindow(date)
+	}
+
+	return m.replay(ctx, path, window, exportDay(date, window), output)
+}
+
+// ReplayOptions control how `ReplayFileWithOptions` replays a file.
+//
+// - `RatePerSecond` paces the events sent over the output channel to about
+//   this many a second, for load testing consumers at a realistic rate. Zero
+//   sends them as fast as they are read.
+// - `Loop` replays the file this many times over. Zero (or one) replays it
+//   once, and a negative value replays it until the context is cancelled.
+type ReplayOptions struct {
+	RatePerSecond float64
+	Loop          int
+}
+
+// ReplayFileWithOptions is `ReplayFile`, paced and repeated as set by
+// `opts`. The returned Stats cover every pass over the file.
+func (m *Mixpanel) ReplayFileWithOptions(ctx context.Context, path string, output chan<- EventData, opts ReplayOptions) (Stats, error) {
+	if opts.RatePerSecond <= 0 {
+		return m.replayLoop(ctx, path, opts.Loop, output)
+	}
+
+	paced := make(chan EventData)
+	sent := make(chan int, 1)
+
+	go func() {
+		sent <- relay(ctx, paced, output, pacer(ctx, opts.RatePerSecond))
+	}()
+
+	stats, err := m.replayLoop(ctx, path, opts.Loop, paced)
+	close(paced)
+
+	// Events dropped by the pacer once the context is done never made it to
+	// `output`.
+	stats.EventsExported = <-sent
+
+	return stats, err
+}
+
+// replayLoop replays `path` `loop` times over (see `ReplayOptions`),
+// totalling up the stats of each pass.
+func (m *Mixpanel) replayLoop(ctx context.Context, path string, loop int, output chan<- EventData) (Stats, error) {
+	var total Stats
+
+	for pass := 0; loop < 0 || pass < loop || pass == 0; pass++ {
+		stats, err := m.replay(ctx, path, nil, time.Time{}, output)
+
+		total.add(stats)
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// pacer returns a `relay` callback which holds each event back until it's
+// due at `rate` events a second, counted from the first. Scheduling against
+// the start rather than sleeping a fixed interval per event keeps the rate
+// accurate even when timers fire late, as they do at high rates.
+func pacer(ctx context.Context, rate float64) func(EventData) bool {
+	var start time.Time
+	n := 0
+
+	return func(EventData) bool {
+		if n == 0 {
+			start = time.Now()
+		}
+
+		due := start.Add(time.Duration(float64(n) / rate * float64(time.Second)))
+		n++
+
+		wait := time.Until(due)
+		if wait <= 0 {
+			return ctx.Err() == nil
+		}
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// replay does the work of `ReplayFile`, dropping events outside of `window`
+// (if non-nil). `day` is the day the file is the export of, if known.
+func (m *Mixpanel) replay(ctx context.Context, path string, window *timeWindow, day time.Time, output chan<- EventData) (Stats, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: replay failed: %w", m.Product, err)
+	}
+
+	defer fp.Close()
+
+	input, err := maybeGunzip(fp)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: replay failed: %w", m.Product, err)
+	}
+
+	ctx, done := m.startProgress(ctx)
+	defer done()
+
+	// Cancelling the context should stop the replay the same way it would
+	// abort a download, so fail reads once it's done.
+	return m.transform(ctx, &contextReader{ctx: ctx, r: input}, output, window, day)
+}
+
+// contextReader fails reads with the context's error once it is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
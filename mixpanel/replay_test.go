@@ -0,0 +1,143 @@
+package mixpanel
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReplayFile(t *testing.T) {
+	mix := New("product", "", "")
+	mix.Keep = []string{"page"}
+
+	output := make(chan EventData, 3)
+
+	stats, err := mix.ReplayFile(context.Background(), "testdata/events.json", output)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 3 {
+		t.Fatalf("expected 3 records, got %d", stats.EventsExported)
+	}
+
+	close(output)
+
+	expected := []struct {
+		Event, DistinctID string
+		Page              interface{}
+	}{
+		{"Signed Up", "user1", nil},
+		{"Viewed Page", "user1", "/home"},
+		{"Viewed Page", "user2", "/pricing"},
+	}
+
+	i := 0
+	for event := range output {
+		e := expected[i]
+
+		if event["event"] != e.Event || event["distinct_id"] != e.DistinctID || event["page"] != e.Page {
+			t.Errorf("expected %+v, got %v", e, event)
+		}
+
+		if _, ok := event["plan"]; ok {
+			t.Errorf("expected options to be applied, got %v", event)
+		}
+
+		i++
+	}
+}
+
+func TestReplayFileMissing(t *testing.T) {
+	mix := New("product", "", "")
+	output := make(chan EventData)
+
+	if _, err := mix.ReplayFile(context.Background(), "testdata/nope.json", output); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestReplayDate(t *testing.T) {
+	mix := New("product", "", "")
+	mix.ProjectTimezone = time.UTC
+
+	// In this zone the fixture's events, a minute apart from midnight UTC,
+	// straddle midnight at 00:01:30 UTC.
+	loc := time.FixedZone("X", -90)
+
+	for _, tc := range []struct {
+		date     time.Time
+		expected int
+	}{
+		{time.Date(2013, 12, 31, 0, 0, 0, 0, loc), 2},
+		{time.Date(2014, 1, 1, 0, 0, 0, 0, loc), 1},
+	} {
+		output := make(chan EventData, 3)
+
+		stats, err := mix.ReplayDate(context.Background(), "testdata/events.json", tc.date, output)
+		if err != nil {
+			t.Fatalf("raised error: %v", err)
+		} else if stats.EventsExported != tc.expected || len(output) != tc.expected {
+			t.Errorf("%s: expected %d events, got %d", tc.date, tc.expected, stats.EventsExported)
+		}
+	}
+
+	// Without a day there's nothing to filter to.
+	if stats, err := mix.ReplayFile(context.Background(), "testdata/events.json", make(chan EventData, 3)); err != nil || stats.EventsExported != 3 {
+		t.Errorf("expected all 3 events, got %d (%v)", stats.EventsExported, err)
+	}
+}
+
+func TestReplayFileRate(t *testing.T) {
+	mix := New("product", "", "")
+	output := make(chan EventData)
+
+	const rate = 200
+
+	var times []time.Time
+	done := make(chan struct{})
+
+	go func() {
+		for range output {
+			times = append(times, time.Now())
+		}
+		close(done)
+	}()
+
+	// 3 events, 20 times over.
+	stats, err := mix.ReplayFileWithOptions(context.Background(), "testdata/events.json", output, ReplayOptions{RatePerSecond: rate, Loop: 20})
+	close(output)
+	<-done
+
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 60 || len(times) != 60 {
+		t.Fatalf("expected 60 events, got %d (received %d)", stats.EventsExported, len(times))
+	}
+
+	elapsed := times[len(times)-1].Sub(times[0]).Seconds()
+	measured := float64(len(times)-1) / elapsed
+
+	if measured > rate*1.1 || measured < rate*0.5 {
+		t.Errorf("expected about %d events a second, got %.1f", rate, measured)
+	}
+}
+
+func TestReplayFileLoopCancel(t *testing.T) {
+	mix := New("product", "", "")
+	output := make(chan EventData)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			<-output
+		}
+		cancel()
+	}()
+
+	stats, err := mix.ReplayFileWithOptions(ctx, "testdata/events.json", output, ReplayOptions{Loop: -1})
+	if err == nil {
+		t.Fatal("expected cancelling to stop an endless replay with an error")
+	} else if stats.EventsExported < 10 {
+		t.Errorf("expected at least 10 events, got %d", stats.EventsExported)
+	}
+}
@@ -0,0 +1,72 @@
+package mixpanel
+
+// The reserved properties Mixpanel attaches to events and profiles itself,
+// or gives a special meaning, so that they can be referred to (in `Keep`,
+// `HashProperties`, `IdentityPrecedence` and the like) without mistyping
+// them. See `ReservedProperties`.
+const (
+	// Identity and deduplication.
+	PropDistinctID = "$distinct_id"
+	PropUserID     = "$user_id"
+	PropDeviceID   = "$device_id"
+	PropInsertID   = "$insert_id"
+
+	// Location, from the IP address the event was sent from.
+	PropCity        = "$city"
+	PropRegion      = "$region"
+	PropCountryCode = "mp_country_code"
+
+	// Device and client.
+	PropOS              = "$os"
+	PropOSVersion       = "$os_version"
+	PropBrowser         = "$browser"
+	PropDevice          = "$device"
+	PropScreenHeight    = "$screen_height"
+	PropScreenWidth     = "$screen_width"
+	PropCurrentURL      = "$current_url"
+	PropReferrer        = "$referrer"
+	PropReferringDomain = "$referring_domain"
+	PropLib             = "mp_lib"
+	PropLibVersion      = "$lib_version"
+
+	// Ingestion.
+	PropToken            = "$token"
+	PropImportTime       = "$mp_api_timestamp_ms"
+	PropProcessingTimeMS = "mp_processing_time_ms"
+
+	// People profiles.
+	PropEmail        = "$email"
+	PropName         = "$name"
+	PropCreated      = "$created"
+	PropLastSeen     = "$last_seen"
+	PropTransactions = TransactionsKey
+
+	// Within each of a profile's `$transactions`.
+	PropAmount          = "$amount"
+	PropTransactionTime = "$time"
+)
+
+// reservedProperties backs `ReservedProperties`.
+var reservedProperties = []string{
+	PropDistinctID, PropUserID, PropDeviceID, PropInsertID,
+	PropCity, PropRegion, PropCountryCode,
+	PropOS, PropOSVersion, PropBrowser, PropDevice, PropScreenHeight, PropScreenWidth,
+	PropCurrentURL, PropReferrer, PropReferringDomain, PropLib, PropLibVersion,
+	PropToken, PropImportTime, PropProcessingTimeMS,
+	PropEmail, PropName, PropCreated, PropLastSeen, PropTransactions,
+	PropAmount, PropTransactionTime,
+}
+
+// ReservedProperties returns the set of the reserved properties there are
+// `Prop` constants for. It's a new map every time, free to be modified.
+//
+// Mixpanel reserves more than these: every `$` and `mp_` prefixed property
+// is its own (see `IncludeImplicit`).
+func ReservedProperties() map[string]bool {
+	set := make(map[string]bool, len(reservedProperties))
+	for _, key := range reservedProperties {
+		set[key] = true
+	}
+
+	return set
+}
@@ -0,0 +1,40 @@
+package mixpanel
+
+import "testing"
+
+func TestReservedProperties(t *testing.T) {
+	reserved := ReservedProperties()
+
+	for _, key := range []string{"$city", "$insert_id", "$distinct_id", "$user_id", "$device_id", "mp_country_code", "$transactions"} {
+		if !reserved[key] {
+			t.Errorf("expected %s to be reserved", key)
+		}
+	}
+
+	for key := range reserved {
+		if !isImplicit(key) {
+			t.Errorf("%s isn't `$` or `mp_` prefixed", key)
+		}
+	}
+
+	// Each call gets a set of its own.
+	delete(reserved, PropCity)
+	if !ReservedProperties()[PropCity] {
+		t.Error("expected modifying the set not to affect the next")
+	}
+
+	// The internal logic goes by the same names.
+	for _, key := range []string{PropInsertID, PropDistinctID} {
+		if !ProtectedProperties[key] {
+			t.Errorf("expected %s to be protected", key)
+		}
+	}
+
+	if precedence := DefaultIdentityPrecedence; precedence[0] != PropUserID || precedence[len(precedence)-1] != PropDeviceID {
+		t.Errorf("unexpected identity precedence %v", precedence)
+	}
+
+	if profile := ProfileTransactions(EventData{PropDistinctID: "a", PropTransactions: []interface{}{map[string]interface{}{PropAmount: 1}}}); len(profile) != 1 || profile[0]["distinct_id"] != "a" {
+		t.Errorf("unexpected transactions %v", profile)
+	}
+}
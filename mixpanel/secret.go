@@ -0,0 +1,58 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultSecretTTL is how long a secret from a `SecretProvider` is used for
+// before it's fetched again, when `SecretTTL` is zero.
+const DefaultSecretTTL = 5 * time.Minute
+
+// SecretProvider supplies the API secret at request time, for secrets kept
+// in a secrets manager (Vault, SSM, ...) rather than in the configuration,
+// and rotated there.
+type SecretProvider interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+// secretTTL returns the configured `SecretTTL`, using the default if unset.
+func (m *Mixpanel) secretTTL() time.Duration {
+	if m.SecretTTL == 0 {
+		return DefaultSecretTTL
+	}
+
+	return m.SecretTTL
+}
+
+// apiSecret returns the secret to sign a request for `endpoint` with: the
+// static `Secret`, or that of the `SecretProvider`, cached for `SecretTTL`.
+func (m *Mixpanel) apiSecret(ctx context.Context, endpoint string) (string, error) {
+	if m.SecretProvider == nil {
+		if m.Secret == "" {
+			return "", fmt.Errorf("%s: %s: %w", m.Product, endpoint, ErrMissingSecret)
+		}
+
+		return m.Secret, nil
+	}
+
+	m.secretMu.Lock()
+	defer m.secretMu.Unlock()
+
+	now := m.now()
+	if m.cachedSecret != "" && now.Before(m.secretExpires) {
+		return m.cachedSecret, nil
+	}
+
+	secret, err := m.SecretProvider.Secret(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s: fetching secret failed: %w", m.Product, endpoint, err)
+	} else if secret == "" {
+		return "", fmt.Errorf("%s: %s: %w", m.Product, endpoint, ErrMissingSecret)
+	}
+
+	m.cachedSecret, m.secretExpires = secret, now.Add(m.secretTTL())
+
+	return secret, nil
+}
@@ -0,0 +1,87 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rotatingSecrets is a SecretProvider handing out a new secret every time
+// it's asked.
+type rotatingSecrets struct {
+	secrets []string
+	calls   int
+}
+
+func (r *rotatingSecrets) Secret(ctx context.Context) (string, error) {
+	if r.calls == len(r.secrets) {
+		return "", errors.New("out of secrets")
+	}
+
+	r.calls++
+	return r.secrets[r.calls-1], nil
+}
+
+func TestSecretProvider(t *testing.T) {
+	var queries []url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	clock := &manualClock{t: time.Unix(1388534400, 0)}
+	provider := &rotatingSecrets{secrets: []string{"old", "new"}}
+
+	mix := NewWithURL("product", "key", "", server.URL)
+	mix.Clock = clock
+	mix.SecretProvider = provider
+	mix.SecretTTL = time.Minute
+
+	query := func() {
+		var names []string
+		if err := mix.query(context.Background(), "events/names", mix.baseArgs(), &names); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+	}
+
+	// The secret is cached for the TTL, then fetched again.
+	query()
+	clock.Advance(30 * time.Second)
+	query()
+	clock.Advance(time.Minute)
+	query()
+
+	if provider.calls != 2 {
+		t.Errorf("expected the secret to be fetched twice, got %d", provider.calls)
+	}
+
+	for i, secret := range []string{"old", "old", "new"} {
+		signed := queries[i]
+		sig := signed.Get("sig")
+
+		mix.addSignature(&signed, secret)
+		if signed.Get("sig") != sig {
+			t.Errorf("request %d: expected to be signed with %q", i, secret)
+		}
+	}
+
+	// Failing to fetch a secret fails the request.
+	clock.Advance(time.Minute)
+
+	var names []string
+	if err := mix.query(context.Background(), "events/names", mix.baseArgs(), &names); err == nil {
+		t.Error("expected the provider's error")
+	}
+
+	// A copy with a secret of its own doesn't consult the provider.
+	static := mix.WithSecret("static")
+	if secret, err := static.apiSecret(context.Background(), "events/names"); err != nil || secret != "static" {
+		t.Errorf("expected the static secret, got %q (%v)", secret, err)
+	}
+}
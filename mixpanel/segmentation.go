@@ -0,0 +1,178 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Aggregations `SegmentationAggregate` can compute over a numeric property.
+const (
+	AggregateSum     = "sum"
+	AggregateAverage = "average"
+)
+
+// SeriesPoint is the value of a series on a single day (`YYYY-MM-DD`).
+type SeriesPoint struct {
+	Date  string
+	Value float64
+}
+
+// NumericBucket is the series of event counts for one range of values of a
+// numeric segmentation, labelled the way Mixpanel labels it (`"10 - 20"`).
+type NumericBucket struct {
+	Label  string
+	Points []SeriesPoint
+}
+
+// NumericSegmentation is the result of `SegmentationNumeric`: the days
+// covered and a series per bucket, ordered by the start of its range.
+type NumericSegmentation struct {
+	Dates   []string
+	Buckets []NumericBucket
+}
+
+// SegmentationNumeric counts the occurrences of `event` from `from` through
+// `to` (inclusive) per day, segmented into buckets by the numeric expression
+// `on` (such as `properties["amount"]`).
+//
+// Mixpanel picks the buckets itself unless `buckets` are given, in which case
+// they are sent as the boundaries to bucket on.
+func (m *Mixpanel) SegmentationNumeric(ctx context.Context, event, on string, buckets []float64, from, to time.Time) (NumericSegmentation, error) {
+	args := m.segmentationArgs(EndpointSegmentationNumeric, event, on, from, to)
+
+	if len(buckets) > 0 {
+		encoded, err := json.Marshal(buckets)
+		if err != nil {
+			return NumericSegmentation{}, fmt.Errorf("%s: encoding buckets failed: %w", m.Product, err)
+		}
+
+		args.Set("buckets", string(encoded))
+	}
+
+	return m.segmentationSeries(ctx, EndpointSegmentationNumeric, args)
+}
+
+// segmentationSeries runs a segmentation query, and collects the series of
+// each bucket (or segment) of the response.
+func (m *Mixpanel) segmentationSeries(ctx context.Context, endpoint string, args url.Values) (NumericSegmentation, error) {
+	// Response has the form:
+	//   {"data": {"series": ["YYYY-MM-DD"], "values": {"10 - 20": {"YYYY-MM-DD": 123}}}}
+	var resp struct {
+		Data struct {
+			Series []string
+			Values map[string]map[string]float64
+		}
+	}
+
+	if err := m.query(ctx, endpoint, args, &resp); err != nil {
+		return NumericSegmentation{}, err
+	}
+
+	result := NumericSegmentation{Dates: resp.Data.Series}
+	sort.Strings(result.Dates)
+
+	for label, byDay := range resp.Data.Values {
+		bucket := NumericBucket{Label: label, Points: make([]SeriesPoint, 0, len(result.Dates))}
+
+		for _, date := range result.Dates {
+			bucket.Points = append(bucket.Points, SeriesPoint{Date: date, Value: byDay[date]})
+		}
+
+		result.Buckets = append(result.Buckets, bucket)
+	}
+
+	sort.Slice(result.Buckets, func(i, j int) bool {
+		return bucketLess(result.Buckets[i].Label, result.Buckets[j].Label)
+	})
+
+	return result, nil
+}
+
+// SegmentationAggregate computes the daily `aggregation` (`AggregateSum` or
+// `AggregateAverage`) of the numeric expression `on` over the occurrences of
+// `event` from `from` through `to` (inclusive), ordered by day.
+func (m *Mixpanel) SegmentationAggregate(ctx context.Context, event, on, aggregation string, from, to time.Time) ([]SeriesPoint, error) {
+	var endpoint string
+
+	switch aggregation {
+	case AggregateSum:
+		endpoint = EndpointSegmentationSum
+	case AggregateAverage:
+		endpoint = EndpointSegmentationAverage
+	default:
+		return nil, fmt.Errorf("%s: unknown segmentation aggregation %q", m.Product, aggregation)
+	}
+
+	args := m.segmentationArgs(endpoint, event, on, from, to)
+
+	// Response has the form:
+	//   {"status": "ok", "results": {"YYYY-MM-DD": 12.5}}
+	var resp struct {
+		Results map[string]float64
+	}
+
+	if err := m.query(ctx, endpoint, args, &resp); err != nil {
+		return nil, err
+	}
+
+	points := make([]SeriesPoint, 0, len(resp.Results))
+	for date, value := range resp.Results {
+		points = append(points, SeriesPoint{Date: date, Value: value})
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Date < points[j].Date })
+
+	return points, nil
+}
+
+// segmentationArgs builds the arguments common to the segmentation
+// endpoints. `on` is left out if empty.
+func (m *Mixpanel) segmentationArgs(endpoint, event, on string, from, to time.Time) url.Values {
+	args := m.baseArgs()
+	setDateRange(args, endpoint, from, to)
+
+	args.Set("event", event)
+	if on != "" {
+		args.Set("on", on)
+	}
+	args.Set("unit", "day")
+
+	return args
+}
+
+// bucketLess orders bucket labels by the start of their range, with any that
+// don't start with a number last.
+func bucketLess(a, b string) bool {
+	startA, errA := bucketStart(a)
+	startB, errB := bucketStart(b)
+
+	switch {
+	case errA != nil && errB != nil:
+		return a < b
+	case errA != nil:
+		return false
+	case errB != nil:
+		return true
+	case startA != startB:
+		return startA < startB
+	}
+
+	return a < b
+}
+
+// bucketStart parses the number a bucket label such as `"1,000 - 2,000"`
+// starts with.
+func bucketStart(label string) (float64, error) {
+	start := label
+	if i := strings.Index(label, " - "); i >= 0 {
+		start = label[:i]
+	}
+
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(start), ",", "", -1), 64)
+}
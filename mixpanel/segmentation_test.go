@@ -0,0 +1,105 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSegmentationNumeric(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		if r.URL.Path != "/segmentation/numeric" {
+			http.NotFound(w, r)
+			return
+		} else if query.Get("event") != "purchase" || query.Get("on") != `properties["amount"]` || query.Get("buckets") != "[0,10,100]" {
+			t.Errorf("unexpected arguments %v", query)
+		} else if query.Get("from_date") != "2014-01-01" || query.Get("to_date") != "2014-01-02" {
+			t.Errorf("unexpected range %v", query)
+		}
+
+		fmt.Fprint(w, `{
+			"data": {
+				"series": ["2014-01-02", "2014-01-01"],
+				"values": {
+					"10 - 100": {"2014-01-01": 3},
+					"1,000 - 2,000": {"2014-01-01": 1, "2014-01-02": 2},
+					"0 - 10": {"2014-01-01": 5, "2014-01-02": 7}
+				}
+			},
+			"legend_size": 3
+		}`)
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	from, _ := time.Parse("2006-01-02", "2014-01-01")
+	to, _ := time.Parse("2006-01-02", "2014-01-02")
+
+	result, err := mix.SegmentationNumeric(context.Background(), "purchase", `properties["amount"]`, []float64{0, 10, 100}, from, to)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := NumericSegmentation{
+		Dates: []string{"2014-01-01", "2014-01-02"},
+		Buckets: []NumericBucket{
+			{Label: "0 - 10", Points: []SeriesPoint{{"2014-01-01", 5}, {"2014-01-02", 7}}},
+			{Label: "10 - 100", Points: []SeriesPoint{{"2014-01-01", 3}, {"2014-01-02", 0}}},
+			{Label: "1,000 - 2,000", Points: []SeriesPoint{{"2014-01-01", 1}, {"2014-01-02", 2}}},
+		},
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestSegmentationAggregate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/segmentation/sum":
+			fmt.Fprint(w, `{"status": "ok", "computed_at": "2014-01-03T00:00:00", "results": {"2014-01-02": 12.5, "2014-01-01": 376}}`)
+		case "/segmentation/average":
+			fmt.Fprint(w, `{"status": "ok", "results": {"2014-01-01": 4.25}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	from, _ := time.Parse("2006-01-02", "2014-01-01")
+	to, _ := time.Parse("2006-01-02", "2014-01-02")
+
+	sum, err := mix.SegmentationAggregate(context.Background(), "purchase", `properties["amount"]`, AggregateSum, from, to)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if expected := []SeriesPoint{{"2014-01-01", 376}, {"2014-01-02", 12.5}}; !reflect.DeepEqual(sum, expected) {
+		t.Errorf("expected %v, got %v", expected, sum)
+	}
+
+	average, err := mix.SegmentationAggregate(context.Background(), "purchase", `properties["amount"]`, AggregateAverage, from, to)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if expected := []SeriesPoint{{"2014-01-01", 4.25}}; !reflect.DeepEqual(average, expected) {
+		t.Errorf("expected %v, got %v", expected, average)
+	}
+
+	if _, err := mix.SegmentationAggregate(context.Background(), "purchase", "x", "median", from, to); err == nil {
+		t.Error("expected an error for an unknown aggregation")
+	}
+}
@@ -0,0 +1,143 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// MaxClockSkew is the largest difference between our clock and Mixpanel's
+// that `SelfTest` tolerates. Requests are signed with an expiry based on our
+// clock, so a badly skewed clock gets them rejected.
+const MaxClockSkew = time.Minute
+
+// Names of the checks run by `SelfTest`.
+const (
+	CheckConfig      = "config"
+	CheckCredentials = "credentials"
+	CheckClock       = "clock"
+)
+
+// SelfTestCheck is the outcome of a single `SelfTest` check.
+type SelfTestCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// SelfTestReport collects the outcome of every `SelfTest` check, along with
+// the measured round trip latency to Mixpanel and how far our clock is ahead
+// of Mixpanel's (negative if behind).
+type SelfTestReport struct {
+	Checks    []SelfTestCheck
+	Latency   time.Duration
+	ClockSkew time.Duration
+}
+
+// OK reports whether every check passed.
+func (r *SelfTestReport) OK() bool {
+	for _, check := range r.Checks {
+		if !check.OK {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *SelfTestReport) add(name string, ok bool, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, SelfTestCheck{Name: name, OK: ok, Detail: fmt.Sprintf(format, args...)})
+}
+
+// SelfTest checks that everything is in place for an export to succeed,
+// meant to be run before a scheduled export:
+//
+// - the configuration is complete
+// - the credentials are accepted, by making a cheap query API request
+// - the clock is within `MaxClockSkew` of the `Date` Mixpanel responds with
+//
+// The report is always returned, and the error is non-nil if any check
+// failed.
+func (m *Mixpanel) SelfTest(ctx context.Context) (*SelfTestReport, error) {
+	report := &SelfTestReport{}
+
+	if m.Key == "" || (m.Secret == "" && m.SecretProvider == nil) {
+		report.add(CheckConfig, false, "missing API key or secret")
+	} else if err := m.checkEndpoints(); err != nil {
+		report.add(CheckConfig, false, "%s", err)
+	} else {
+		report.add(CheckConfig, true, "ok")
+	}
+
+	if report.OK() {
+		m.selfTestRequest(ctx, report)
+	}
+
+	if !report.OK() {
+		return report, fmt.Errorf("%s: self test failed", m.Product)
+	}
+
+	return report, nil
+}
+
+// checkEndpoints checks that each of the `Endpoints` is a valid URL.
+func (m *Mixpanel) checkEndpoints() error {
+	for _, base := range m.Endpoints.urls() {
+		if _, err := url.Parse(base); err != nil {
+			return fmt.Errorf("invalid endpoint URL: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// selfTestRequest makes the request behind the credential and clock checks.
+func (m *Mixpanel) selfTestRequest(ctx context.Context, report *SelfTestReport) {
+	args := m.baseArgs()
+	args.Set("type", "general")
+	args.Set("limit", "1")
+
+	req, err := m.newRequest(ctx, "GET", "events/names", args)
+	if err != nil {
+		report.add(CheckCredentials, false, "%s", err)
+		return
+	}
+
+	start := m.now()
+	resp, err := m.do(ctx, req)
+	report.Latency = m.now().Sub(start)
+
+	if err != nil {
+		report.add(CheckCredentials, false, "request failed: %s", err)
+		return
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		report.add(CheckCredentials, true, "ok (%s round trip)", report.Latency)
+	} else if err := responseError(resp); errors.Is(err, ErrUnauthorized) {
+		report.add(CheckCredentials, false, "rejected: %s", err)
+	} else {
+		report.add(CheckCredentials, false, "%s", err)
+	}
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		report.add(CheckClock, false, "no usable Date header in response")
+		return
+	}
+
+	// The Date header only has second resolution, and was generated some
+	// time during the round trip.
+	report.ClockSkew = m.now().Add(-report.Latency / 2).Sub(date).Truncate(time.Second)
+
+	if skew := report.ClockSkew; skew > MaxClockSkew || skew < -MaxClockSkew {
+		report.add(CheckClock, false, "clock is off by %s", skew)
+	} else {
+		report.add(CheckClock, true, "ok (off by %s)", skew)
+	}
+}
@@ -0,0 +1,125 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSelfTestServer responds to everything with `status` and a `Date` header
+// of `date`.
+func newSelfTestServer(status int, date time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", date.UTC().Format(http.TimeFormat))
+		w.WriteHeader(status)
+
+		if status == http.StatusOK {
+			fmt.Fprint(w, `["a"]`)
+		} else {
+			fmt.Fprint(w, `{"error": "nope"}`)
+		}
+	}))
+}
+
+// checkStatus returns whether the named check passed, failing the test if it
+// wasn't run.
+func checkStatus(t *testing.T, report *SelfTestReport, name string) bool {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check.OK
+		}
+	}
+
+	t.Fatalf("check %s not run: %+v", name, report.Checks)
+	return false
+}
+
+func TestSelfTest(t *testing.T) {
+	now := time.Date(2014, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	server := newSelfTestServer(http.StatusOK, now)
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+	mix.Clock = fixedClock(now)
+
+	report, err := mix.SelfTest(context.Background())
+	if err != nil {
+		t.Fatalf("raised error: %v (%+v)", err, report.Checks)
+	} else if report.ClockSkew != 0 {
+		t.Errorf("expected no skew, got %s", report.ClockSkew)
+	}
+}
+
+func TestSelfTestClockSkew(t *testing.T) {
+	now := time.Date(2014, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	server := newSelfTestServer(http.StatusOK, now.Add(-5*time.Minute))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+	mix.Clock = fixedClock(now)
+
+	report, err := mix.SelfTest(context.Background())
+	if err == nil {
+		t.Error("expected error")
+	}
+
+	if report.ClockSkew != 5*time.Minute {
+		t.Errorf("expected 5m skew, got %s", report.ClockSkew)
+	}
+
+	if !checkStatus(t, report, CheckCredentials) || checkStatus(t, report, CheckClock) {
+		t.Errorf("expected only the clock check to fail: %+v", report.Checks)
+	}
+}
+
+func TestSelfTestBadCredentials(t *testing.T) {
+	server := newSelfTestServer(http.StatusUnauthorized, time.Now())
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	report, err := mix.SelfTest(context.Background())
+	if err == nil || checkStatus(t, report, CheckCredentials) {
+		t.Errorf("expected credentials check to fail: %+v", report.Checks)
+	}
+
+	mix.Secret = ""
+	if report, _ := mix.SelfTest(context.Background()); checkStatus(t, report, CheckConfig) {
+		t.Errorf("expected config check to fail: %+v", report.Checks)
+	}
+}
+
+func TestSelfTestErrorBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": "Request has expired"}`)
+	}))
+	defer server.Close()
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+
+	report, _ := mix.SelfTest(context.Background())
+
+	for _, check := range report.Checks {
+		if check.Name != CheckCredentials {
+			continue
+		}
+
+		// The message only comes from the body, so it has to be read
+		// before the body is closed.
+		if check.OK || !strings.Contains(check.Detail, "Request has expired") || !strings.Contains(check.Detail, "system clock") {
+			t.Errorf("expected the signature error from the body, got %q", check.Detail)
+		}
+	}
+}
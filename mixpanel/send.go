@@ -0,0 +1,45 @@
+package mixpanel
+
+import "context"
+
+// sendEvent sends `event` to `output`, unless `ctx` is done first. Without
+// this, a consumer that stops reading would leave the export blocked forever.
+func sendEvent(ctx context.Context, output chan<- EventData, event EventData) error {
+	select {
+	case output <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sendRaw is `sendEvent` for the raw bytes sent to `RawChan`.
+func sendRaw(ctx context.Context, output chan<- []byte, raw []byte) error {
+	select {
+	case output <- raw:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// relay passes everything from `input` on to `output` until `input` is
+// closed, calling `each` (if non-nil) on each record first and passing on
+// only those it returns true for. Once `ctx` is done, records are drained
+// without being passed on, so that neither side is left blocked. Returns the
+// number of records passed on.
+func relay(ctx context.Context, input <-chan EventData, output chan<- EventData, each func(EventData) bool) int {
+	sent := 0
+
+	for record := range input {
+		if ctx.Err() != nil || (each != nil && !each(record)) {
+			continue
+		}
+
+		if sendEvent(ctx, output, record) == nil {
+			sent++
+		}
+	}
+
+	return sent
+}
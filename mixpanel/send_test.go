@@ -0,0 +1,87 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitGoroutines waits for the number of goroutines to drop back to `n`,
+// failing if it doesn't.
+func waitGoroutines(t *testing.T, n int) {
+	deadline := time.Now().Add(2 * time.Second)
+
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("leaked %d goroutines:\n%s", runtime.NumGoroutine()-n, buf[:runtime.Stack(buf, true)])
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExportAbandonedConsumer(t *testing.T) {
+	body := numberedEvents(5000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/engage") {
+			fmt.Fprint(w, `{"results": []}`)
+			return
+		}
+
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	exports := map[string]func(*Mixpanel, context.Context, chan<- EventData) error{
+		"ExportDate": func(mix *Mixpanel, ctx context.Context, output chan<- EventData) error {
+			_, err := mix.ExportDate(ctx, time.Now(), output, nil)
+			return err
+		},
+		"ExportAll": func(mix *Mixpanel, ctx context.Context, output chan<- EventData) error {
+			_, err := mix.ExportAll(ctx, time.Now(), output, ExportAllOptions{})
+			return err
+		},
+	}
+
+	for name, export := range exports {
+		for _, workers := range []int{1, 4} {
+			mix := NewWithURL("product", "key", "secret", server.URL)
+			mix.Endpoints.Query = server.URL
+			mix.DecodeWorkers = workers
+
+			before := runtime.NumGoroutine()
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			// Nobody reads this past the first event.
+			output := make(chan EventData)
+			returned := make(chan error, 1)
+
+			go func() {
+				returned <- export(mix, ctx, output)
+			}()
+
+			<-output
+			cancel()
+
+			select {
+			case err := <-returned:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("%s/%d: expected context.Canceled, got %v", name, workers, err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s/%d: export blocked on an abandoned consumer", name, workers)
+			}
+
+			waitGoroutines(t, before)
+		}
+	}
+}
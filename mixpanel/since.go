@@ -0,0 +1,107 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrNoProjectTimezone is returned by `ExportSince` when `ProjectTimezone`
+// isn't set, since it can't tell which project days a cursor falls in
+// without it.
+var ErrNoProjectTimezone = errors.New("ProjectTimezone must be set")
+
+// Cursor records how far an incremental export (see `ExportSince`) has got.
+//
+// - `Time` is the time of the latest event seen.
+// - `InsertIDs` holds the `$insert_id` of every event seen at exactly `Time`.
+//   Event times only have second resolution, so those events will be part of
+//   the next window too, and are skipped there.
+type Cursor struct {
+	Time      time.Time
+	InsertIDs map[string]bool
+}
+
+// ExportSince exports every event at or after `cursor.Time`, up to now, and
+// returns the cursor to pass to the next call. A zero cursor exports all of
+// the project's today.
+//
+// The raw export API only works in whole project days, so each call downloads
+// every project day from the cursor's onwards and discards what has already
+// been seen. Which day that is depends on the project's timezone, so
+// `ProjectTimezone` has to be set; otherwise `ErrNoProjectTimezone` is
+// returned.
+// Events at the cursor's time are deduplicated by `$insert_id`; those without
+// one can't be, and may be sent twice.
+func (m *Mixpanel) ExportSince(ctx context.Context, cursor Cursor, output chan<- EventData, moreArgs *url.Values) (Cursor, Stats, error) {
+	var stats Stats
+
+	tz := m.ProjectTimezone
+	if tz == nil {
+		return cursor, stats, fmt.Errorf("%s: ExportSince: %w", m.Product, ErrNoProjectTimezone)
+	}
+
+	next := Cursor{Time: cursor.Time, InsertIDs: make(map[string]bool)}
+	for id := range cursor.InsertIDs {
+		next.InsertIDs[id] = true
+	}
+
+	// Dates in the project's timezone are exported as exactly that
+	// project day.
+	start := m.today(tz)
+	if !cursor.Time.IsZero() {
+		year, month, day := cursor.Time.In(tz).Date()
+		start = time.Date(year, month, day, 0, 0, 0, 0, tz)
+	}
+
+	// Events already seen are dropped as they're decoded, so they never
+	// reach `RawChan` and the like.
+	var mu sync.Mutex
+	ctx = withFilter(ctx, &eventFilter{keep: func(ev *rawEvent) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return m.advance(cursor, &next, EventData(ev.Properties))
+	}})
+
+	for date := start; !date.After(m.today(tz)); date = date.AddDate(0, 0, 1) {
+		dayStats, err := m.ExportDate(ctx, date, output, moreArgs)
+
+		stats.add(dayStats)
+
+		if err != nil {
+			return cursor, stats, err
+		}
+	}
+
+	return next, stats, nil
+}
+
+// advance reports whether `event` is new relative to `cursor`, moving `next`
+// along if it is.
+func (m *Mixpanel) advance(cursor Cursor, next *Cursor, event EventData) bool {
+	t, ok := EventTime(event)
+	if !ok || t.Before(cursor.Time) {
+		return false
+	}
+
+	id, _ := event[PropInsertID].(string)
+
+	if t.Equal(cursor.Time) && id != "" && cursor.InsertIDs[id] {
+		return false
+	}
+
+	if t.After(next.Time) {
+		next.Time = t
+		next.InsertIDs = make(map[string]bool)
+	}
+
+	if t.Equal(next.Time) && id != "" {
+		next.InsertIDs[id] = true
+	}
+
+	return true
+}
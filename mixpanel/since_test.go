@@ -0,0 +1,173 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExportSince(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		body string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	event := func(id string, time int64) string {
+		return fmt.Sprintf(`{"event": "e", "properties": {"$insert_id": "%s", "time": %d}}`, id, time)
+	}
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Clock = fixedClock(time.Unix(1388577600, 0)) // 2014-01-01 12:00
+	mix.ProjectTimezone = time.UTC
+
+	run := func(cursor Cursor) (Cursor, []string) {
+		output := make(chan EventData, 10)
+
+		next, _, err := mix.ExportSince(context.Background(), cursor, output, nil)
+		if err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+		close(output)
+
+		var ids []string
+		for event := range output {
+			ids = append(ids, event["$insert_id"].(string))
+		}
+		return next, ids
+	}
+
+	// First poll: b and c share the latest second.
+	body = strings.Join([]string{event("a", 1388570000), event("b", 1388570010), event("c", 1388570010)}, "\n")
+
+	cursor, ids := run(Cursor{})
+	if strings.Join(ids, ",") != "a,b,c" {
+		t.Errorf("first window: expected a,b,c, got %v", ids)
+	}
+
+	if cursor.Time.Unix() != 1388570010 || !cursor.InsertIDs["b"] || !cursor.InsertIDs["c"] || cursor.InsertIDs["a"] {
+		t.Errorf("unexpected cursor: %+v", cursor)
+	}
+
+	// Second poll overlaps the first: b and c must not be sent again, but
+	// d arrived late in the same second.
+	body = strings.Join([]string{
+		event("a", 1388570000), event("b", 1388570010), event("c", 1388570010),
+		event("d", 1388570010), event("e", 1388570020),
+	}, "\n")
+
+	cursor, ids = run(cursor)
+	if strings.Join(ids, ",") != "d,e" {
+		t.Errorf("second window: expected d,e, got %v", ids)
+	}
+
+	if cursor.Time.Unix() != 1388570020 || len(cursor.InsertIDs) != 1 || !cursor.InsertIDs["e"] {
+		t.Errorf("unexpected cursor: %+v", cursor)
+	}
+}
+
+func TestExportSinceRawLockstep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i, id := range []string{"a", "b", "c"} {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"$insert_id": "%s", "time": %d}}`+"\n", id, 1388570000+i)
+		}
+	}))
+	defer server.Close()
+
+	raws := make(chan []byte, 5)
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Clock = fixedClock(time.Unix(1388577600, 0))
+	mix.ProjectTimezone = time.UTC
+	mix.RawChan = raws
+
+	output := make(chan EventData, 5)
+
+	// a is before the cursor, and b was seen at its time.
+	cursor := Cursor{Time: time.Unix(1388570001, 0), InsertIDs: map[string]bool{"b": true}}
+
+	_, stats, err := mix.ExportSince(context.Background(), cursor, output, nil)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	close(output)
+	close(raws)
+
+	var ids []string
+	for event := range output {
+		ids = append(ids, event[PropInsertID].(string))
+
+		if raw := <-raws; !strings.Contains(string(raw), fmt.Sprintf("%q", event[PropInsertID])) {
+			t.Errorf("raw %s doesn't match %v", raw, event)
+		}
+	}
+
+	for raw := range raws {
+		t.Errorf("raw line of a skipped event %s", raw)
+	}
+
+	if strings.Join(ids, ",") != "c" || stats.EventsExported != 1 {
+		t.Errorf("expected only c, got %v (counted %d)", ids, stats.EventsExported)
+	}
+}
+
+func TestExportSinceProjectDays(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		dates []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		dates = append(dates, r.URL.Query().Get("from_date")+".."+r.URL.Query().Get("to_date"))
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.Clock = fixedClock(time.Date(2014, 1, 2, 3, 0, 0, 0, time.UTC))
+
+	if _, _, err := mix.ExportSince(context.Background(), Cursor{}, make(chan EventData), nil); !errors.Is(err, ErrNoProjectTimezone) {
+		t.Fatalf("expected ErrNoProjectTimezone, got %v", err)
+	}
+
+	// It's still the 1st in the project, 8 hours behind UTC.
+	mix.ProjectTimezone = time.FixedZone("PST", -8*60*60)
+
+	for _, cursor := range []Cursor{{}, {Time: time.Date(2014, 1, 1, 9, 0, 0, 0, time.UTC)}} {
+		dates = nil
+
+		if _, _, err := mix.ExportSince(context.Background(), cursor, make(chan EventData), nil); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		if strings.Join(dates, ",") != "2014-01-01..2014-01-01" {
+			t.Errorf("cursor %s: expected only the project's 1st, got %v", cursor.Time, dates)
+		}
+	}
+
+	// Early on the 1st in UTC is still the 31st in the project.
+	dates = nil
+	if _, _, err := mix.ExportSince(context.Background(), Cursor{Time: time.Date(2014, 1, 1, 2, 0, 0, 0, time.UTC)}, make(chan EventData), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	if strings.Join(dates, ",") != "2013-12-31..2013-12-31,2014-01-01..2014-01-01" {
+		t.Errorf("expected the project's 31st and 1st, got %v", dates)
+	}
+}
@@ -0,0 +1,220 @@
+package mixpanel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// Sink is a destination for exported events, so that output targets (files,
+// cloud storage, queues, ...) can be swapped for each other.
+//
+// `Write` receives each event as a single JSON object, and must not hold on to
+// the slice after returning. `Flush` is called once every event of a
+// successful export has been written, or once those written of a cancelled
+// export have been (see `ExportDateToSink`), and `Close` is always called
+// last.
+type Sink interface {
+	Write(event []byte) error
+	Flush() error
+	Close() error
+}
+
+// DefaultSinkGracePeriod is how long `ExportDateToSink` keeps writing
+// already exported events to a sink after a cancellation, unless
+// `SinkGracePeriod` says otherwise.
+const DefaultSinkGracePeriod = 10 * time.Second
+
+// ExportDateToSink is `ExportDate`, but driving `sink` rather than sending to
+// a channel: every event is written, then the sink is flushed (only if the
+// export succeeded or was cancelled), then closed.
+//
+// If `ctx` is cancelled part way through, the events already exported are
+// still written, for up to `SinkGracePeriod`, and the sink is flushed and
+// closed cleanly before the cancellation is returned. What the sink holds is
+// then a valid, if partial, export. Events not written in time are dropped.
+//
+// If the sink fails, the export is abandoned and the sink's error returned.
+// Otherwise, the first error out of the export, `Flush` and `Close` (in that
+// order) is. A cancelled export always returns an error matching the
+// cancellation with `errors.Is`, mentioning the `Flush` error if there was
+// one.
+//
+// With `Checksum` (or `ChecksumByEvent`), the returned stats carry the
+// SHA-256 of the events written to the sink, as JSON lines (each event
+// followed by a newline). That's the stream a sink writing JSON lines
+// produces. Sinks that encode events differently need their own checksums.
+func (m *Mixpanel) ExportDateToSink(ctx context.Context, date time.Time, sink Sink, moreArgs *url.Values) (Stats, error) {
+	parent := ctx
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	output := make(chan EventData, 100)
+	writeErr := make(chan error, 1)
+
+	// Closed once the grace period after a cancellation is up.
+	abandon := make(chan struct{})
+
+	sums := m.newChecksummer()
+
+	go func() {
+		var err error
+		for event := range output {
+			// Keep draining after a failure so the export side
+			// doesn't block before it notices the cancellation.
+			if err != nil {
+				continue
+			}
+
+			select {
+			case <-abandon:
+				continue
+			default:
+			}
+
+			var data []byte
+			if data, err = json.Marshal(event); err == nil {
+				err = sink.Write(data)
+			}
+
+			if err != nil {
+				cancel()
+			} else {
+				sums.add(event, append(data, '\n'))
+			}
+		}
+
+		writeErr <- err
+	}()
+
+	stats, err := m.ExportDate(ctx, date, output, moreArgs)
+	close(output)
+
+	cancelled := err != nil && parent.Err() != nil
+
+	var werr error
+	if cancelled {
+		grace := m.SinkGracePeriod
+		if grace <= 0 {
+			grace = DefaultSinkGracePeriod
+		}
+
+		timer := time.NewTimer(grace)
+
+		select {
+		case werr = <-writeErr:
+		case <-timer.C:
+			close(abandon)
+			werr = <-writeErr
+		}
+
+		timer.Stop()
+	} else {
+		werr = <-writeErr
+	}
+
+	// A failed write cancels the export, so its error is the cause of
+	// whatever the export returned.
+	if werr != nil {
+		err = werr
+	} else if cancelled {
+		if ferr := sink.Flush(); ferr != nil {
+			err = fmt.Errorf("%s: flushing sink after cancellation failed: %v: %w", m.Product, ferr, err)
+		}
+	}
+
+	if err == nil {
+		err = sink.Flush()
+	}
+
+	if cerr := sink.Close(); err == nil {
+		err = cerr
+	}
+
+	sums.fill(&stats)
+
+	return stats, err
+}
+
+// DecodeEvent decodes an event as passed to `Sink.Write` back into EventData,
+// keeping numbers as json.Number just like the export itself does. For sinks
+// which work on EventData rather than encoded events.
+func DecodeEvent(event []byte) (EventData, error) {
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+
+	var data EventData
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// writerSink is the Sink returned by `NewWriterSink`.
+type writerSink struct {
+	w   io.Writer
+	buf *bufio.Writer
+}
+
+// NewWriterSink adapts an io.Writer into a Sink which writes each event as a
+// line of JSON, buffered until `Flush`. `Close` closes `w` if it is an
+// io.Closer.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w, buf: bufio.NewWriter(w)}
+}
+
+func (s *writerSink) Write(event []byte) error {
+	if _, err := s.buf.Write(event); err != nil {
+		return err
+	}
+
+	return s.buf.WriteByte('\n')
+}
+
+func (s *writerSink) Flush() error {
+	return s.buf.Flush()
+}
+
+func (s *writerSink) Close() error {
+	if closer, ok := s.w.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// chanSink is the Sink returned by `NewChanSink`.
+type chanSink chan<- EventData
+
+// NewChanSink adapts the channel style of output into a Sink, decoding each
+// event back into EventData and sending it on `output`. `Close` closes
+// `output`, to tell the consumer there is nothing more coming.
+func NewChanSink(output chan<- EventData) Sink {
+	return chanSink(output)
+}
+
+func (s chanSink) Write(event []byte) error {
+	data, err := DecodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	s <- data
+	return nil
+}
+
+func (s chanSink) Flush() error {
+	return nil
+}
+
+func (s chanSink) Close() error {
+	close(s)
+	return nil
+}
@@ -0,0 +1,329 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memorySink records everything done to it.
+type memorySink struct {
+	calls    []string
+	events   [][]byte
+	writeErr error
+}
+
+func (s *memorySink) Write(event []byte) error {
+	s.calls = append(s.calls, "write")
+	s.events = append(s.events, append([]byte(nil), event...))
+	return s.writeErr
+}
+
+func (s *memorySink) Flush() error {
+	s.calls = append(s.calls, "flush")
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.calls = append(s.calls, "close")
+	return nil
+}
+
+func newSinkServer(events int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < events; i++ {
+			fmt.Fprintf(w, `{"event": "e%d", "properties": {}}`+"\n", i)
+		}
+	}))
+}
+
+func TestExportDateToSink(t *testing.T) {
+	server := newSinkServer(3)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	sink := &memorySink{}
+
+	stats, err := mix.ExportDateToSink(context.Background(), time.Now(), sink, nil)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 3 {
+		t.Errorf("expected 3 events, got %d", stats.EventsExported)
+	}
+
+	if calls := strings.Join(sink.calls, ","); calls != "write,write,write,flush,close" {
+		t.Errorf("unexpected call order: %s", calls)
+	}
+
+	for i, event := range sink.events {
+		if !bytes.Contains(event, []byte(fmt.Sprintf(`"event":"e%d"`, i))) {
+			t.Errorf("unexpected event %d: %s", i, event)
+		}
+	}
+}
+
+func TestExportDateToSinkWriteError(t *testing.T) {
+	server := newSinkServer(3)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	failure := errors.New("disk full")
+	sink := &memorySink{writeErr: failure}
+
+	if _, err := mix.ExportDateToSink(context.Background(), time.Now(), sink, nil); !errors.Is(err, failure) {
+		t.Errorf("expected write error, got %v", err)
+	}
+
+	// No flush after a failure, but still closed.
+	if calls := strings.Join(sink.calls, ","); calls != "write,close" {
+		t.Errorf("unexpected call order: %s", calls)
+	}
+}
+
+func TestSinkAdapters(t *testing.T) {
+	server := newSinkServer(2)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	var buf bytes.Buffer
+	if _, err := mix.ExportDateToSink(context.Background(), time.Now(), NewWriterSink(&buf), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 {
+		t.Errorf("expected 2 lines, got %q", buf.String())
+	}
+
+	output := make(chan EventData, 2)
+	if _, err := mix.ExportDateToSink(context.Background(), time.Now(), NewChanSink(output), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	count := 0
+	for event := range output {
+		if event["product"] != "product" {
+			t.Errorf("unexpected event: %v", event)
+		}
+		count++
+	}
+
+	if count != 2 {
+		t.Errorf("expected 2 events, got %d", count)
+	}
+}
+
+// EventData leans on encoding/json sorting map keys for stable output. Pin
+// that down, along with the encoder's own settings still applying.
+func TestEventDataSortedKeys(t *testing.T) {
+	event := EventData{
+		"zeta":  1,
+		"alpha": "<b>",
+		"mid":   EventData{"y": true, "x": nil},
+		"list":  []interface{}{map[string]interface{}{"d": 1, "c": 2}},
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := `{"alpha":"\u003cb\u003e","list":[{"c":2,"d":1}],"mid":{"x":null,"y":true},"zeta":1}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(event); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected = `{"alpha":"<b>","list":[{"c":2,"d":1}],"mid":{"x":null,"y":true},"zeta":1}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 20; i++ {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"z": %d, "a": "x", "m": {"q": 1, "b": 2}, "time": 1388534400}}`+"\n", i)
+		}
+	}))
+	defer server.Close()
+
+	export := func() []byte {
+		mix := NewWithURL("product", "key", "secret", server.URL)
+
+		var buf bytes.Buffer
+		if _, err := mix.ExportDateToSink(context.Background(), time.Now(), NewWriterSink(&buf), nil); err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		return buf.Bytes()
+	}
+
+	// Event IDs are random on every run, so they're the one thing allowed
+	// to differ.
+	ids := regexp.MustCompile(`"` + regexp.QuoteMeta(EventIDKey) + `":"[^"]*"`)
+	first := ids.ReplaceAll(export(), []byte(`"id":""`))
+	second := ids.ReplaceAll(export(), []byte(`"id":""`))
+
+	if !bytes.Equal(first, second) {
+		t.Errorf("exports differ:\n%s\n%s", first, second)
+	}
+
+	line := first[:bytes.IndexByte(first, '\n')]
+	if !bytes.HasPrefix(line, []byte(`{"id":"","$__$$timestamp":"2014-01-01 00:00:00","a":"x","event":"e","m":{"b":2,"q":1},`)) {
+		t.Errorf("keys not sorted: %s", line)
+	}
+}
+
+// cancellingSink is a writer sink which cancels the export once it has seen
+// `after` events, optionally taking `delay` over each write.
+type cancellingSink struct {
+	Sink
+	after  int
+	delay  time.Duration
+	cancel context.CancelFunc
+
+	writes   int
+	calls    []string
+	flushErr error
+}
+
+func (s *cancellingSink) Write(event []byte) error {
+	s.writes++
+	if s.writes == s.after {
+		s.cancel()
+	}
+
+	time.Sleep(s.delay)
+	return s.Sink.Write(event)
+}
+
+func (s *cancellingSink) Flush() error {
+	s.calls = append(s.calls, "flush")
+	if s.flushErr != nil {
+		return s.flushErr
+	}
+
+	return s.Sink.Flush()
+}
+
+func (s *cancellingSink) Close() error {
+	s.calls = append(s.calls, "close")
+	return s.Sink.Close()
+}
+
+// newBlockingServer sends `events` events, then holds the connection open
+// until the request is cancelled.
+func newBlockingServer(events int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < events; i++ {
+			fmt.Fprintf(w, `{"event": "e%d", "properties": {}}`+"\n", i)
+		}
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+}
+
+func TestExportDateToSinkCancelled(t *testing.T) {
+	server := newBlockingServer(50)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var buf bytes.Buffer
+	sink := &cancellingSink{Sink: NewWriterSink(&buf), after: 10, cancel: cancel}
+
+	stats, err := mix.ExportDateToSink(ctx, time.Now(), sink, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+
+	if strings.Join(sink.calls, ",") != "flush,close" {
+		t.Errorf("expected a flush then close, got %v", sink.calls)
+	}
+
+	// Everything exported before the cancellation made it out, intact.
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) < 10 || len(lines) != stats.EventsExported {
+		t.Fatalf("expected all %d exported events, got %d lines", stats.EventsExported, len(lines))
+	}
+
+	for i, line := range lines {
+		var event EventData
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("line %d is invalid: %v", i, err)
+		} else if event["event"] != fmt.Sprintf("e%d", i) {
+			t.Errorf("line %d: unexpected event %v", i, event["event"])
+		}
+	}
+}
+
+func TestExportDateToSinkCancelledFlushFails(t *testing.T) {
+	server := newBlockingServer(50)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	flushErr := errors.New("disk full")
+	sink := &cancellingSink{Sink: NewWriterSink(ioutil.Discard), after: 10, cancel: cancel, flushErr: flushErr}
+
+	_, err := mix.ExportDateToSink(ctx, time.Now(), sink, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	} else if !strings.Contains(err.Error(), flushErr.Error()) {
+		t.Errorf("expected the flush error to be mentioned, got %v", err)
+	}
+
+	if strings.Join(sink.calls, ",") != "flush,close" {
+		t.Errorf("expected a flush then close, got %v", sink.calls)
+	}
+}
+
+func TestExportDateToSinkGracePeriod(t *testing.T) {
+	server := newBlockingServer(50)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.SinkGracePeriod = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var buf bytes.Buffer
+	sink := &cancellingSink{Sink: NewWriterSink(&buf), after: 1, delay: 20 * time.Millisecond, cancel: cancel}
+
+	start := time.Now()
+	stats, err := mix.ExportDateToSink(ctx, time.Now(), sink, nil)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	} else if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %s to give up on a slow sink", elapsed)
+	}
+
+	if strings.Join(sink.calls, ",") != "flush,close" {
+		t.Errorf("expected a flush then close, got %v", sink.calls)
+	}
+
+	if sink.writes >= stats.EventsExported {
+		t.Errorf("expected writes to stop at the grace period, wrote all %d", sink.writes)
+	} else if lines := strings.Count(buf.String(), "\n"); lines != sink.writes {
+		t.Errorf("expected %d complete lines, got %d", sink.writes, lines)
+	}
+}
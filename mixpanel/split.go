@@ -0,0 +1,119 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// DefaultSplitParts is the number of requests a failed export is split into
+// under `SplitOnFailure` when `SplitParts` is zero: one an hour.
+const DefaultSplitParts = 24
+
+// Without a `ProjectTimezone`, the span of time the days of an export cover
+// is only known to within the offsets of the timezones furthest from UTC, so
+// a split covers all of them.
+const (
+	splitEarliestOffset = 14 * time.Hour
+	splitLatestOffset   = 12 * time.Hour
+)
+
+// splitParts returns the configured `SplitParts`, using the default if unset.
+func (m *Mixpanel) splitParts() int {
+	if m.SplitParts <= 0 {
+		return DefaultSplitParts
+	}
+
+	return m.SplitParts
+}
+
+// splittable reports whether an export which failed with `err` might
+// succeed as smaller requests: it timed out, stalled, was too large or hit a
+// server error, rather than being rejected or cancelled.
+func splittable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+
+	return true
+}
+
+// splitSpan returns the span of time covered by the project days `from`
+// through `to`.
+func (m *Mixpanel) splitSpan(from, to time.Time) (time.Time, time.Time) {
+	loc := m.ProjectTimezone
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, loc)
+
+	if m.ProjectTimezone == nil {
+		start, end = start.Add(-splitEarliestOffset), end.Add(splitLatestOffset)
+	}
+
+	return start, end
+}
+
+// exportSplit retries the export of the project days `from` through `to`
+// for `SplitOnFailure`, as `SplitParts` requests for consecutive slices of
+// their time, and one more for any events without a time, which together
+// send every event once. `failed` are the stats of the attempt that failed.
+func (m *Mixpanel) exportSplit(ctx context.Context, from, to time.Time, window *timeWindow, output chan<- EventData, moreArgs *url.Values, failed Stats) (Stats, error) {
+	start, end := m.splitSpan(from, to)
+	parts := m.splitParts()
+	step := end.Sub(start) / time.Duration(parts)
+
+	wheres := make([]string, 0, parts+1)
+	for i := 0; i < parts; i++ {
+		lo, hi := start.Add(step*time.Duration(i)), start.Add(step*time.Duration(i+1))
+		if i == parts-1 {
+			hi = end
+		}
+
+		wheres = append(wheres, fmt.Sprintf(`properties["time"] >= datetime(%d) and properties["time"] < datetime(%d)`, lo.Unix(), hi.Unix()))
+	}
+
+	wheres = append(wheres, `not defined(properties["time"])`)
+
+	total := Stats{BytesRead: failed.BytesRead}
+
+	for i, where := range wheres {
+		stats, err := m.exportOnce(ctx, from, to, window, output, splitArgs(moreArgs, where))
+
+		total.add(stats)
+
+		if err != nil {
+			return total, fmt.Errorf("%s: split export part %d of %d failed: %w", m.Product, i+1, len(wheres), err)
+		}
+	}
+
+	return total, nil
+}
+
+// splitArgs returns a copy of `moreArgs` whose `where` is also limited by
+// `where`.
+func splitArgs(moreArgs *url.Values, where string) *url.Values {
+	args := url.Values{}
+	if moreArgs != nil {
+		for k, vs := range *moreArgs {
+			args[k] = append([]string(nil), vs...)
+		}
+	}
+
+	if existing := args.Get("where"); existing != "" {
+		where = fmt.Sprintf("(%s) and %s", existing, where)
+	}
+
+	args.Set("where", where)
+
+	return &args
+}
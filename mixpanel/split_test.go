@@ -0,0 +1,136 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// splitServer serves the events of a day, one every hour (and one without a
+// time), failing requests for the whole day with a gateway timeout and
+// answering the ones limited by `splitArgs` with the events they select.
+func splitServer(t *testing.T, day time.Time) (*httptest.Server, *[]string) {
+	var (
+		mu     sync.Mutex
+		wheres []string
+	)
+
+	bounds := regexp.MustCompile(`datetime\((\d+)\) and properties\["time"\] < datetime\((\d+)\)`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		where := r.URL.Query().Get("where")
+
+		mu.Lock()
+		wheres = append(wheres, where)
+		mu.Unlock()
+
+		if !strings.Contains(where, "datetime") && !strings.Contains(where, "defined") {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+
+		if where == `(properties["plan"] == "paid") and not defined(properties["time"])` {
+			fmt.Fprintln(w, `{"event": "untimed", "properties": {}}`)
+			return
+		}
+
+		match := bounds.FindStringSubmatch(where)
+		if match == nil {
+			t.Errorf("unexpected where %q", where)
+			return
+		}
+
+		lo, _ := strconv.ParseInt(match[1], 10, 64)
+		hi, _ := strconv.ParseInt(match[2], 10, 64)
+
+		for hour := 0; hour < 24; hour++ {
+			if ts := day.Add(time.Duration(hour)*time.Hour + 30*time.Minute).Unix(); ts >= lo && ts < hi {
+				fmt.Fprintf(w, `{"event": "e", "properties": {"time": %d, "hour": %d}}`+"\n", ts, hour)
+			}
+		}
+	}))
+
+	return server, &wheres
+}
+
+func TestSplitOnFailure(t *testing.T) {
+	day := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	server, wheres := splitServer(t, day)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	// Without it, the failure is returned as it is.
+	if _, err := mix.ExportDate(context.Background(), day, make(chan EventData, 100), nil); err == nil {
+		t.Fatal("expected the day to fail")
+	}
+
+	mix.SplitOnFailure = true
+	mix.SplitParts = 5
+	*wheres = nil
+
+	output := make(chan EventData, 100)
+	moreArgs := &url.Values{"where": {`properties["plan"] == "paid"`}}
+
+	stats, err := mix.ExportDate(context.Background(), day, output, moreArgs)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	close(output)
+
+	hours := make(map[interface{}]int)
+	untimed := 0
+	for event := range output {
+		if event["event"] == "untimed" {
+			untimed++
+		} else {
+			hours[event["hour"]]++
+		}
+	}
+
+	if stats.EventsExported != 25 || len(hours) != 24 || untimed != 1 {
+		t.Errorf("expected every event once, got %d events over %d hours (%d untimed)", stats.EventsExported, len(hours), untimed)
+	}
+
+	for hour, n := range hours {
+		if n != 1 {
+			t.Errorf("hour %v sent %d times", hour, n)
+		}
+	}
+
+	// The failed request, then the slices and the events without a time.
+	if len(*wheres) != 1+5+1 {
+		t.Errorf("expected 7 requests, got %d: %q", len(*wheres), *wheres)
+	}
+}
+
+func TestSplitOnFailureUnsplittable(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.SplitOnFailure = true
+
+	if _, err := mix.ExportDate(context.Background(), time.Now(), make(chan EventData, 1), nil); err == nil {
+		t.Error("expected the request to fail")
+	}
+
+	if requests != 1 {
+		t.Errorf("expected a bad request not to be split, got %d requests", requests)
+	}
+}
@@ -0,0 +1,60 @@
+package mixpanel
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled is returned when an export's response stops sending data for
+// longer than the configured `IdleTimeout`, while the connection stays open.
+// Nothing is wrong with the request itself, so it is safe to retry.
+var ErrStalled = errors.New("export stalled: no data received within the idle timeout")
+
+// isAbort reports whether `err` is one of the errors a response reader aborts
+// with (`ErrStalled`, `ErrResponseTooLarge`). These are returned as is rather
+// than wrapped as a read or parse failure.
+func isAbort(err error) bool {
+	return err == ErrStalled || err == ErrResponseTooLarge
+}
+
+// idleReader passes reads through to `r`, calling `abort` (which should
+// cancel the request) if any one read blocks for longer than `timeout`, and
+// failing with `ErrStalled` once it has. Only time spent waiting on `r`
+// counts, so a slow consumer of the events doesn't look like a stall.
+type idleReader struct {
+	r       io.Reader
+	timeout time.Duration
+	abort   func()
+	timer   *time.Timer
+	stalled int32
+}
+
+func newIdleReader(r io.Reader, timeout time.Duration, abort func()) *idleReader {
+	i := &idleReader{r: r, timeout: timeout, abort: abort}
+
+	i.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&i.stalled, 1)
+		i.abort()
+	})
+	i.timer.Stop()
+
+	return i
+}
+
+func (i *idleReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&i.stalled) == 1 {
+		return 0, ErrStalled
+	}
+
+	i.timer.Reset(i.timeout)
+	n, err := i.r.Read(p)
+	i.timer.Stop()
+
+	if err != nil && atomic.LoadInt32(&i.stalled) == 1 {
+		return n, ErrStalled
+	}
+
+	return n, err
+}
@@ -0,0 +1,121 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, `{"event": "e%d", "properties": {}}`+"\n", i)
+		}
+		w.(http.Flusher).Flush()
+
+		// Go silent, but keep the connection open.
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.IdleTimeout = 50 * time.Millisecond
+
+	output := make(chan EventData, 10)
+
+	start := time.Now()
+	stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrStalled) {
+		t.Fatalf("expected ErrStalled, got %v", err)
+	} else if stats.EventsExported != 3 || len(output) != 3 {
+		t.Errorf("expected the 3 events before the stall, got %d", stats.EventsExported)
+	}
+
+	if elapsed > 5*time.Second {
+		t.Errorf("took %s to notice the stall", elapsed)
+	}
+}
+
+func TestIdleTimeoutSlowConsumer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 2000; i++ {
+			fmt.Fprintf(w, `{"event": "e%d", "properties": {}}`+"\n", i)
+		}
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.IdleTimeout = 20 * time.Millisecond
+
+	// Taking longer than the idle timeout to read an event isn't a stall,
+	// even though nothing is read off the connection meanwhile.
+	output := make(chan EventData)
+	go func() {
+		<-output
+		time.Sleep(50 * time.Millisecond)
+
+		for range output {
+		}
+	}()
+
+	stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil)
+	close(output)
+
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 2000 {
+		t.Errorf("expected 2000 events, got %d", stats.EventsExported)
+	}
+}
+
+// stallingReader returns `data`, then fails the way an idleReader does once
+// the connection has gone quiet.
+type stallingReader struct {
+	data []byte
+}
+
+func (s *stallingReader) Read(p []byte) (int, error) {
+	if len(s.data) == 0 {
+		return 0, ErrStalled
+	}
+
+	n := copy(p, s.data)
+	s.data = s.data[n:]
+	return n, nil
+}
+
+func TestStalledTransformUnwrapped(t *testing.T) {
+	lines := `{"event": "a", "properties": {}}` + "\n" + `{"event": "b", "properties": {}}` + "\n"
+
+	inputs := map[string]string{
+		"lines":     lines,
+		"array":     `[{"event": "a", "properties": {}}, `,
+		"truncated": `{"event": "a", "prop`,
+	}
+
+	for _, workers := range []int{1, 4} {
+		for name, input := range inputs {
+			mix := New("product", "", "")
+			mix.DecodeWorkers = workers
+
+			output := make(chan EventData, 10)
+			_, err := mix.TransformEventData(&stallingReader{data: []byte(input)}, output)
+
+			if err != ErrStalled {
+				t.Errorf("%s with %d workers: expected ErrStalled as is, got %v", name, workers, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package mixpanel
+
+// Default names of the keys `product` and `event` information is attached to
+// each record under.
+const (
+	DefaultProductKey = "product"
+	DefaultEventKey   = "event"
+)
+
+// CollisionPrefix is prepended to the key of a property that would otherwise
+// be overwritten by one of the keys mixport attaches to each record. For
+// example, an event that already has a `product` property will have that
+// value preserved as `mp_product`.
+const CollisionPrefix = "mp_"
+
+// productKey returns the key the product name is attached under.
+func (m *Mixpanel) productKey() string {
+	if m.ProductKey == "" {
+		return DefaultProductKey
+	}
+
+	return m.ProductKey
+}
+
+// eventKey returns the key the event name is attached under.
+func (m *Mixpanel) eventKey() string {
+	if m.EventKey == "" {
+		return DefaultEventKey
+	}
+
+	return m.EventKey
+}
+
+// stamp sets `key` to `value` in `props`. If there's already a property with
+// that name, it is moved to a prefixed key (repeatedly, if need be) rather
+// than being silently overwritten.
+func stamp(props map[string]interface{}, key string, value interface{}) {
+	if existing, ok := props[key]; ok {
+		setAside(props, key, existing)
+	}
+
+	props[key] = value
+}
+
+// setAside sets `key` to `value` in `props` under the first prefixed version
+// of `key` (see `CollisionPrefix`) that isn't taken.
+func setAside(props map[string]interface{}, key string, value interface{}) {
+	moved := CollisionPrefix + key
+	for {
+		if _, taken := props[moved]; !taken {
+			break
+		}
+		moved = CollisionPrefix + moved
+	}
+
+	props[moved] = value
+}
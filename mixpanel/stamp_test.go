@@ -0,0 +1,68 @@
+package mixpanel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStampCollision(t *testing.T) {
+	mix := New("product", "", "")
+	input := strings.NewReader(`{"event": "a", "properties": {"product": "user product", "event": "user event", "mp_event": "taken"}}`)
+	output := make(chan EventData, 1)
+
+	if _, err := mix.TransformEventData(input, output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	event := <-output
+
+	expected := []struct {
+		Name  string
+		Value interface{}
+	}{
+		{"product", "product"},
+		{"event", "a"},
+		{"mp_product", "user product"},
+		{"mp_event", "taken"},
+		{"mp_mp_event", "user event"},
+	}
+
+	for _, e := range expected {
+		if v, ok := event[e.Name]; !ok || v != e.Value {
+			t.Errorf("bad value: expected %s=(%v) got %s=(%v)", e.Name, e.Value,
+				e.Name, v)
+		}
+	}
+}
+
+func TestStampCustomKeys(t *testing.T) {
+	mix := New("product", "", "")
+	mix.ProductKey = "_product"
+	mix.EventKey = "_event"
+
+	input := strings.NewReader(`{"event": "a", "properties": {"product": "user product", "event": "user event"}}`)
+	output := make(chan EventData, 1)
+
+	if _, err := mix.TransformEventData(input, output); err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	event := <-output
+
+	expected := []struct {
+		Name  string
+		Value interface{}
+	}{
+		{"_product", "product"},
+		{"_event", "a"},
+		{"product", "user product"},
+		{"event", "user event"},
+	}
+
+	for _, e := range expected {
+		if v, ok := event[e.Name]; !ok || v != e.Value {
+			t.Errorf("bad value: expected %s=(%v) got %s=(%v)", e.Name, e.Value,
+				e.Name, v)
+		}
+	}
+}
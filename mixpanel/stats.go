@@ -0,0 +1,54 @@
+package mixpanel
+
+import "time"
+
+// Stats collects statistics about a single export run.
+//
+// - `EventsExported` is the number of events sent over the output channel.
+// - `BytesRead` is the number of bytes of response body read.
+// - `OversizedEvents` is the number of events over `MaxProperties`, whether
+//   they were truncated or dropped.
+// - `MissingTime` is the number of events without a `time` property,
+//   whatever `MissingTimePolicy` did with them.
+// - `TapDropped` is the number of events `Tap` missed for falling behind.
+// - `Dropped` is the number of events `OverflowPolicy` dropped, which aren't
+//   counted in `EventsExported`.
+// - `NetworkWait` is the time spent waiting on reads of the response (or
+//   file), and `DecodeTime` the rest of the time spent decoding and
+//   transforming it, other than waiting to send events. If `NetworkWait`
+//   dominates, the export is held up by the network; if `DecodeTime` does,
+//   `DecodeWorkers` may help. With `DecodeWorkers`, reading and decoding
+//   overlap, and `DecodeTime` is only what reading didn't cover.
+// - `Checksum` and `EventChecksums` are the hex encoded SHA-256 of the
+//   events written, overall and per event type, if `Checksum` or
+//   `ChecksumByEvent` asked for them (see there).
+type Stats struct {
+	EventsExported  int
+	BytesRead       int64
+	OversizedEvents int
+	MissingTime     int
+	TapDropped      int
+	Dropped         int
+	NetworkWait     time.Duration
+	DecodeTime      time.Duration
+	Checksum        string
+	EventChecksums  map[string]string
+
+	// Time spent waiting to send events, which doesn't count towards
+	// `DecodeTime`.
+	outputWait time.Duration
+}
+
+// add adds the counts and times of `other` to the stats, for exports made of
+// several others. The checksums can't be combined, so are left alone.
+func (s *Stats) add(other Stats) {
+	s.EventsExported += other.EventsExported
+	s.BytesRead += other.BytesRead
+	s.OversizedEvents += other.OversizedEvents
+	s.MissingTime += other.MissingTime
+	s.TapDropped += other.TapDropped
+	s.Dropped += other.Dropped
+	s.NetworkWait += other.NetworkWait
+	s.DecodeTime += other.DecodeTime
+	s.outputWait += other.outputWait
+}
@@ -0,0 +1,37 @@
+package mixpanel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatsAddCoversEveryCount(t *testing.T) {
+	var one Stats
+
+	// Every count and duration, so that a new one can't be left out of
+	// `add`.
+	v := reflect.ValueOf(&one).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if field := v.Field(i); field.CanSet() && (field.Kind() == reflect.Int || field.Kind() == reflect.Int64) {
+			field.SetInt(1)
+		}
+	}
+
+	one.outputWait = time.Nanosecond
+
+	var total Stats
+	total.add(one)
+	total.add(one)
+
+	got := reflect.ValueOf(total)
+	for i := 0; i < got.NumField(); i++ {
+		if field := got.Field(i); (field.Kind() == reflect.Int || field.Kind() == reflect.Int64) && v.Type().Field(i).PkgPath == "" && field.Int() != 2 {
+			t.Errorf("%s wasn't added up: %d", got.Type().Field(i).Name, field.Int())
+		}
+	}
+
+	if total.outputWait != 2*time.Nanosecond {
+		t.Errorf("outputWait wasn't added up: %s", total.outputWait)
+	}
+}
@@ -0,0 +1,68 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"time"
+)
+
+// StreamDate exports the given day, writing each event to `w` as a line of
+// JSON. This is the glue that has to be written around `ExportDate` for
+// something like dumping a day to stdout.
+//
+// If writing to `w` fails, the export is abandoned and the write error
+// returned. Otherwise any error from the export itself is returned.
+//
+// With `Checksum` (or `ChecksumByEvent`), the returned stats carry the
+// SHA-256 of exactly the bytes written to `w`.
+func (m *Mixpanel) StreamDate(ctx context.Context, date time.Time, w io.Writer, moreArgs *url.Values) (Stats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	output := make(chan EventData, 100)
+	writeErr := make(chan error, 1)
+	sums := m.newChecksummer()
+
+	go func() {
+		var line bytes.Buffer
+		encoder := json.NewEncoder(&line)
+
+		var err error
+		for event := range output {
+			// Keep draining after a failure so the export side
+			// doesn't block before it notices the cancellation.
+			if err != nil {
+				continue
+			}
+
+			line.Reset()
+
+			if err = encoder.Encode(event); err == nil {
+				_, err = w.Write(line.Bytes())
+			}
+
+			if err != nil {
+				cancel()
+			} else {
+				sums.add(event, line.Bytes())
+			}
+		}
+
+		writeErr <- err
+	}()
+
+	stats, err := m.ExportDate(ctx, date, output, moreArgs)
+	close(output)
+
+	werr := <-writeErr
+	sums.fill(&stats)
+
+	if werr != nil {
+		return stats, werr
+	}
+
+	return stats, err
+}
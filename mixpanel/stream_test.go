@@ -0,0 +1,55 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamDate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat(`{"event": "a", "properties": {"b": "c"}}`+"\n", 5))
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	var output bytes.Buffer
+
+	stats, err := mix.StreamDate(context.Background(), time.Now(), &output, nil)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != stats.EventsExported || stats.EventsExported != 5 {
+		t.Errorf("expected 5 lines and events, got %d lines and %d events",
+			len(lines), stats.EventsExported)
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestStreamDateWriteError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat(`{"event": "a", "properties": {"b": "c"}}`+"\n", 5))
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	if _, err := mix.StreamDate(context.Background(), time.Now(), failingWriter{}, nil); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected write error, got %v", err)
+	}
+}
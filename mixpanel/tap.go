@@ -0,0 +1,60 @@
+package mixpanel
+
+// TapBuffer is how many events can be waiting for a slow `Tap` before any
+// more are dropped.
+const TapBuffer = 1024
+
+// tap hands events to `Tap` from a goroutine of its own, so that a slow tap
+// only ever loses events rather than holding up the export. A nil tap does
+// nothing.
+type tap struct {
+	events  chan []byte
+	done    chan struct{}
+	dropped int
+}
+
+// startTap starts passing events to `Tap`, if it's set.
+func (m *Mixpanel) startTap() *tap {
+	if m.Tap == nil {
+		return nil
+	}
+
+	t := &tap{events: make(chan []byte, TapBuffer), done: make(chan struct{})}
+
+	go func() {
+		defer close(t.done)
+
+		for raw := range t.events {
+			m.Tap(raw)
+		}
+	}()
+
+	return t
+}
+
+// send queues `raw` for the tap, dropping it if the tap has fallen too far
+// behind. It's only ever called from the goroutine sending to the output.
+func (t *tap) send(raw []byte) {
+	if t == nil {
+		return
+	}
+
+	select {
+	case t.events <- raw:
+	default:
+		t.dropped++
+	}
+}
+
+// stop waits for the tap to see the events already queued, and returns how
+// many were dropped.
+func (t *tap) stop() int {
+	if t == nil {
+		return 0
+	}
+
+	close(t.events)
+	<-t.done
+
+	return t.dropped
+}
@@ -0,0 +1,91 @@
+package mixpanel
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTap(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		tapped []string
+	)
+
+	mix := New("product", "", "")
+	mix.Events = []string{"a"}
+	mix.ServerSideFilter = false
+	mix.Tap = func(event []byte) {
+		mu.Lock()
+		tapped = append(tapped, string(event))
+		mu.Unlock()
+	}
+
+	input := strings.NewReader(`{"event": "a", "properties": {"n": 1}}
+{"event": "b", "properties": {"n": 2}}
+{"event": "a", "properties": {"n": 3}}
+`)
+	output := make(chan EventData, 3)
+
+	stats, err := mix.TransformEventData(input, output)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+
+	expected := []string{`{"event": "a", "properties": {"n": 1}}`, `{"event": "a", "properties": {"n": 3}}`}
+	if fmt.Sprint(tapped) != fmt.Sprint(expected) || stats.TapDropped != 0 {
+		t.Errorf("expected the tap to see %v, got %v (%d dropped)", expected, tapped, stats.TapDropped)
+	}
+}
+
+func TestTapSlow(t *testing.T) {
+	const events = TapBuffer * 2
+
+	release := make(chan struct{})
+	seen := 0
+
+	mix := New("product", "", "")
+	mix.Tap = func(event []byte) {
+		<-release
+		seen++
+	}
+
+	var lines strings.Builder
+	for i := 0; i < events; i++ {
+		fmt.Fprintf(&lines, `{"event": "e", "properties": {"n": %d}}`+"\n", i)
+	}
+
+	output := make(chan EventData)
+	result := make(chan Stats, 1)
+
+	go func() {
+		stats, err := mix.TransformEventData(strings.NewReader(lines.String()), output)
+		if err != nil {
+			t.Errorf("raised error: %v", err)
+		}
+		result <- stats
+	}()
+
+	// Every event has to come through while the tap is stuck.
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < events; i++ {
+		select {
+		case <-output:
+		case <-timeout:
+			t.Fatalf("output stalled after %d events", i)
+		}
+	}
+
+	close(release)
+	stats := <-result
+
+	if stats.EventsExported != events {
+		t.Errorf("expected %d events, got %d", events, stats.EventsExported)
+	}
+
+	if stats.TapDropped == 0 || seen+stats.TapDropped != events {
+		t.Errorf("expected the tap to lose events instead, saw %d and dropped %d", seen, stats.TapDropped)
+	}
+}
@@ -0,0 +1,111 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default number of times a throttled (HTTP 429) request is retried before
+// giving up.
+const DefaultThrottleRetries = 3
+
+// Wait used before retrying a throttled request when the response didn't
+// include a usable `Retry-After` header.
+const DefaultThrottleDelay = 10 * time.Second
+
+// do sends `req`, transparently retrying it if Mixpanel responds with HTTP 429
+// (Too Many Requests). Each retry waits for as long as the `Retry-After`
+// header asks, and `OnThrottle` is called (if set) before each wait.
+//
+// If a `Breaker` is set, it has to allow the request first, and is told how
+// it went.
+//
+// Any extra headers (see `Headers` and `WithHeaders`) are added here.
+func (m *Mixpanel) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	m.setHeaders(ctx, req)
+
+	probe, err := m.Breaker.allow()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Product, err)
+	}
+
+	resp, err := m.doThrottled(ctx, req)
+
+	// Cancellation says nothing about Mixpanel's health.
+	if ctx.Err() != nil {
+		m.Breaker.abandon(probe)
+	} else {
+		m.Breaker.record(probe, breakerFailure(resp, err))
+	}
+
+	return resp, err
+}
+
+// doThrottled does the 429 handling of `do`.
+func (m *Mixpanel) doThrottled(ctx context.Context, req *http.Request) (*http.Response, error) {
+	retries := m.ThrottleRetries
+	if retries == 0 {
+		retries = DefaultThrottleRetries
+	}
+
+	ctx = m.withAuditID(ctx)
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req.WithContext(ctx)
+		m.audit(attemptReq, attempt)
+
+		resp, err := m.client().Do(attemptReq)
+		if err != nil {
+			return nil, redactError(err)
+		} else if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+
+		if attempt > retries {
+			return nil, fmt.Errorf("%s: still throttled after %d attempts: %w", m.Product, attempt, ErrThrottled)
+		}
+
+		wait := retryAfter(resp.Header.Get("Retry-After"), m.now())
+
+		if m.OnThrottle != nil {
+			m.OnThrottle(wait, attempt)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		// Request bodies are consumed by sending them, so we need a
+		// fresh one for the next attempt.
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryAfter interprets the value of a `Retry-After` header, which can either
+// be a number of seconds or an HTTP date.
+func retryAfter(header string, now time.Time) time.Duration {
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+
+		return 0
+	}
+
+	return DefaultThrottleDelay
+}
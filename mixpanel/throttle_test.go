@@ -0,0 +1,84 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOnThrottle(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		fmt.Fprint(w, `{"event": "a", "properties": {}}`)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	var attempts []int
+	mix.OnThrottle = func(retryAfter time.Duration, attempt int) {
+		if retryAfter != 0 {
+			t.Errorf("expected retryAfter=0, got %v", retryAfter)
+		}
+
+		attempts = append(attempts, attempt)
+	}
+
+	output := make(chan EventData, 1)
+
+	if stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 1 {
+		t.Errorf("expected 1 record, got %d", stats.EventsExported)
+	}
+
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Errorf("expected callback for attempts [1 2], got %v", attempts)
+	}
+}
+
+func TestThrottleRetriesExhausted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.ThrottleRetries = 1
+
+	output := make(chan EventData)
+
+	if _, err := mix.ExportDate(context.Background(), time.Now(), output, nil); err == nil {
+		t.Error("expected error once retries are exhausted")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		Header   string
+		Expected time.Duration
+	}{
+		{"5", 5 * time.Second},
+		{"Thu, 01 Jan 2015 00:00:30 GMT", 30 * time.Second},
+		{"garbage", DefaultThrottleDelay},
+	}
+
+	for _, c := range cases {
+		if wait := retryAfter(c.Header, now); wait != c.Expected {
+			t.Errorf("%s: expected %v, got %v", c.Header, c.Expected, wait)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package mixpanel
+
+import "time"
+
+// projectWindow works out what to ask Mixpanel for to cover the day that
+// `date` falls on in its own location, when that differs from the project's
+// timezone.
+//
+// Returns the first and last project days that overlap the day, and the
+// bounds of the day itself, which events from those project days have to be
+// filtered down to.
+func (m *Mixpanel) projectWindow(date time.Time) (from, to time.Time, window *timeWindow) {
+	year, month, day := date.Date()
+
+	start := time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+	end := start.AddDate(0, 0, 1)
+
+	return start.In(m.ProjectTimezone), end.Add(-time.Nanosecond).In(m.ProjectTimezone), &timeWindow{start, end}
+}
+
+// timeWindow is the span of time [start, end) events have to happen in to be
+// exported.
+type timeWindow struct {
+	start, end time.Time
+}
+
+// contains reports whether `event` happened within the window. Events
+// without a usable time can't be placed, so are let through.
+func (w *timeWindow) contains(event EventData) bool {
+	t, ok := EventTime(event)
+	return !ok || (!t.Before(w.start) && t.Before(w.end))
+}
@@ -0,0 +1,103 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProjectTimezone(t *testing.T) {
+	var from, to string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, to = r.URL.Query().Get("from_date"), r.URL.Query().Get("to_date")
+
+		for _, event := range []struct {
+			name string
+			time int64
+		}{
+			{"before", 1388534399}, // 2013-12-31 23:59:59 UTC
+			{"start", 1388534400},  // 2014-01-01 00:00:00 UTC
+			{"end", 1388620799},    // 2014-01-01 23:59:59 UTC
+			{"after", 1388620800},  // 2014-01-02 00:00:00 UTC
+		} {
+			fmt.Fprintf(w, `{"event": "%s", "properties": {"time": %d}}`+"\n", event.name, event.time)
+		}
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.ProjectTimezone = time.FixedZone("PST", -8*60*60)
+
+	// The UTC day runs from 16:00 on the 31st to 16:00 on the 1st in the
+	// project's timezone.
+	date := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	output := make(chan EventData, 4)
+
+	stats, err := mix.ExportDate(context.Background(), date, output, nil)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	}
+	close(output)
+
+	if from != "2013-12-31" || to != "2014-01-01" {
+		t.Errorf("expected 2013-12-31 to 2014-01-01 to be requested, got %s to %s", from, to)
+	}
+
+	if stats.EventsExported != 2 {
+		t.Errorf("expected 2 events, got %d", stats.EventsExported)
+	}
+
+	for event := range output {
+		if name := event["event"]; name != "start" && name != "end" {
+			t.Errorf("expected %s to be filtered out", name)
+		}
+	}
+}
+
+func TestProjectTimezoneSameDay(t *testing.T) {
+	pst := time.FixedZone("PST", -8*60*60)
+
+	mix := New("product", "key", "secret")
+	mix.ProjectTimezone = pst
+
+	from, to, _ := mix.projectWindow(time.Date(2014, 1, 1, 0, 0, 0, 0, pst))
+
+	if from.Format("2006-01-02") != "2014-01-01" || to.Format("2006-01-02") != "2014-01-01" {
+		t.Errorf("expected just 2014-01-01, got %v to %v", from, to)
+	}
+}
+
+func TestProjectTimezoneRawChan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, ts := range []int64{1388534399, 1388534400, 1388620799, 1388620800} {
+			fmt.Fprintf(w, `{"event": "e", "properties": {"time": %d}}`+"\n", ts)
+		}
+	}))
+	defer server.Close()
+
+	for _, workers := range []int{0, 2} {
+		raws := make(chan []byte, 4)
+
+		mix := NewWithURL("product", "key", "secret", server.URL)
+		mix.ProjectTimezone = time.FixedZone("PST", -8*60*60)
+		mix.RawChan = raws
+		mix.DecodeWorkers = workers
+
+		output := make(chan EventData, 4)
+
+		stats, err := mix.ExportDate(context.Background(), time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC), output, nil)
+		if err != nil {
+			t.Fatalf("workers=%d: raised error: %v", workers, err)
+		}
+
+		// Events outside the day mustn't leave their raw bytes behind.
+		if stats.EventsExported != 2 || len(output) != 2 || len(raws) != 2 {
+			t.Errorf("workers=%d: expected 2 events and 2 raw records, got %d (%d sent) and %d",
+				workers, stats.EventsExported, len(output), len(raws))
+		}
+	}
+}
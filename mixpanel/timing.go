@@ -0,0 +1,30 @@
+package mixpanel
+
+import (
+	"io"
+	"time"
+)
+
+// timedReader passes reads through to `r`, adding up the time they take.
+type timedReader struct {
+	r    io.Reader
+	wait time.Duration
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.r.Read(p)
+	t.wait += time.Since(start)
+
+	return n, err
+}
+
+// setTimings sets `NetworkWait` and `DecodeTime` from the time a transform
+// took overall, and waited on reads.
+func (s *Stats) setTimings(elapsed, network time.Duration) {
+	s.NetworkWait = network
+
+	if s.DecodeTime = elapsed - network - s.outputWait; s.DecodeTime < 0 {
+		s.DecodeTime = 0
+	}
+}
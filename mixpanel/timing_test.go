@@ -0,0 +1,65 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// timingServer streams 5 events, pausing for `delay` before each.
+func timingServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 5; i++ {
+			time.Sleep(delay)
+			fmt.Fprintf(w, `{"event": "e", "properties": {"time": 1388534400, "i": %d}}`+"\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+func TestTimings(t *testing.T) {
+	fast, slow := timingServer(0), timingServer(20*time.Millisecond)
+	defer fast.Close()
+	defer slow.Close()
+
+	export := func(url string, consume time.Duration) Stats {
+		mix := NewWithURL("product", "key", "secret", url)
+
+		output := make(chan EventData)
+		go func() {
+			for range output {
+				time.Sleep(consume)
+			}
+		}()
+
+		stats, err := mix.ExportDate(context.Background(), time.Now(), output, nil)
+		close(output)
+
+		if err != nil {
+			t.Fatalf("raised error: %v", err)
+		}
+
+		return stats
+	}
+
+	fastStats, slowStats := export(fast.URL, 0), export(slow.URL, 0)
+
+	for _, stats := range []Stats{fastStats, slowStats} {
+		if stats.NetworkWait <= 0 || stats.DecodeTime < 0 {
+			t.Errorf("expected timings to be set, got %v network and %v decoding", stats.NetworkWait, stats.DecodeTime)
+		}
+	}
+
+	if slowStats.NetworkWait < 80*time.Millisecond || slowStats.NetworkWait <= fastStats.NetworkWait {
+		t.Errorf("expected the slow server to show in the network wait, got %v (and %v when fast)", slowStats.NetworkWait, fastStats.NetworkWait)
+	}
+
+	// A slow consumer is neither.
+	consumerStats := export(fast.URL, 20*time.Millisecond)
+	if consumerStats.DecodeTime > 50*time.Millisecond || consumerStats.NetworkWait > 50*time.Millisecond {
+		t.Errorf("expected waiting on the consumer not to count, got %v network and %v decoding", consumerStats.NetworkWait, consumerStats.DecodeTime)
+	}
+}
@@ -0,0 +1,69 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// TransactionsKey is the People profile property Mixpanel keeps a profile's
+// revenue in, as an array of `{"$amount": 9.99, "$time": "..."}` objects.
+const TransactionsKey = "$transactions"
+
+// ProfileTransactions flattens the `$transactions` of a People profile (as
+// sent by `ExportPeople`) into one record per transaction, for revenue
+// analysis:
+//
+// - `distinct_id` is the profile's `$distinct_id`.
+// - `amount` is the transaction's `$amount`, as a float64.
+// - `time` is the transaction's `$time`, as Mixpanel reported it.
+//
+// Any other properties of a transaction are kept as they are. A profile
+// without transactions has no records.
+func ProfileTransactions(profile EventData) []EventData {
+	list, _ := profile[PropTransactions].([]interface{})
+
+	records := make([]EventData, 0, len(list))
+
+	for _, item := range list {
+		tx, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		record := make(EventData, len(tx)+1)
+		for k, v := range tx {
+			switch k {
+			case PropAmount:
+				record["amount"] = normalizeAmount(v)
+			case PropTransactionTime:
+				record["time"] = v
+			default:
+				record[k] = v
+			}
+		}
+
+		record["distinct_id"] = profile[PropDistinctID]
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// normalizeAmount converts a transaction amount to a float64, however it was
+// decoded. Values that aren't numbers are left alone.
+func normalizeAmount(v interface{}) interface{} {
+	switch amount := v.(type) {
+	case json.Number:
+		if f, err := amount.Float64(); err == nil {
+			return f
+		}
+	case string:
+		if f, err := strconv.ParseFloat(amount, 64); err == nil {
+			return f
+		}
+	case int:
+		return float64(amount)
+	}
+
+	return v
+}
@@ -0,0 +1,110 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestExportPeopleTransactions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"page": 0, "page_size": 1000, "session_id": "session", "status": "ok", "total": 2, "results": [
+			{"$distinct_id": "buyer", "$properties": {"$transactions": [
+				{"$amount": 9.99, "$time": "2014-01-01T12:00:00"},
+				{"$amount": 25, "$time": "2014-01-02T08:30:00", "sku": "pro"}
+			]}},
+			{"$distinct_id": "browser", "$properties": {"plan": "free"}}
+		]}`)
+	}))
+	defer server.Close()
+
+	transactions := make(chan EventData, 10)
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+	mix.Transactions = transactions
+
+	output := make(chan EventData, 10)
+
+	count, err := mix.ExportPeople(context.Background(), output, "")
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if count != 2 {
+		t.Errorf("expected 2 profiles, got %d", count)
+	}
+
+	close(transactions)
+
+	var records []EventData
+	for tx := range transactions {
+		records = append(records, tx)
+	}
+
+	expected := []EventData{
+		{"distinct_id": "buyer", "amount": 9.99, "time": "2014-01-01T12:00:00", "product": "product"},
+		{"distinct_id": "buyer", "amount": 25.0, "time": "2014-01-02T08:30:00", "sku": "pro", "product": "product"},
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+func TestExportPeopleTransactionsConsumerOwnsProfile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"page": 0, "page_size": 1000, "session_id": "session", "status": "ok", "total": 1, "results": [
+			{"$distinct_id": "buyer", "$properties": {"$transactions": [{"$amount": 9.99, "$time": "2014-01-01T12:00:00"}]}}
+		]}`)
+	}))
+	defer server.Close()
+
+	transactions := make(chan EventData, 10)
+
+	mix := New("product", "key", "secret")
+	mix.Endpoints.Query = server.URL
+	mix.Transactions = transactions
+
+	// The consumer changes each profile as soon as it gets it, which
+	// `-race` catches if the export is still reading it.
+	output := make(chan EventData)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for profile := range output {
+			profile[PropDistinctID] = "changed"
+			profile[TransactionsKey] = nil
+		}
+	}()
+
+	count, err := mix.ExportPeople(context.Background(), output, "")
+	close(output)
+	<-done
+
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if count != 1 {
+		t.Errorf("expected 1 profile, got %d", count)
+	}
+
+	close(transactions)
+
+	var records []EventData
+	for tx := range transactions {
+		records = append(records, tx)
+	}
+
+	if len(records) != 1 || records[0]["distinct_id"] != "buyer" {
+		t.Errorf("expected the buyer's transaction, got %v", records)
+	}
+}
+
+func TestProfileTransactionsNone(t *testing.T) {
+	if records := ProfileTransactions(EventData{"$distinct_id": "a", TransactionsKey: "junk"}); len(records) != 0 {
+		t.Errorf("expected no records, got %v", records)
+	}
+}
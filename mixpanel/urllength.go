@@ -0,0 +1,65 @@
+package mixpanel
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Default value of `MaxURLLength`, comfortably below the limits of common
+// servers and proxies.
+const DefaultMaxURLLength = 8000
+
+// ErrURLTooLong is returned for requests over `MaxURLLength` under
+// `LongURLError`.
+var ErrURLTooLong = errors.New("request URL too long")
+
+// What to do with a GET request whose URL is longer than `MaxURLLength`.
+const (
+	// LongURLWarn sends the request anyway, only calling `OnLongURL`.
+	LongURLWarn = iota
+
+	// LongURLError fails the request with `ErrURLTooLong`.
+	LongURLError
+
+	// LongURLPost sends the request as a form encoded POST instead, for
+	// the endpoints that accept one, and otherwise acts like
+	// `LongURLWarn`.
+	LongURLPost
+)
+
+// Endpoints which accept their parameters form encoded in a POST body just
+// as well as in the URL.
+var postEndpoints = map[string]bool{
+	EndpointExport:       true,
+	EndpointEvents:       true,
+	EndpointSegmentation: true,
+	EndpointFunnels:      true,
+	EndpointStream:       true,
+	EndpointEngage:       true,
+}
+
+// checkURLLength applies `LongURLPolicy` to a GET request for `endpoint`
+// with a URL `length` bytes long, returning the method to send it with.
+func (m *Mixpanel) checkURLLength(endpoint string, length int) (string, error) {
+	max := m.MaxURLLength
+	if max <= 0 {
+		max = DefaultMaxURLLength
+	}
+
+	if length <= max {
+		return "GET", nil
+	}
+
+	if m.OnLongURL != nil {
+		m.OnLongURL(endpoint, length)
+	}
+
+	switch {
+	case m.LongURLPolicy == LongURLError:
+		return "", fmt.Errorf("%s: %s: %d bytes, limit is %d: %w", m.Product, endpoint, length, max, ErrURLTooLong)
+	case m.LongURLPolicy == LongURLPost && postEndpoints[endpoint]:
+		return "POST", nil
+	}
+
+	return "GET", nil
+}
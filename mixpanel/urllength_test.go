@@ -0,0 +1,78 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLongURL(t *testing.T) {
+	var method, where string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		method, where = r.Method, r.Form.Get("where")
+
+		fmt.Fprint(w, `{"event": "a", "properties": {}}`)
+	}))
+	defer server.Close()
+
+	filter := strings.Repeat(`properties["a"] == "b" or `, 400) + "true"
+	moreArgs := &url.Values{"where": {filter}}
+
+	var warned []int
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+	mix.OnLongURL = func(endpoint string, length int) {
+		if endpoint != EndpointExport {
+			t.Errorf("unexpected endpoint %s", endpoint)
+		}
+		warned = append(warned, length)
+	}
+
+	export := func() error {
+		_, err := mix.ExportDate(context.Background(), time.Now(), make(chan EventData, 1), moreArgs)
+		return err
+	}
+
+	// Warn: sent as is.
+	if err := export(); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if len(warned) != 1 || warned[0] <= DefaultMaxURLLength || method != "GET" {
+		t.Errorf("expected a warning and a GET, got %v and %s", warned, method)
+	}
+
+	// Post: the filter makes it over in the body.
+	mix.LongURLPolicy = LongURLPost
+
+	if err := export(); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if method != "POST" || where != filter {
+		t.Errorf("expected filter to be POSTed, got %s with %d byte filter", method, len(where))
+	}
+
+	// Error: never sent.
+	mix.LongURLPolicy = LongURLError
+	method = ""
+
+	if err := export(); !errors.Is(err, ErrURLTooLong) {
+		t.Errorf("expected ErrURLTooLong, got %v", err)
+	} else if method != "" {
+		t.Errorf("expected no request to be made")
+	}
+
+	// Nothing happens to short URLs.
+	warned = nil
+
+	if _, err := mix.ExportDate(context.Background(), time.Now(), make(chan EventData, 1), nil); err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if len(warned) != 0 || method != "GET" {
+		t.Errorf("expected a plain GET, got %v and %s", warned, method)
+	}
+}
@@ -0,0 +1,28 @@
+package mixpanel
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ExportUser exports every event of a single user from the project days
+// `from` through `to` (inclusive), to quickly pull one user's full history.
+//
+// The filtering is done by Mixpanel with a `where` expression matching the
+// user's ID in either the `distinct_id` or `$distinct_id` property, so only
+// that user's events are downloaded.
+func (m *Mixpanel) ExportUser(ctx context.Context, distinctID string, from, to time.Time, output chan<- EventData) (Stats, error) {
+	args := url.Values{}
+	args.Set("where", userFilter(distinctID))
+
+	return m.exportRange(ctx, from, to, output, &args)
+}
+
+// userFilter builds the `where` expression matching events of `distinctID`.
+func userFilter(distinctID string) string {
+	id := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(distinctID) + `"`
+
+	return `properties["distinct_id"] == ` + id + ` or properties["` + PropDistinctID + `"] == ` + id
+}
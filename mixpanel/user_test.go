@@ -0,0 +1,66 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExportUser(t *testing.T) {
+	events := []struct{ key, id string }{
+		{"distinct_id", "alice"},
+		{"distinct_id", "bob"},
+		{"$distinct_id", "alice"},
+		{"$distinct_id", `"quoted"`},
+	}
+
+	var where, from, to string
+
+	// Filters on the expression for the wanted user only, as Mixpanel would.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		where, from, to = query.Get("where"), query.Get("from_date"), query.Get("to_date")
+
+		for _, ev := range events {
+			if where == userFilter(ev.id) {
+				fmt.Fprintf(w, `{"event": "e", "properties": {%q: %q}}`+"\n", ev.key, ev.id)
+			}
+		}
+	}))
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL)
+
+	start, _ := time.Parse("2006-01-02", "2014-01-01")
+	end, _ := time.Parse("2006-01-02", "2014-01-31")
+
+	output := make(chan EventData, 10)
+	stats, err := mix.ExportUser(context.Background(), "alice", start, end, output)
+	close(output)
+
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if stats.EventsExported != 2 {
+		t.Errorf("expected 2 events, got %d", stats.EventsExported)
+	}
+
+	expected := `properties["distinct_id"] == "alice" or properties["$distinct_id"] == "alice"`
+	if where != expected {
+		t.Errorf("expected where %s, got %s", expected, where)
+	} else if from != "2014-01-01" || to != "2014-01-31" {
+		t.Errorf("unexpected range %s - %s", from, to)
+	}
+
+	for event := range output {
+		if event["distinct_id"] != "alice" && event["$distinct_id"] != "alice" {
+			t.Errorf("unexpected event %v", event)
+		}
+	}
+
+	if filter := userFilter(`"quoted"`); filter != `properties["distinct_id"] == "\"quoted\"" or properties["$distinct_id"] == "\"quoted\""` {
+		t.Errorf("ID not escaped: %s", filter)
+	}
+}
@@ -0,0 +1,61 @@
+package mixpanel
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// ErrInvalidConfig is wrapped by the error `Validate` returns for a
+// configuration which can't work, when none of the more specific errors
+// (`ErrMissingSecret`, `ErrMissingToken`, `ErrInvalidProjectID`) apply.
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Validate checks the configuration upfront, rather than leaving it to the
+// first request to fail. It checks that:
+//
+// - there are credentials for at least one of the ways of authenticating:
+//   an API key and secret, service account credentials, or a `Token`;
+// - whichever of those are given are complete;
+// - `Token` is set, if `ImportShape` needs it;
+// - each of the `Endpoints` is an absolute URL;
+// - `ProjectID`, if set, is numeric.
+//
+// It makes no requests, so it can't tell whether the credentials are right;
+// see `SelfTest` for that.
+func (m *Mixpanel) Validate() error {
+	secret := m.Secret != "" || m.SecretProvider != nil
+	key := m.Key != "" || secret
+	account := m.ServiceAccountUser != "" || m.ServiceAccountSecret != ""
+
+	switch {
+	case !key && !account && m.Token == "":
+		return fmt.Errorf("%s: %w: no credentials", m.Product, ErrInvalidConfig)
+	case m.Key != "" && !secret:
+		return fmt.Errorf("%s: %w", m.Product, ErrMissingSecret)
+	case m.Key == "" && secret:
+		return fmt.Errorf("%s: %w: API secret without a key", m.Product, ErrInvalidConfig)
+	case account && (m.ServiceAccountUser == "" || m.ServiceAccountSecret == ""):
+		return fmt.Errorf("%s: %w: incomplete service account credentials", m.Product, ErrInvalidConfig)
+	case m.ImportShape && m.Token == "":
+		return fmt.Errorf("%s: import shape: %w", m.Product, ErrMissingToken)
+	}
+
+	for _, base := range m.Endpoints.urls() {
+		u, err := url.Parse(base)
+		if err != nil {
+			return fmt.Errorf("%s: %w: endpoint: %v", m.Product, ErrInvalidConfig, err)
+		} else if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%s: %w: endpoint %q isn't an absolute URL", m.Product, ErrInvalidConfig, base)
+		}
+	}
+
+	if m.ProjectID != "" {
+		if _, err := strconv.ParseUint(m.ProjectID, 10, 64); err != nil {
+			return fmt.Errorf("%s: %w: %q", m.Product, ErrInvalidProjectID, m.ProjectID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package mixpanel
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(m *Mixpanel)
+		want  error
+	}{
+		{"key and secret", func(m *Mixpanel) {}, nil},
+		{"service account", func(m *Mixpanel) {
+			m.Key, m.Secret = "", ""
+			m.ServiceAccountUser, m.ServiceAccountSecret = "user", "secret"
+		}, nil},
+		{"token", func(m *Mixpanel) {
+			m.Key, m.Secret = "", ""
+			m.Token = "token"
+		}, nil},
+		{"project", func(m *Mixpanel) { m.ProjectID = "12345" }, nil},
+
+		{"no credentials", func(m *Mixpanel) { m.Key, m.Secret = "", "" }, ErrInvalidConfig},
+		{"missing secret", func(m *Mixpanel) { m.Secret = "" }, ErrMissingSecret},
+		{"missing key", func(m *Mixpanel) { m.Key = "" }, ErrInvalidConfig},
+		{"missing service account secret", func(m *Mixpanel) {
+			m.Key, m.Secret = "", ""
+			m.ServiceAccountUser = "user"
+		}, ErrInvalidConfig},
+		{"missing service account user", func(m *Mixpanel) {
+			m.ServiceAccountSecret = "secret"
+		}, ErrInvalidConfig},
+		{"import shape without token", func(m *Mixpanel) { m.ImportShape = true }, ErrMissingToken},
+		{"relative endpoint", func(m *Mixpanel) { m.Endpoints.Query = "mixpanel.com/api" }, ErrInvalidConfig},
+		{"bad endpoint", func(m *Mixpanel) { m.Endpoints.App = "http://[::1" }, ErrInvalidConfig},
+		{"no endpoints", func(m *Mixpanel) { m.Endpoints = Endpoints{} }, ErrInvalidConfig},
+		{"project ID", func(m *Mixpanel) { m.ProjectID = "my-project" }, ErrInvalidProjectID},
+	}
+
+	for _, test := range tests {
+		mix := New("product", "key", "secret")
+		test.setup(mix)
+
+		err := mix.Validate()
+		if test.want == nil && err != nil {
+			t.Errorf("%s: expected no error, got %v", test.name, err)
+		} else if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, err)
+		}
+	}
+}
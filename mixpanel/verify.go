@@ -0,0 +1,112 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// VerifyDate reconciles a full export of the given day against the event
+// counts reported by the query API.
+//
+// Returns the number of events Mixpanel reports for the day (`expected`), the
+// number of events a full `ExportDate` emitted (`got`), and possibly an
+// error. Any discrepancy is simply `expected - got`; it is up to the caller to
+// decide how much of one is acceptable.
+//
+// Caveats:
+// - Events can arrive late (mobile clients batching offline events, imports),
+//   so a recent day may not reconcile until some time after the fact. Verify
+//   days that are at least a few days old.
+// - Both the query API and the raw export bucket days by the project's
+//   timezone, but the counts are only comparable if nothing shifts that
+//   timezone between the two requests.
+// - The query API counts are computed from Mixpanel's reporting data, which
+//   may lag behind raw data by a small amount.
+func (m *Mixpanel) VerifyDate(ctx context.Context, date time.Time) (expected int64, got int64, err error) {
+	if expected, err = m.countEvents(ctx, date); err != nil {
+		return 0, 0, err
+	}
+
+	output := make(chan EventData, 100)
+	done := make(chan struct{})
+
+	// Only the count matters here, so just drain the events.
+	go func() {
+		defer close(done)
+
+		for range output {
+			got++
+		}
+	}()
+
+	_, err = m.ExportDate(ctx, date, output, nil)
+	close(output)
+	<-done
+
+	return expected, got, err
+}
+
+// countEvents asks the query API for the total number of events that occurred
+// on the given day, summed over all event names.
+func (m *Mixpanel) countEvents(ctx context.Context, date time.Time) (int64, error) {
+	perDay, err := m.dailyCounts(ctx, date, date)
+	if err != nil {
+		return 0, err
+	}
+
+	return perDay[date.Format("2006-01-02")], nil
+}
+
+// dailyCounts asks the query API for the number of events that occurred on
+// each day from `from` through `to` (inclusive), summed over all event names
+// and keyed by `YYYY-MM-DD`.
+func (m *Mixpanel) dailyCounts(ctx context.Context, from, to time.Time) (map[string]int64, error) {
+	args := m.makeArgs(from)
+	args.Set("type", "general")
+	args.Set("limit", "10000")
+
+	var names []string
+	if err := m.query(ctx, "events/names", args, &names); err != nil {
+		return nil, err
+	}
+
+	perDay := make(map[string]int64)
+
+	// No events at all, nothing more to ask about.
+	if len(names) == 0 {
+		return perDay, nil
+	}
+
+	encoded, err := json.Marshal(names)
+	if err != nil {
+		return nil, fmt.Errorf("%s: encoding event names failed: %w", m.Product, err)
+	}
+
+	args = m.baseArgs()
+	setDateRange(args, EndpointEvents, from, to)
+	args.Set("event", string(encoded))
+	args.Set("type", "general")
+	args.Set("unit", "day")
+
+	// Response has the form:
+	//   {"data": {"series": ["YYYY-MM-DD"], "values": {"event": {"YYYY-MM-DD": 123}}}}
+	var counts struct {
+		Data struct {
+			Values map[string]map[string]int64
+		}
+	}
+
+	if err := m.query(ctx, EndpointEvents, args, &counts); err != nil {
+		return nil, err
+	}
+
+	for _, byDay := range counts.Data.Values {
+		for day, count := range byDay {
+			perDay[day] += count
+		}
+	}
+
+	return perDay, nil
+}
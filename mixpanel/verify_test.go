@@ -0,0 +1,62 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newVerifyServer serves `exported` records from the raw export endpoint and
+// reports `reported` events for "2014-01-01" through the query API.
+func newVerifyServer(exported, reported int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/export":
+			fmt.Fprint(w, strings.Repeat(`{"event": "a", "properties": {"time": 1388534400}}`+"\n", exported))
+		case "/events/names":
+			fmt.Fprint(w, `["a"]`)
+		case "/events":
+			fmt.Fprintf(w, `{"data": {"series": ["2014-01-01"], "values": {"a": {"2014-01-01": %d}}}}`, reported)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestVerifyDateMatch(t *testing.T) {
+	server := newVerifyServer(3, 3)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL+"/export")
+	mix.Endpoints.Query = server.URL
+
+	date, _ := time.Parse("2006-01-02", "2014-01-01")
+
+	expected, got, err := mix.VerifyDate(context.Background(), date)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if expected != 3 || got != 3 {
+		t.Errorf("expected 3/3 events, got %d/%d", expected, got)
+	}
+}
+
+func TestVerifyDateMismatch(t *testing.T) {
+	server := newVerifyServer(2, 5)
+	defer server.Close()
+
+	mix := NewWithURL("product", "key", "secret", server.URL+"/export")
+	mix.Endpoints.Query = server.URL
+
+	date, _ := time.Parse("2006-01-02", "2014-01-01")
+
+	expected, got, err := mix.VerifyDate(context.Background(), date)
+	if err != nil {
+		t.Fatalf("raised error: %v", err)
+	} else if expected != 5 || got != 2 {
+		t.Errorf("expected 5/2 events, got %d/%d", expected, got)
+	}
+}